@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"component-4/config"
+	"component-4/internal/audit"
+	"component-4/internal/store"
+)
+
+// runVerifyAudit implementa el subcomando `verify-audit`: recorre la cadena de auditoría,
+// comprueba los puntos de control firmados e informa del primer eslabón roto.
+func runVerifyAudit(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("verify-audit", flag.ExitOnError)
+	fs.Parse(args)
+
+	if cfg.AuditSigningKey == "" {
+		fatal("AUDIT_SIGNING_KEY es necesario para verificar los puntos de control")
+	}
+
+	db, err := store.OpenPostgres(cfg)
+	if err != nil {
+		fatal("error al conectar con la base de datos", "error", err)
+	}
+	defer db.Close()
+	if err := store.WaitForPostgres(context.Background(), db, cfg.DBConnectTimeout); err != nil {
+		fatal("error al conectar con la base de datos", "error", err)
+	}
+
+	result, err := audit.NewStore(db, cfg.DBQueryTimeout).Verify(context.Background(), []byte(cfg.AuditSigningKey))
+	if err != nil {
+		fatal("error verificando la cadena de auditoría", "error", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(result)
+	if result.BrokenAt != nil {
+		os.Exit(1)
+	}
+}
@@ -1,14 +1,26 @@
 package main
 
 import (
-	"database/sql"
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"sync"
 
 	"component-4/config"
+	"component-4/internal/audit"
 	"component-4/internal/auth"
+	"component-4/internal/bulk"
 	"component-4/internal/handlers"
+	"component-4/internal/health"
+	"component-4/internal/jobs"
+	"component-4/internal/logging"
+	"component-4/internal/metrics"
+	"component-4/internal/mail"
 	"component-4/internal/store"
+	"component-4/internal/tracing"
 	"component-4/internal/models"
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
@@ -48,66 +60,218 @@ import (
 func main() {
 	// Cargar configuración
 	cfg := config.LoadConfig()
+	logging.Setup(cfg.LogLevel)
+
+	// Trazas OpenTelemetry (sin exportador si no hay colector configurado)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		fatal("error configurando las trazas", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Subcomandos de administración
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import-users":
+			runImportUsers(cfg, os.Args[2:])
+			return
+		case "export-users":
+			runExportUsers(cfg, os.Args[2:])
+			return
+		case "verify-audit":
+			runVerifyAudit(cfg, os.Args[2:])
+			return
+		case "migrate":
+			runMigrate(cfg, os.Args[2:])
+			return
+		default:
+			fatal("subcomando desconocido (disponibles: import-users, export-users, verify-audit, migrate)", "subcommand", os.Args[1])
+		}
+	}
+
 	if cfg.Port == "" {
 		cfg.Port = "8080" // Puerto por defecto
 	}
 	if cfg.JWTSecret == "" {
-		log.Fatal("FATAL: JWT_SECRET environment variable is not set.")
+		fatal("JWT_SECRET environment variable is not set")
+	}
+	// Los puntos de control de auditoría se firman con una clave propia: quien obtenga JWT_SECRET
+	// podría, si no, reescribir la cadena y volver a firmarla
+	if cfg.AuditSigningKey == "" {
+		fatal("AUDIT_SIGNING_KEY environment variable is not set")
+	}
+	if cfg.AuditSigningKey == cfg.JWTSecret {
+		fatal("AUDIT_SIGNING_KEY must be different from JWT_SECRET")
 	}
 
 	// Inicializar la configuración de OAuth de Google
 	auth.ConfigureGoogleOauth(cfg)
 
-	// Inicializar la conexión a la base de datos
-	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	// Inicializar el pool de conexiones, compartido por las migraciones y todos los stores
+	db, err := store.OpenPostgres(cfg)
 	if err != nil {
-		log.Fatalf("Error al conectar con la base de datos: %v", err)
+		fatal("error al conectar con la base de datos", "error", err)
+	}
+	if err := store.WaitForPostgres(context.Background(), db, cfg.DBConnectTimeout); err != nil {
+		fatal("error al conectar con la base de datos", "error", err)
 	}
-	defer db.Close()
+	metrics.RegisterDBStats(db, cfg.DBName)
 
-	// Ejecutar migraciones antes de inicializar el store
-	err = migrate.RunMigrations(db, "./migrations")
+	// Réplicas de lectura para las búsquedas de usuarios (opcionales)
+	replicas, err := store.OpenReplicas(cfg)
 	if err != nil {
-		log.Fatalf("Error ejecutando migraciones: %v", err)
+		fatal("error al conectar con las réplicas de lectura", "error", err)
+	}
+	for i, replica := range replicas.DBs() {
+		metrics.RegisterDBStats(replica, fmt.Sprintf("%s_replica_%d", cfg.DBName, i+1))
+	}
+	for _, name := range replicas.Names() {
+		metrics.RegisterReplicaUp(name, func() bool { return replicas.IsUp(name) })
 	}
 
-	// Inicializar el store
-	store, err := store.NewUserStore(cfg)
+	// Checks de readiness
+	checker := health.NewChecker(cfg.HealthCheckTimeout)
+	migrationsCheck, markMigrated := health.MigrationsCheck()
+	checker.Register("database", health.DatabaseCheck(db))
+	checker.Register("migrations", migrationsCheck)
+	checker.Register("signing_keys", health.SigningKeysCheck(cfg))
+	checker.Register("google_oauth", health.GoogleConfigCheck(cfg))
+
+	// Ejecutar migraciones antes de inicializar el store
+	err = migrate.RunMigrations(db, migrationsFS(cfg.MigrationsDir))
 	if err != nil {
-		log.Fatalf("Error creating store: %v", err)
+		fatal("error ejecutando migraciones", "error", err)
 	}
+	markMigrated()
+
+	// Inicializar el store
+	userStore := store.NewUserStore(db, replicas, cfg.DBQueryTimeout)
+	invitationStore := store.NewInvitationStore(db, replicas, cfg.DBQueryTimeout)
+	sessionStore := store.NewSessionStore(db, cfg.DBQueryTimeout)
+	serviceClientStore := store.NewServiceClientStore(db, cfg.DBQueryTimeout)
+	oauthStore := store.NewOAuthStore(db, cfg.DBQueryTimeout)
+	auditStore := audit.NewStore(db, cfg.DBQueryTimeout)
 
 	// Crear usuario administrador si no existe
-	_, err = store.FindByEmail("rector@colegio.edu")
+	_, err = userStore.FindByEmail(context.Background(), "rector@colegio.edu")
 	if err != nil {
-		if err.Error() == "user not found" {
-			_, err = store.CreateNativeUser(
+		if errors.Is(err, store.ErrUserNotFound) {
+			_, err = userStore.CreateNativeUser(
+				context.Background(),
 				"rector@gmail.com",
 				"Rector del Colegio Luis Alberto",
 				"rector123",
 				models.ROLE_ADMINISTRADOR,
 			)
 			if err != nil {
-				log.Printf("Error al crear usuario administrador: %v", err)
+				slog.Error("error al crear usuario administrador", "error", err)
 			} else {
-				log.Println("Usuario administrador creado exitosamente")
+				slog.Info("usuario administrador creado exitosamente")
 			}
 		} else {
-			log.Printf("Error al buscar usuario administrador: %v", err)
+			slog.Error("error al buscar usuario administrador", "error", err)
 		}
 	}
 
+	// Tareas en segundo plano; se detienen al apagar el servidor
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(5)
+	// Eliminar las cuentas cuyo periodo de gracia terminó
+	go func() {
+		defer workers.Done()
+		jobs.RunDeletionPurger(workersCtx, userStore, cfg.DeletionPurgeInterval)
+	}()
+	// Encadenar los eventos de auditoría que las peticiones guardan sin encadenar
+	go func() {
+		defer workers.Done()
+		jobs.RunAuditChainer(workersCtx, auditStore, cfg.AuditChainInterval)
+	}()
+	// Firmar periódicamente puntos de control de la cadena de auditoría
+	go func() {
+		defer workers.Done()
+		jobs.RunAuditCheckpointer(workersCtx, auditStore, []byte(cfg.AuditSigningKey), cfg.AuditCheckpointInterval)
+	}()
+	// Eliminar las solicitudes de autorización OAuth vencidas
+	go func() {
+		defer workers.Done()
+		jobs.RunAuthorizationPurger(workersCtx, oauthStore, cfg.OAuthPurgeInterval)
+	}()
+	// Sacar de rotación las réplicas de lectura que no responden y devolver las recuperadas
+	go func() {
+		defer workers.Done()
+		replicas.Watch(workersCtx, cfg.DBReplicaCheckInterval)
+	}()
+
+	// Clave con la que se firman los ID tokens de OIDC
+	oidcKey, err := loadOIDCSigningKey(cfg)
+	if err != nil {
+		fatal("error cargando la clave de firma de OIDC", "error", err)
+	}
+
+	// TLS opcional con recarga del certificado cuando cambia en disco
+	tlsConfig, certReloader, err := setupTLS(cfg)
+	if err != nil {
+		fatal("error configurando TLS", "error", err)
+	}
+	if certReloader != nil {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			certReloader.Watch(workersCtx, cfg.TLSReloadInterval)
+		}()
+	}
+
 	// Inicializar los manejadores de autenticación
-	authHandler := handlers.NewAuthHandler(store, cfg)
+	authHandler := handlers.NewAuthHandler(userStore, sessionStore, auditStore, cfg)
+	invitationHandler := handlers.NewInvitationHandler(invitationStore, sessionStore, auditStore, cfg)
+	sessionHandler := handlers.NewSessionHandler(sessionStore, auditStore)
+	auditHandler := handlers.NewAuditHandler(auditStore)
+	introspectionHandler := handlers.NewIntrospectionHandler(userStore, sessionStore, serviceClientStore, cfg)
+	serviceClientHandler := handlers.NewServiceClientHandler(serviceClientStore, auditStore, cfg)
+	oidcHandler := handlers.NewOIDCHandler(oauthStore, userStore, sessionStore, serviceClientHandler, auditStore, cfg, oidcKey)
+	oauthClientHandler := handlers.NewOAuthClientHandler(oauthStore, auditStore)
+	mailer := mail.NewMailer(cfg)
+	meHandler := handlers.NewMeHandler(userStore, sessionStore, auditStore, mailer, cfg)
+	userAdminHandler := handlers.NewUserAdminHandler(userStore, &bulk.Importer{
+		Users:       userStore,
+		Invitations: invitationStore,
+		Mailer:      mailer,
+		Config:      cfg,
+	}, auditStore)
+
+	// Proxies cuyo X-Forwarded-For se acepta como IP del cliente
+	trustedProxies, err := handlers.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		fatal("error en TRUSTED_PROXIES", "error", err)
+	}
 
 	// Crear el router principal
 	r := mux.NewRouter()
 
+	// Span por petición, propagando el trace-context W3C entrante
+	r.Use(tracing.Middleware(cfg.ServiceName))
+	// IP del cliente para la auditoría y las sesiones
+	r.Use(handlers.ClientIPMiddleware(trustedProxies))
+	// Logger por petición con request ID
+	r.Use(logging.Middleware)
+	// Métricas HTTP por ruta
+	r.Use(metrics.Middleware)
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Sondas de liveness y readiness
+	r.HandleFunc("/healthz", checker.LivenessHandler).Methods("GET")
+	r.HandleFunc("/readyz", checker.ReadinessHandler).Methods("GET")
+
+	// Descubrimiento de OpenID Connect
+	r.HandleFunc("/.well-known/openid-configuration", oidcHandler.DiscoveryHandler).Methods("GET")
+	r.HandleFunc("/.well-known/jwks.json", oidcHandler.JWKSHandler).Methods("GET")
+
 	// Configurar CORS
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3001")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 			w.Header().Set("Access-Control-Expose-Headers", "Authorization")
 			
@@ -133,24 +297,127 @@ func main() {
 	api.HandleFunc("/logout", authHandler.LogoutHandler).Methods("POST", "OPTIONS")
 	// Nueva ruta para verificar si un correo existe
 	api.HandleFunc("/users/exists", authHandler.UserExists).Methods("GET", "OPTIONS")
+	// Introspección de tokens para otros servicios (RFC 7662)
+	api.HandleFunc("/oauth/introspect", introspectionHandler.IntrospectHandler).Methods("POST")
+	api.HandleFunc("/oauth/token", oidcHandler.TokenHandler).Methods("POST")
+	api.HandleFunc("/oauth/authorize", oidcHandler.AuthorizeHandler).Methods("GET")
+	api.HandleFunc("/oauth/userinfo", oidcHandler.UserInfoHandler).Methods("GET", "POST")
+	// Aceptación de invitaciones del personal
+	api.HandleFunc("/invitations/accept", invitationHandler.AcceptInvitationHandler).Methods("POST", "OPTIONS")
 	// Ruta simple de health check
 	api.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Servidor funcionando correctamente!"))
 	}).Methods("GET")
 
+	// La importación y las exportaciones pueden superar HTTP_READ_TIMEOUT y HTTP_WRITE_TIMEOUT
+	long := handlers.ExtendDeadlines(cfg.HTTPLongTimeout)
+
 	// Rutas protegidas
 	protected := api.PathPrefix("/profile").Subrouter()
-	protected.Use(handlers.AuthMiddleware(cfg.JWTSecret))
+	protected.Use(handlers.AuthMiddleware(cfg.JWTSecret, sessionStore))
 	protected.HandleFunc("", authHandler.ProtectedHandler).Methods("GET", "OPTIONS")
 
+	// Autoservicio del perfil
+	me := api.PathPrefix("/me").Subrouter()
+	me.Use(handlers.AuthMiddleware(cfg.JWTSecret, sessionStore))
+	me.HandleFunc("", meHandler.GetMeHandler).Methods("GET", "OPTIONS")
+	me.HandleFunc("", meHandler.UpdateMeHandler).Methods("PATCH", "OPTIONS")
+	me.HandleFunc("/email", meHandler.ChangeEmailHandler).Methods("POST", "OPTIONS")
+	me.HandleFunc("/email/verify", meHandler.VerifyEmailHandler).Methods("POST", "OPTIONS")
+	me.HandleFunc("", meHandler.DeleteMeHandler).Methods("DELETE", "OPTIONS")
+	me.HandleFunc("/deletion/cancel", meHandler.CancelDeletionHandler).Methods("POST", "OPTIONS")
+	me.Handle("/export", long(http.HandlerFunc(meHandler.ExportMeHandler))).Methods("GET", "OPTIONS")
+	me.HandleFunc("/sessions", sessionHandler.ListMySessionsHandler).Methods("GET", "OPTIONS")
+	me.HandleFunc("/sessions/{id}", sessionHandler.RevokeMySessionHandler).Methods("DELETE", "OPTIONS")
+
+	// Pantalla de consentimiento de OIDC: el usuario ya inició sesión en el frontend
+	consent := api.PathPrefix("/oauth/consent").Subrouter()
+	consent.Use(handlers.AuthMiddleware(cfg.JWTSecret, sessionStore))
+	consent.HandleFunc("/{id}", oidcHandler.GetConsentHandler).Methods("GET", "OPTIONS")
+	consent.HandleFunc("/{id}", oidcHandler.ConsentHandler).Methods("POST", "OPTIONS")
+
+	// Rutas de administración que también pueden usar las cuentas de servicio con el scope correspondiente.
+	// Se registran antes que el subrouter de administración para que este no las intercepte.
+	scoped := api.PathPrefix("/admin").Subrouter()
+	scoped.Use(handlers.ServiceAuthMiddleware(cfg.JWTSecret, sessionStore, serviceClientStore))
+	scoped.Handle("/users/import", long(handlers.RequireRoleOrScope(models.SCOPE_USERS_WRITE, models.ROLE_ADMINISTRADOR)(
+		http.HandlerFunc(userAdminHandler.ImportUsersHandler)))).Methods("POST", "OPTIONS")
+	scoped.Handle("/users/export", long(handlers.RequireRoleOrScope(models.SCOPE_USERS_READ, models.ROLE_ADMINISTRADOR)(
+		http.HandlerFunc(userAdminHandler.ExportUsersHandler)))).Methods("GET", "OPTIONS")
+	scoped.Handle("/audit-events", long(handlers.RequireRoleOrScope(models.SCOPE_AUDIT_READ, models.ROLE_ADMINISTRADOR)(
+		http.HandlerFunc(auditHandler.ListAuditEventsHandler)))).Methods("GET", "OPTIONS")
+
+	// Rutas de administración
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(handlers.AuthMiddleware(cfg.JWTSecret, sessionStore), handlers.RequireRole(models.ROLE_ADMINISTRADOR))
+	admin.HandleFunc("/invitations", invitationHandler.CreateInvitationHandler).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/invitations", invitationHandler.ListInvitationsHandler).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/invitations/{id}", invitationHandler.RevokeInvitationHandler).Methods("DELETE", "OPTIONS")
+	admin.HandleFunc("/users/{id}/anonymize", userAdminHandler.AnonymizeUserHandler).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/users/{id}/sessions", sessionHandler.ListUserSessionsHandler).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/sessions/{id}", sessionHandler.RevokeSessionHandler).Methods("DELETE", "OPTIONS")
+	admin.HandleFunc("/service-clients", serviceClientHandler.CreateServiceClientHandler).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/service-clients", serviceClientHandler.ListServiceClientsHandler).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/service-clients/{id}/rotate", serviceClientHandler.RotateServiceClientHandler).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/service-clients/{id}", serviceClientHandler.RevokeServiceClientHandler).Methods("DELETE", "OPTIONS")
+	admin.HandleFunc("/oauth-clients", oauthClientHandler.CreateOAuthClientHandler).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/oauth-clients", oauthClientHandler.ListOAuthClientsHandler).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/oauth-clients/{id}", oauthClientHandler.RevokeOAuthClientHandler).Methods("DELETE", "OPTIONS")
+
 
 	// Swagger endpoint (fuera de /api)
 	// r.PathPrefix("/swagger").Handler(httpSwagger.WrapHandler)
 
 	// Iniciar el servidor
-	log.Printf("Starting server on port %s", cfg.Port)
-	if err := http.ListenAndServe(":"+cfg.Port, r); err != nil {
-		log.Fatalf("Could not start server: %v", err)
+	servers := []*http.Server{newServer(cfg, ":"+cfg.Port, r, tlsConfig)}
+	slog.Info("starting server", "port", cfg.Port, "tls", tlsConfig != nil)
+
+	// Listener interno para otros componentes del backend, con mTLS opcional
+	if cfg.InternalPort != "" {
+		internalConfig, err := internalTLS(cfg, tlsConfig)
+		if err != nil {
+			fatal("error configurando el listener interno", "error", err)
+		}
+		internal := mux.NewRouter()
+		internal.Use(tracing.Middleware(cfg.ServiceName), handlers.ClientIPMiddleware(trustedProxies), logging.Middleware, metrics.Middleware)
+		internal.HandleFunc("/healthz", checker.LivenessHandler).Methods("GET")
+		internal.HandleFunc("/readyz", checker.ReadinessHandler).Methods("GET")
+		internal.Handle("/metrics", metrics.Handler()).Methods("GET")
+		internal.HandleFunc("/api/v1/oauth/introspect", introspectionHandler.IntrospectHandler).Methods("POST")
+		servers = append(servers, newServer(cfg, ":"+cfg.InternalPort, internal, internalConfig))
+		slog.Info("starting internal listener", "port", cfg.InternalPort, "mtls", cfg.InternalClientCAFile != "")
+	}
+
+	if err := serve(servers, checker, cfg.ReadinessDrainDelay, cfg.ShutdownTimeout); err != nil {
+		fatal("could not start server", "error", err)
+	}
+
+	// Apagado ordenado: primero los workers, que aún usan la base de datos, después los pools
+	// de conexiones; las trazas pendientes se envían al salir de main.
+	stopWorkers()
+	workers.Wait()
+	if err := replicas.Close(); err != nil {
+		slog.Error("error cerrando las réplicas de lectura", "error", err)
 	}
+	if err := db.Close(); err != nil {
+		slog.Error("error cerrando la base de datos", "error", err)
+	}
+	slog.Info("servidor detenido")
+}
+
+// loadOIDCSigningKey carga la clave de OIDC_SIGNING_KEY_FILE o, si no se configuró, genera una
+// efímera: los ID tokens emitidos dejan de verificarse al reiniciar, lo que solo es aceptable en desarrollo.
+func loadOIDCSigningKey(cfg *config.Config) (*auth.SigningKey, error) {
+	if cfg.OIDCSigningKeyFile != "" {
+		return auth.LoadSigningKey(cfg.OIDCSigningKeyFile)
+	}
+	slog.Warn("OIDC_SIGNING_KEY_FILE no configurado; se usa una clave de firma efímera")
+	return auth.GenerateSigningKey()
+}
+
+// fatal registra un error y termina el proceso.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
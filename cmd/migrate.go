@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"component-4/config"
+	"component-4/internal/migrate"
+	"component-4/internal/store"
+	"component-4/migrations"
+)
+
+// runMigrate implementa el subcomando `migrate`:
+//
+//	migrate [-dir DIR] up [N]        aplica las N siguientes migraciones pendientes (todas por defecto)
+//	migrate [-dir DIR] down [N]      revierte las N últimas migraciones aplicadas (1 por defecto)
+//	migrate [-dir DIR] status        muestra el estado de cada migración
+//	migrate [-dir DIR] create NOMBRE crea un par de archivos .up.sql/.down.sql vacíos
+//	migrate [-dir DIR] force VERSION marca como aplicadas exactamente las migraciones <= VERSION
+//
+// Sin -dir (ni MIGRATIONS_DIR) se usan las migraciones incluidas en el binario; create, que escribe
+// archivos, usa entonces ./migrations.
+func runMigrate(cfg *config.Config, args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dir := flags.String("dir", cfg.MigrationsDir, "directorio de migraciones en lugar de las incluidas en el binario")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fatal("falta la acción (disponibles: up, down, status, create, force)")
+	}
+	action, rest := flags.Arg(0), flags.Args()[1:]
+
+	if action == "create" {
+		if len(rest) != 1 {
+			fatal("uso: migrate create NOMBRE")
+		}
+		target := *dir
+		if target == "" {
+			target = "./migrations"
+		}
+		up, down, err := migrate.Create(target, rest[0])
+		if err != nil {
+			fatal("error creando la migración", "error", err)
+		}
+		fmt.Println(up)
+		fmt.Println(down)
+		return
+	}
+
+	db, err := store.OpenPostgres(cfg)
+	if err != nil {
+		fatal("error al conectar con la base de datos", "error", err)
+	}
+	defer db.Close()
+	if err := store.WaitForPostgres(context.Background(), db, cfg.DBConnectTimeout); err != nil {
+		fatal("error al conectar con la base de datos", "error", err)
+	}
+
+	m, err := migrate.New(db, migrationsFS(*dir))
+	if err != nil {
+		fatal("error cargando las migraciones", "dir", *dir, "error", err)
+	}
+	ctx := context.Background()
+
+	switch action {
+	case "up":
+		n, err := m.Up(ctx, countArg(rest, 0))
+		if err != nil {
+			fatal("error ejecutando migraciones", "applied", n, "error", err)
+		}
+	case "down":
+		n, err := m.Down(ctx, countArg(rest, 1))
+		if err != nil {
+			fatal("error revirtiendo migraciones", "reverted", n, "error", err)
+		}
+		fmt.Fprintf(os.Stderr, "%d migraciones revertidas\n", n)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			fatal("error consultando el estado de las migraciones", "error", err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tSTATE\tAPPLIED_AT")
+		for _, s := range statuses {
+			appliedAt := "-"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(tw, "%03d\t%s\t%s\t%s\n", s.Version, s.Name, s.State, appliedAt)
+		}
+		tw.Flush()
+	case "force":
+		if len(rest) != 1 {
+			fatal("uso: migrate force VERSION")
+		}
+		version, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil || version < 0 {
+			fatal("versión inválida", "version", rest[0])
+		}
+		if err := m.Force(ctx, version); err != nil {
+			fatal("error forzando la versión", "error", err)
+		}
+	default:
+		fatal("acción desconocida (disponibles: up, down, status, create, force)", "action", action)
+	}
+}
+
+// migrationsFS devuelve las migraciones incluidas en el binario o, si se indica, las de un directorio
+// externo (útil en desarrollo para probar una migración sin recompilar).
+func migrationsFS(dir string) fs.FS {
+	if dir == "" {
+		return migrations.FS
+	}
+	return os.DirFS(dir)
+}
+
+// countArg interpreta el argumento opcional N de up y down.
+func countArg(rest []string, def int) int {
+	if len(rest) == 0 {
+		return def
+	}
+	n, err := strconv.Atoi(rest[0])
+	if err != nil || n <= 0 || len(rest) > 1 {
+		fatal("N debe ser un entero positivo", "value", rest[0])
+	}
+	return n
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"component-4/config"
+	"component-4/internal/health"
+	"component-4/internal/tlsconfig"
+)
+
+// newServer crea un servidor HTTP con los timeouts de la configuración, para que un cliente
+// lento no pueda retener una conexión indefinidamente. Con tlsConfig no nil sirve HTTPS.
+func newServer(cfg *config.Config, addr string, handler http.Handler, tlsConfig *tls.Config) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
+}
+
+// listen arranca el servidor en HTTP o HTTPS según tenga configuración TLS. El certificado lo
+// aporta TLSConfig.GetCertificate, por eso no se pasan rutas a ListenAndServeTLS.
+func listen(srv *http.Server) error {
+	if srv.TLSConfig != nil {
+		return srv.ListenAndServeTLS("", "")
+	}
+	return srv.ListenAndServe()
+}
+
+// serve atiende peticiones en todos los servidores hasta recibir SIGINT o SIGTERM (o hasta que
+// alguno falle). Al recibir la señal marca el servicio como no listo, espera readinessDelay para
+// que el balanceador lo retire y después apaga los servidores esperando a que terminen las
+// peticiones en curso, como mucho durante shutdownTimeout; pasado ese plazo se cierran las
+// conexiones que queden abiertas.
+func serve(servers []*http.Server, checker *health.Checker, readinessDelay, shutdownTimeout time.Duration) error {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	errc := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			errc <- listen(srv)
+		}(srv)
+	}
+
+	var serveErr error
+	select {
+	case serveErr = <-errc:
+		slog.Error("un servidor terminó inesperadamente, apagando el resto", "error", serveErr)
+	case sig := <-stop:
+		slog.Info("señal recibida, apagando el servidor", "signal", sig.String())
+		checker.SetShuttingDown()
+		time.Sleep(readinessDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				slog.Warn("plazo de apagado agotado, cerrando las conexiones restantes", "addr", srv.Addr, "error", err)
+				srv.Close()
+			}
+		}(srv)
+	}
+	wg.Wait()
+
+	if serveErr != nil {
+		return serveErr
+	}
+	for range servers {
+		if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupTLS prepara la configuración TLS de los listeners. Devuelve nil si no hay certificado
+// configurado, en cuyo caso se sirve HTTP sin cifrar (p. ej. detrás de un proxy que termina TLS).
+func setupTLS(cfg *config.Config) (*tls.Config, *tlsconfig.CertReloader, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		return nil, nil, nil
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return nil, nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	reloader, err := tlsconfig.NewCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsConfig, err := tlsconfig.ServerConfig(reloader, tlsconfig.Options{
+		MinVersion:   cfg.TLSMinVersion,
+		CipherSuites: cfg.TLSCipherSuites,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return tlsConfig, reloader, nil
+}
+
+// internalTLS deriva la configuración del listener interno: el mismo certificado y, si hay
+// una CA de clientes configurada, verificación obligatoria del certificado del cliente (mTLS).
+func internalTLS(cfg *config.Config, public *tls.Config) (*tls.Config, error) {
+	if cfg.InternalClientCAFile == "" {
+		return public, nil
+	}
+	if public == nil {
+		return nil, errors.New("INTERNAL_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	internal := public.Clone()
+	if err := tlsconfig.RequireClientCerts(internal, cfg.InternalClientCAFile); err != nil {
+		return nil, err
+	}
+	return internal, nil
+}
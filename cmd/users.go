@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"component-4/config"
+	"component-4/internal/bulk"
+	"component-4/internal/mail"
+	"component-4/internal/store"
+)
+
+// runImportUsers implementa el subcomando `import-users`.
+func runImportUsers(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("import-users", flag.ExitOnError)
+	file := fs.String("file", "", "archivo CSV o JSON con los usuarios a importar (obligatorio)")
+	format := fs.String("format", "", "formato del archivo: csv o json (por defecto según la extensión)")
+	dryRun := fs.Bool("dry-run", false, "validar y simular la importación sin persistir cambios")
+	invite := fs.Bool("send-invitations", false, "enviar invitaciones por correo a las filas sin contraseña")
+	sqlitePath := fs.String("sqlite", "", "importar al archivo SQLite indicado en lugar de Postgres (se crea si no existe)")
+	fs.Parse(args)
+
+	if *file == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(*file), ".")
+	}
+	if *invite && cfg.JWTSecret == "" {
+		fatal("JWT_SECRET environment variable is required to send invitations")
+	}
+	if *invite && *sqlitePath != "" {
+		fatal("las invitaciones se guardan en Postgres y no están disponibles con -sqlite")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		fatal("error abriendo el archivo", "file", *file, "error", err)
+	}
+	defer f.Close()
+
+	rows, err := bulk.Parse(f, *format)
+	if err != nil {
+		fatal("error leyendo el archivo", "file", *file, "error", err)
+	}
+
+	db, userStore := openUserRepository(cfg, *sqlitePath)
+	defer userStore.Close()
+
+	importer := &bulk.Importer{
+		Users:  userStore,
+		Mailer: mail.NewMailer(cfg),
+		Config: cfg,
+	}
+	if db != nil {
+		defer db.Close()
+		importer.Invitations = store.NewInvitationStore(db, nil, cfg.DBQueryTimeout)
+	}
+	report, err := importer.Import(context.Background(), rows, bulk.Options{DryRun: *dryRun, SendInvitations: *invite})
+	if err != nil {
+		fatal("error importando usuarios", "error", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	if report.Invalid > 0 || report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// runExportUsers implementa el subcomando `export-users`.
+func runExportUsers(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("export-users", flag.ExitOnError)
+	format := fs.String("format", "csv", "formato de salida: csv o json")
+	out := fs.String("out", "", "archivo de salida (por defecto la salida estándar)")
+	sqlitePath := fs.String("sqlite", "", "exportar desde el archivo SQLite indicado en lugar de Postgres")
+	fs.Parse(args)
+
+	db, userStore := openUserRepository(cfg, *sqlitePath)
+	if db != nil {
+		defer db.Close()
+	}
+	defer userStore.Close()
+
+	users, err := userStore.ListUsers(context.Background())
+	if err != nil {
+		fatal("error listando usuarios", "error", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fatal("error creando el archivo de salida", "file", *out, "error", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := bulk.Export(w, users, *format); err != nil {
+		fatal("error exportando usuarios", "error", err)
+	}
+	if *out != "" {
+		fmt.Fprintf(os.Stderr, "%d usuarios exportados a %s\n", len(users), *out)
+	}
+}
+
+// openUserRepository abre el directorio de usuarios de los subcomandos: el archivo SQLite de -sqlite
+// o, si no se indica, Postgres. Devuelve también el pool de Postgres, que la importación necesita
+// para las invitaciones; con SQLite es nil y no se conecta a Postgres.
+func openUserRepository(cfg *config.Config, sqlitePath string) (*sql.DB, store.UserRepository) {
+	if sqlitePath != "" {
+		userStore, err := store.NewSQLiteUserStore(sqlitePath, cfg.DBQueryTimeout)
+		if err != nil {
+			fatal("error creating store", "error", err)
+		}
+		return nil, userStore
+	}
+
+	db, err := store.OpenPostgres(cfg)
+	if err != nil {
+		fatal("error al conectar con la base de datos", "error", err)
+	}
+	if err := store.WaitForPostgres(context.Background(), db, cfg.DBConnectTimeout); err != nil {
+		fatal("error al conectar con la base de datos", "error", err)
+	}
+	return db, store.NewUserStore(db, nil, cfg.DBQueryTimeout)
+}
@@ -1,9 +1,12 @@
 package config
 
 import (
-	"fmt"
-	"log"
+	"log/slog"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -13,32 +16,93 @@ type Config struct {
 	GoogleClient   string
 	GoogleSecret   string
 	GoogleRedirect string
-	JWTSecret      string // Secreto para firmar los tokens JWT
-	DatabaseURL    string // URL de conexión a la base de datos
-	DBHost         string // Host de la base de datos
-	DBPort         string // Puerto de la base de datos
-	DBUser         string // Usuario de la base de datos
-	DBPassword     string // Contraseña de la base de datos
-	DBName         string // Nombre de la base de datos
-	DBSSLMode      string // Modo SSL de la base de datos
-	FrontendURL    string // URL del frontend para redirección
+	JWTSecret      string        // Secreto para firmar los tokens JWT
+	DatabaseURL    string        // URL de conexión a la base de datos
+	DBHost         string        // Host de la base de datos
+	DBPort         string        // Puerto de la base de datos
+	DBUser         string        // Usuario de la base de datos
+	DBPassword     string        // Contraseña de la base de datos
+	DBName         string        // Nombre de la base de datos
+	DBSSLMode      string        // Modo SSL de la base de datos
+	MigrationsDir  string        // Directorio de migraciones que reemplaza a las incluidas en el binario (vacío = incluidas)
+	FrontendURL    string        // URL del frontend para redirección
+	LogLevel       string        // Nivel de log: debug, info, warn o error
+	ServiceName    string        // Nombre del servicio en las trazas
+	OTLPEndpoint   string        // Colector OTLP/HTTP de trazas (vacío = no exportar)
+	InvitationTTL  time.Duration // Vigencia de los tokens de invitación
+	SMTPHost       string        // Servidor SMTP para el envío de correos (vacío = solo registrar en el log)
+	SMTPPort       string        // Puerto del servidor SMTP
+	SMTPUser       string        // Usuario del servidor SMTP
+	SMTPPassword   string        // Contraseña del servidor SMTP
+	SMTPFrom       string        // Remitente de los correos enviados
+
+	AccountDeletionGrace  time.Duration // Periodo de gracia antes de eliminar definitivamente una cuenta
+	DeletionPurgeInterval time.Duration // Frecuencia con la que se eliminan las cuentas vencidas
+
+	HTTPReadTimeout       time.Duration // Tiempo máximo para leer una petición completa
+	HTTPReadHeaderTimeout time.Duration // Tiempo máximo para leer las cabeceras de una petición
+	HTTPWriteTimeout      time.Duration // Tiempo máximo para escribir la respuesta
+	HTTPIdleTimeout       time.Duration // Tiempo máximo de una conexión keep-alive inactiva
+	HTTPLongTimeout       time.Duration // Plazo de lectura y escritura de la importación y las exportaciones CSV
+	ShutdownTimeout       time.Duration // Plazo para drenar las conexiones abiertas al apagar
+	TrustedProxies        []string      // IPs o rangos CIDR de los proxies cuyo X-Forwarded-For se acepta (vacío = ninguno)
+
+	TLSCertFile          string        // Certificado del servidor (vacío = servir HTTP sin TLS)
+	TLSKeyFile           string        // Clave privada del certificado
+	TLSMinVersion        string        // Versión mínima de TLS: 1.2 o 1.3
+	TLSCipherSuites      []string      // Suites de cifrado permitidas en TLS 1.2 (vacío = las de Go)
+	TLSReloadInterval    time.Duration // Frecuencia con la que se comprueba si el certificado cambió
+	InternalPort         string        // Puerto del listener interno para otros componentes (vacío = deshabilitado)
+	InternalClientCAFile string        // CA de los certificados de cliente exigidos en el listener interno (mTLS)
+
+	HealthCheckTimeout  time.Duration // Tiempo máximo de los checks de /readyz
+	ReadinessDrainDelay time.Duration // Espera entre marcar el servicio como no listo y cerrar el servidor
+
+	IntrospectionClients  map[string]string // Credenciales de los servicios que pueden usar la introspección (client_id -> secreto)
+	IntrospectionCacheTTL time.Duration     // Tiempo que se cachea una respuesta de introspección
+	ServiceTokenTTL       time.Duration     // Vigencia de los tokens emitidos a cuentas de servicio
+
+	OIDCIssuer         string        // URL base del servicio como proveedor OIDC (claim iss y endpoints del discovery)
+	OIDCConsentURL     string        // Página del frontend que muestra la pantalla de consentimiento
+	OIDCSigningKeyFile string        // Clave RSA en PEM para firmar los ID tokens (vacío = clave efímera)
+	OIDCAuthRequestTTL time.Duration // Tiempo que tiene el usuario para iniciar sesión y dar su consentimiento
+	OIDCCodeTTL        time.Duration // Vigencia de los códigos de autorización
+	OIDCIDTokenTTL     time.Duration // Vigencia de los ID tokens
+	OAuthPurgeInterval time.Duration // Frecuencia con la que se eliminan las solicitudes de autorización vencidas
+
+	AuditSigningKey         string        // Clave para firmar los puntos de control de auditoría (distinta de JWT_SECRET)
+	AuditCheckpointInterval time.Duration // Frecuencia con la que se firma un punto de control de auditoría
+	AuditChainInterval      time.Duration // Frecuencia con la que se encadenan los eventos de auditoría nuevos
+
+	DBQueryTimeout time.Duration // Tiempo máximo de cada operación de los stores (0 = sin límite)
+
+	DBMaxOpenConns    int           // Conexiones abiertas como máximo en el pool (0 = sin límite)
+	DBMaxIdleConns    int           // Conexiones inactivas que conserva el pool
+	DBConnMaxLifetime time.Duration // Antigüedad máxima de una conexión antes de reemplazarla (0 = sin límite)
+	DBConnMaxIdleTime time.Duration // Tiempo máximo que una conexión puede estar inactiva (0 = sin límite)
+	DBConnectTimeout  time.Duration // Tiempo que se reintenta la conexión al arrancar mientras Postgres no responde
+
+	DBReplicaURLs          []string      // Réplicas de solo lectura para las búsquedas de usuarios (vacío = todo al primario)
+	DBReplicaCheckInterval time.Duration // Cada cuánto se comprueba si las réplicas responden
+	DBReplicaStickiness    time.Duration // Tiempo que las lecturas de un usuario recién modificado van al primario
 }
 
+// buildDatabaseURL arma la URL de Postgres escapando el usuario y la contraseña, que pueden
+// contener caracteres reservados.
 func buildDatabaseURL(cfg *Config) string {
-	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-		cfg.DBUser,
-		cfg.DBPassword,
-		cfg.DBHost,
-		cfg.DBPort,
-		cfg.DBName,
-		cfg.DBSSLMode,
-		cfg.FrontendURL,
-	)
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.DBUser, cfg.DBPassword),
+		Host:     cfg.DBHost + ":" + cfg.DBPort,
+		Path:     "/" + cfg.DBName,
+		RawQuery: url.Values{"sslmode": {cfg.DBSSLMode}}.Encode(),
+	}
+	return u.String()
 }
 
 func LoadConfig() *Config {
 	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
+		slog.Info("No .env file found, using environment variables")
 	}
 
 	cfg := &Config{
@@ -53,7 +117,70 @@ func LoadConfig() *Config {
 		DBPassword:     os.Getenv("DB_PASSWORD"),
 		DBName:         os.Getenv("DB_NAME"),
 		DBSSLMode:      os.Getenv("DB_SSL_MODE"),
+		MigrationsDir:  os.Getenv("MIGRATIONS_DIR"),
 		FrontendURL:    os.Getenv("FrontendURL"),
+		LogLevel:       getString("LOG_LEVEL", "info"),
+		ServiceName:    getString("OTEL_SERVICE_NAME", "component-4"),
+		OTLPEndpoint:   os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		InvitationTTL:  getDuration("INVITATION_TTL", 72*time.Hour),
+		SMTPHost:       os.Getenv("SMTP_HOST"),
+		SMTPPort:       getString("SMTP_PORT", "587"),
+		SMTPUser:       os.Getenv("SMTP_USER"),
+		SMTPPassword:   os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:       getString("SMTP_FROM", "no-reply@colegio.edu"),
+
+		AccountDeletionGrace:  getDuration("ACCOUNT_DELETION_GRACE", 30*24*time.Hour),
+		DeletionPurgeInterval: getInterval("DELETION_PURGE_INTERVAL", time.Hour),
+
+		HTTPReadTimeout:       getDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+		HTTPReadHeaderTimeout: getDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		HTTPWriteTimeout:      getDuration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+		HTTPIdleTimeout:       getDuration("HTTP_IDLE_TIMEOUT", 120*time.Second),
+		HTTPLongTimeout:       getDuration("HTTP_LONG_TIMEOUT", 10*time.Minute),
+		ShutdownTimeout:       getDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		TrustedProxies:        getList("TRUSTED_PROXIES"),
+
+		TLSCertFile:          os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:           os.Getenv("TLS_KEY_FILE"),
+		TLSMinVersion:        getString("TLS_MIN_VERSION", "1.2"),
+		TLSCipherSuites:      getList("TLS_CIPHER_SUITES"),
+		TLSReloadInterval:    getInterval("TLS_RELOAD_INTERVAL", time.Minute),
+		InternalPort:         os.Getenv("INTERNAL_PORT"),
+		InternalClientCAFile: os.Getenv("INTERNAL_CLIENT_CA_FILE"),
+
+		HealthCheckTimeout:  getDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+		ReadinessDrainDelay: getDuration("READINESS_DRAIN_DELAY", 5*time.Second),
+
+		IntrospectionClients:  getCredentials("INTROSPECTION_CLIENTS"),
+		IntrospectionCacheTTL: getDuration("INTROSPECTION_CACHE_TTL", 10*time.Second),
+		ServiceTokenTTL:       getDuration("SERVICE_TOKEN_TTL", time.Hour),
+
+		OIDCIssuer:         strings.TrimSuffix(getString("OIDC_ISSUER", "http://localhost:8080"), "/"),
+		OIDCConsentURL:     os.Getenv("OIDC_CONSENT_URL"),
+		OIDCSigningKeyFile: os.Getenv("OIDC_SIGNING_KEY_FILE"),
+		OIDCAuthRequestTTL: getDuration("OIDC_AUTH_REQUEST_TTL", 10*time.Minute),
+		OIDCCodeTTL:        getDuration("OIDC_CODE_TTL", time.Minute),
+		OIDCIDTokenTTL:     getDuration("OIDC_ID_TOKEN_TTL", time.Hour),
+		OAuthPurgeInterval: getInterval("OAUTH_PURGE_INTERVAL", time.Hour),
+
+		AuditSigningKey:         os.Getenv("AUDIT_SIGNING_KEY"),
+		AuditCheckpointInterval: getInterval("AUDIT_CHECKPOINT_INTERVAL", time.Hour),
+		AuditChainInterval:      getInterval("AUDIT_CHAIN_INTERVAL", 5*time.Second),
+
+		DBQueryTimeout: getDuration("DB_QUERY_TIMEOUT", 5*time.Second),
+
+		DBMaxOpenConns:    getInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:    getInt("DB_MAX_IDLE_CONNS", 5),
+		DBConnMaxLifetime: getDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DBConnMaxIdleTime: getDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		DBConnectTimeout:  getDuration("DB_CONNECT_TIMEOUT", 30*time.Second),
+
+		DBReplicaURLs:          getList("DB_REPLICA_URLS"),
+		DBReplicaCheckInterval: getInterval("DB_REPLICA_CHECK_INTERVAL", 10*time.Second),
+		DBReplicaStickiness:    getDuration("DB_REPLICA_STICKINESS", 10*time.Second),
+	}
+	if cfg.OIDCConsentURL == "" {
+		cfg.OIDCConsentURL = strings.TrimSuffix(cfg.FrontendURL, "/") + "/oauth/consent"
 	}
 
 	// Construir la URL de la base de datos
@@ -61,3 +188,76 @@ func LoadConfig() *Config {
 
 	return cfg
 }
+
+// getString lee una variable de entorno, usando el valor por defecto si no existe.
+func getString(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// getList lee una lista separada por comas de una variable de entorno.
+func getList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getCredentials lee pares "id:secreto" separados por comas de una variable de entorno.
+func getCredentials(key string) map[string]string {
+	credentials := make(map[string]string)
+	for _, pair := range getList(key) {
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok || id == "" || secret == "" {
+			slog.Warn("credencial inválida en la configuración, se ignora", "key", key, "client_id", id)
+			continue
+		}
+		credentials[id] = secret
+	}
+	return credentials
+}
+
+// getInt lee un entero de una variable de entorno, usando el valor por defecto si no existe o es inválido.
+func getInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("valor inválido en la configuración, usando el valor por defecto", "key", key, "value", value, "default", def)
+		return def
+	}
+	return n
+}
+
+// getDuration lee una duración (p. ej. "72h") de una variable de entorno, usando el valor por defecto si no existe o es inválida.
+// Las duraciones negativas se consideran inválidas.
+func getDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		slog.Warn("valor inválido en la configuración, usando el valor por defecto", "key", key, "value", value, "default", def)
+		return def
+	}
+	return d
+}
+
+// getInterval lee la frecuencia de una tarea periódica. Como getDuration, pero también rechaza 0:
+// time.NewTicker entra en pánico con intervalos que no son positivos.
+func getInterval(key string, def time.Duration) time.Duration {
+	d := getDuration(key, def)
+	if d == 0 {
+		slog.Warn("valor inválido en la configuración, usando el valor por defecto", "key", key, "value", os.Getenv(key), "default", def)
+		return def
+	}
+	return d
+}
@@ -0,0 +1,91 @@
+// Package audit registra los eventos de seguridad relacionados con la autenticación.
+package audit
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventType identifica la acción auditada. Solo se auditan las acciones con efecto sobre la
+// seguridad; las consultas (si existe un email, el estado de la sesión, el inicio del login con
+// Google) no se registran.
+type EventType string
+
+const (
+	EVENT_REGISTER              EventType = "register"
+	EVENT_LOGIN                 EventType = "login"
+	EVENT_GOOGLE_LOGIN          EventType = "google_login"
+	EVENT_GOOGLE_LINK           EventType = "google_link"
+	EVENT_LOGOUT                EventType = "logout"
+	EVENT_PASSWORD_CHANGE       EventType = "password_change"
+	EVENT_INVITATION_CREATE     EventType = "invitation_create"
+	EVENT_INVITATION_REVOKE     EventType = "invitation_revoke"
+	EVENT_INVITATION_ACCEPT     EventType = "invitation_accept"
+	EVENT_PROFILE_UPDATE        EventType = "profile_update"
+	EVENT_EMAIL_CHANGE          EventType = "email_change_request"
+	EVENT_EMAIL_VERIFY          EventType = "email_change_verify"
+	EVENT_DELETION_REQUEST      EventType = "deletion_request"
+	EVENT_DELETION_CANCEL       EventType = "deletion_cancel"
+	EVENT_DATA_EXPORT           EventType = "data_export"
+	EVENT_USER_ANONYMIZE        EventType = "user_anonymize"
+	EVENT_USER_IMPORT           EventType = "user_import"
+	EVENT_USER_EXPORT           EventType = "user_export"
+	EVENT_SESSION_REVOKE        EventType = "session_revoke"
+	EVENT_SERVICE_CLIENT_CREATE EventType = "service_client_create"
+	EVENT_SERVICE_CLIENT_ROTATE EventType = "service_client_rotate"
+	EVENT_SERVICE_CLIENT_REVOKE EventType = "service_client_revoke"
+	EVENT_SERVICE_TOKEN         EventType = "service_token"
+	EVENT_OAUTH_CLIENT_CREATE   EventType = "oauth_client_create"
+	EVENT_OAUTH_CLIENT_REVOKE   EventType = "oauth_client_revoke"
+	EVENT_OAUTH_CONSENT         EventType = "oauth_consent"
+	EVENT_OAUTH_TOKEN           EventType = "oauth_token"
+)
+
+// Outcome indica si la acción auditada tuvo éxito.
+type Outcome string
+
+const (
+	OUTCOME_SUCCESS Outcome = "success"
+	OUTCOME_FAILURE Outcome = "failure"
+)
+
+// Event es un registro de auditoría. ActorID es quien realizó la acción y TargetID
+// el usuario afectado; cualquiera puede ser nil (p. ej. un login fallido de un email desconocido).
+type Event struct {
+	ID         uuid.UUID              `json:"id"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	ActorID    *uuid.UUID             `json:"actor_id,omitempty"`
+	TargetID   *uuid.UUID             `json:"target_id,omitempty"`
+	Type       EventType              `json:"event_type"`
+	IP         string                 `json:"ip"`
+	UserAgent  string                 `json:"user_agent"`
+	Outcome    Outcome                `json:"outcome"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Filter restringe la consulta de eventos. Los campos vacíos no filtran.
+type Filter struct {
+	ActorID  *uuid.UUID
+	TargetID *uuid.UUID
+	UserID   *uuid.UUID // Eventos donde el usuario es actor u objetivo
+	Types    []EventType
+	Outcome  Outcome
+	From     *time.Time
+	To       *time.Time
+	After    *Cursor // Eventos que siguen a este en el orden de la consulta (paginación por cursor)
+	Limit    int
+	Offset   int
+}
+
+// Cursor identifica el último evento de una página: con Filter.After la consulta continúa tras él.
+// A diferencia de Offset, no se desplaza si se registran eventos nuevos entre una página y la siguiente.
+type Cursor struct {
+	OccurredAt time.Time
+	ID         uuid.UUID
+}
+
+// CursorAfter devuelve el cursor que continúa la consulta tras event.
+func CursorAfter(event *Event) *Cursor {
+	return &Cursor{OccurredAt: event.OccurredAt, ID: event.ID}
+}
@@ -0,0 +1,318 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// chainLockID es la clave del advisory lock que serializa la escritura de la cadena.
+const chainLockID = 7_032_001
+
+// canonicalMetadata devuelve una codificación estable de los metadatos: la misma tanto al escribir
+// el evento como al leerlo de vuelta desde JSONB (orden de claves y formato numérico normalizados).
+func canonicalMetadata(metadata map[string]interface{}) ([]byte, error) {
+	if len(metadata) == 0 {
+		return []byte("{}"), nil
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(encoded, &normalized); err != nil {
+		return nil, err
+	}
+	return json.Marshal(normalized)
+}
+
+// hashEvent calcula el hash de un evento encadenado al hash del evento anterior.
+func hashEvent(seq int64, prevHash string, event *Event, metadata []byte) string {
+	optional := func(id *uuid.UUID) string {
+		if id == nil {
+			return ""
+		}
+		return id.String()
+	}
+	payload, _ := json.Marshal([]interface{}{
+		seq,
+		event.ID.String(),
+		event.OccurredAt.UTC().Format(time.RFC3339Nano),
+		optional(event.ActorID),
+		optional(event.TargetID),
+		string(event.Type),
+		event.IP,
+		event.UserAgent,
+		string(event.Outcome),
+		json.RawMessage(metadata),
+	})
+	sum := sha256.Sum256(append([]byte(prevHash+"\n"), payload...))
+	return hex.EncodeToString(sum[:])
+}
+
+// chainBatchSize es el máximo de eventos que Chain encadena en cada transacción.
+const chainBatchSize = 500
+
+// Chain encadena los eventos que Record guardó sin encadenar, del más antiguo al más reciente, en
+// lotes de chainBatchSize. Cada lote toma el advisory lock de la cadena, así que varias instancias
+// pueden ejecutarlo a la vez. Devuelve cuántos eventos encadenó.
+func (s *Store) Chain(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		n, err := s.chainBatch(ctx)
+		total += n
+		if err != nil || n < chainBatchSize {
+			return total, err
+		}
+	}
+}
+
+func (s *Store) chainBatch(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, chainLockID); err != nil {
+		return 0, fmt.Errorf("error locking audit chain: %w", err)
+	}
+
+	var seq int64
+	var prevHash string
+	err = tx.QueryRowContext(ctx,
+		`SELECT seq, hash FROM audit_events WHERE seq IS NOT NULL ORDER BY seq DESC LIMIT 1`,
+	).Scan(&seq, &prevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("error reading audit chain head: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+eventColumns+` FROM audit_events WHERE seq IS NULL ORDER BY occurred_at, id LIMIT $1`,
+		chainBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("error reading unchained audit events: %w", err)
+	}
+	var events []*Event
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error reading audit event: %w", err)
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error reading unchained audit events: %w", err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	for _, event := range events {
+		metadata, err := canonicalMetadata(event.Metadata)
+		if err != nil {
+			return 0, fmt.Errorf("error encoding audit metadata: %w", err)
+		}
+		seq++
+		hash := hashEvent(seq, prevHash, event, metadata)
+		_, err = tx.ExecContext(ctx,
+			`UPDATE audit_events SET seq = $1, prev_hash = $2, hash = $3 WHERE id = $4`,
+			seq, prevHash, hash, event.ID)
+		if err != nil {
+			return 0, fmt.Errorf("error chaining audit event: %w", err)
+		}
+		prevHash = hash
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return int64(len(events)), nil
+}
+
+// signCheckpoint firma un punto de control con la clave de auditoría.
+func signCheckpoint(key []byte, seq int64, hash string, createdAt time.Time) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%d:%s:%s", seq, hash, createdAt.UTC().Format(time.RFC3339Nano))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Checkpoint firma el último eslabón de la cadena si avanzó desde el punto de control anterior.
+// Devuelve false si no había eventos nuevos.
+func (s *Store) Checkpoint(ctx context.Context, key []byte) (bool, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, chainLockID); err != nil {
+		return false, fmt.Errorf("error locking audit chain: %w", err)
+	}
+
+	var seq int64
+	var hash string
+	err = tx.QueryRowContext(ctx,
+		`SELECT seq, hash FROM audit_events WHERE seq IS NOT NULL ORDER BY seq DESC LIMIT 1`,
+	).Scan(&seq, &hash)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error reading audit chain head: %w", err)
+	}
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM audit_checkpoints WHERE seq = $1)`, seq).Scan(&exists); err != nil {
+		return false, fmt.Errorf("error reading audit checkpoints: %w", err)
+	}
+	if exists {
+		return false, nil
+	}
+
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO audit_checkpoints (seq, hash, signature, created_at) VALUES ($1, $2, $3, $4)`,
+		seq, hash, signCheckpoint(key, seq, hash, createdAt), createdAt,
+	)
+	if err != nil {
+		return false, fmt.Errorf("error creating audit checkpoint: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return true, nil
+}
+
+// BrokenLink describe el primer punto en el que la cadena deja de ser válida.
+type BrokenLink struct {
+	Seq     int64     `json:"seq"`
+	EventID uuid.UUID `json:"event_id,omitempty"`
+	Reason  string    `json:"reason"`
+}
+
+// VerifyResult resume la verificación de la cadena de auditoría.
+type VerifyResult struct {
+	EventsChecked      int64       `json:"events_checked"`
+	CheckpointsChecked int         `json:"checkpoints_checked"`
+	Unchained          int64       `json:"unchained"` // Eventos que Chain aún no encadenó
+	BrokenAt           *BrokenLink `json:"broken_at,omitempty"`
+}
+
+type checkpoint struct {
+	seq       int64
+	hash      string
+	signature string
+	createdAt time.Time
+}
+
+// Verify recorre la cadena completa en orden, recalculando cada hash, y comprueba que cada punto de
+// control esté firmado con key y coincida con el eslabón correspondiente. Se detiene en el primer error.
+// Recorre toda la tabla, así que no aplica el límite por operación del store: solo rige el plazo de ctx.
+func (s *Store) Verify(ctx context.Context, key []byte) (*VerifyResult, error) {
+	result := &VerifyResult{}
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_events WHERE seq IS NULL`).Scan(&result.Unchained); err != nil {
+		return nil, fmt.Errorf("error counting unchained events: %w", err)
+	}
+
+	checkpoints, err := s.loadCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, cp := range checkpoints {
+		if !hmac.Equal([]byte(cp.signature), []byte(signCheckpoint(key, cp.seq, cp.hash, cp.createdAt))) {
+			result.BrokenAt = &BrokenLink{Seq: cp.seq, Reason: "firma del punto de control inválida"}
+			return result, nil
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT seq, prev_hash, hash, `+eventColumns+` FROM audit_events WHERE seq IS NOT NULL ORDER BY seq`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading audit chain: %w", err)
+	}
+	defer rows.Close()
+
+	var expectedSeq int64 = 1
+	prevHash := ""
+	next := 0
+	for rows.Next() {
+		var seq int64
+		var storedPrev, storedHash string
+		event, err := scanEvent(rows, &seq, &storedPrev, &storedHash)
+		if err != nil {
+			return nil, fmt.Errorf("error reading audit event: %w", err)
+		}
+
+		broken := func(reason string) (*VerifyResult, error) {
+			result.BrokenAt = &BrokenLink{Seq: seq, EventID: event.ID, Reason: reason}
+			return result, nil
+		}
+		if seq != expectedSeq {
+			result.BrokenAt = &BrokenLink{Seq: expectedSeq, Reason: "falta el evento (la secuencia salta a " + fmt.Sprint(seq) + ")"}
+			return result, nil
+		}
+		if storedPrev != prevHash {
+			return broken("prev_hash no coincide con el hash del evento anterior")
+		}
+		metadata, err := canonicalMetadata(event.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding audit metadata: %w", err)
+		}
+		if hashEvent(seq, prevHash, event, metadata) != storedHash {
+			return broken("el contenido del evento no coincide con su hash")
+		}
+		for next < len(checkpoints) && checkpoints[next].seq == seq {
+			if checkpoints[next].hash != storedHash {
+				return broken("el punto de control firmado no coincide con el evento")
+			}
+			result.CheckpointsChecked++
+			next++
+		}
+
+		result.EventsChecked++
+		prevHash = storedHash
+		expectedSeq++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading audit chain: %w", err)
+	}
+
+	if next < len(checkpoints) {
+		result.BrokenAt = &BrokenLink{Seq: checkpoints[next].seq, Reason: "el punto de control firmado apunta a un evento que ya no existe"}
+	}
+	return result, nil
+}
+
+func (s *Store) loadCheckpoints(ctx context.Context) ([]checkpoint, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT seq, hash, signature, created_at FROM audit_checkpoints ORDER BY seq`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading audit checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var checkpoints []checkpoint
+	for rows.Next() {
+		var cp checkpoint
+		if err := rows.Scan(&cp.seq, &cp.hash, &cp.signature, &cp.createdAt); err != nil {
+			return nil, fmt.Errorf("error reading audit checkpoint: %w", err)
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, rows.Err()
+}
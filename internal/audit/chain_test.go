@@ -0,0 +1,206 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"component-4/internal/migrate"
+	"component-4/migrations"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+// testDB abre TEST_DATABASE_URL en un esquema propio y desechable con las migraciones aplicadas: Verify
+// recorre la tabla completa, así que cada prueba necesita una cadena que solo ella escriba. Devuelve
+// nil si la variable no está definida.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		return nil
+	}
+	schema := "audit_test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("CREATE SCHEMA: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+	switch {
+	case !strings.Contains(dsn, "://"):
+		dsn += " search_path=" + schema
+	case strings.Contains(dsn, "?"):
+		dsn += "&search_path=" + schema
+	default:
+		dsn += "?search_path=" + schema
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := migrate.RunMigrations(db, migrations.FS); err != nil {
+		t.Fatalf("RunMigrations: %v", err)
+	}
+	return db
+}
+
+// chainedStore registra tres eventos, los encadena y firma un punto de control sobre el último.
+func chainedStore(t *testing.T, db *sql.DB, key []byte) *Store {
+	t.Helper()
+	ctx := context.Background()
+	s := NewStore(db, 5*time.Second)
+	for i, eventType := range []EventType{EVENT_REGISTER, EVENT_LOGIN, EVENT_LOGOUT} {
+		event := &Event{
+			OccurredAt: time.Now().Add(time.Duration(i) * time.Millisecond),
+			Type:       eventType,
+			IP:         "127.0.0.1",
+			UserAgent:  "test",
+			Outcome:    OUTCOME_SUCCESS,
+			Metadata:   map[string]interface{}{"intento": i + 1},
+		}
+		if err := s.Record(ctx, event); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	if n, err := s.Chain(ctx); err != nil || n != 3 {
+		t.Fatalf("Chain = %d, %v, se esperaba 3", n, err)
+	}
+	if ok, err := s.Checkpoint(ctx, key); err != nil || !ok {
+		t.Fatalf("Checkpoint = %v, %v, se esperaba true", ok, err)
+	}
+	return s
+}
+
+func TestVerifyIntactChain(t *testing.T) {
+	db := testDB(t)
+	if db == nil {
+		t.Skip("TEST_DATABASE_URL no definida")
+	}
+	key := []byte("clave-de-auditoria")
+	s := chainedStore(t, db, key)
+
+	// Un evento registrado después aún no está encadenado, pero no rompe la cadena
+	if err := s.Record(context.Background(), &Event{Type: EVENT_LOGIN, Outcome: OUTCOME_FAILURE}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	result, err := s.Verify(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if result.BrokenAt != nil {
+		t.Fatalf("cadena rota en %+v, se esperaba intacta", result.BrokenAt)
+	}
+	if result.EventsChecked != 3 || result.CheckpointsChecked != 1 || result.Unchained != 1 {
+		t.Fatalf("resultado = %+v, se esperaban 3 eventos, 1 punto de control y 1 sin encadenar", result)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper string
+		key    string
+		seq    int64
+		reason string
+	}{
+		{"contenido alterado", `UPDATE audit_events SET ip = '10.0.0.1' WHERE seq = 2`, "", 2, "no coincide con su hash"},
+		{"hash sustituido", `UPDATE audit_events SET hash = repeat('0', 64) WHERE seq = 2`, "", 2, "no coincide con su hash"},
+		{"eslabón reescrito", `UPDATE audit_events SET prev_hash = repeat('0', 64) WHERE seq = 3`, "", 3, "prev_hash"},
+		{"evento intermedio borrado", `DELETE FROM audit_events WHERE seq = 2`, "", 2, "falta el evento"},
+		{"último evento borrado", `DELETE FROM audit_events WHERE seq = 3`, "", 3, "ya no existe"},
+		{"firma con otra clave", "", "otra-clave", 3, "firma del punto de control"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testDB(t)
+			if db == nil {
+				t.Skip("TEST_DATABASE_URL no definida")
+			}
+			key := []byte("clave-de-auditoria")
+			s := chainedStore(t, db, key)
+			if tt.tamper != "" {
+				if _, err := db.Exec(tt.tamper); err != nil {
+					t.Fatalf("alterando la cadena: %v", err)
+				}
+			}
+			verifyKey := key
+			if tt.key != "" {
+				verifyKey = []byte(tt.key)
+			}
+
+			result, err := s.Verify(context.Background(), verifyKey)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if result.BrokenAt == nil {
+				t.Fatal("Verify no detectó la alteración")
+			}
+			if result.BrokenAt.Seq != tt.seq || !strings.Contains(result.BrokenAt.Reason, tt.reason) {
+				t.Fatalf("rota en %+v, se esperaba seq %d con %q", result.BrokenAt, tt.seq, tt.reason)
+			}
+		})
+	}
+}
+
+// TestHashEventCoversEveryField comprueba sin base de datos que alterar cualquier campo del evento
+// o el eslabón anterior cambia su hash, y que los metadatos leídos de JSONB producen el mismo hash.
+func TestHashEventCoversEveryField(t *testing.T) {
+	actor := uuid.New()
+	base := func() *Event {
+		return &Event{
+			ID:         uuid.MustParse("6f1c3a4e-0000-4000-8000-000000000001"),
+			OccurredAt: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+			ActorID:    &actor,
+			Type:       EVENT_LOGIN,
+			IP:         "127.0.0.1",
+			UserAgent:  "test",
+			Outcome:    OUTCOME_SUCCESS,
+			Metadata:   map[string]interface{}{"intentos": 1},
+		}
+	}
+	hash := func(seq int64, prev string, event *Event) string {
+		metadata, err := canonicalMetadata(event.Metadata)
+		if err != nil {
+			t.Fatalf("canonicalMetadata: %v", err)
+		}
+		return hashEvent(seq, prev, event, metadata)
+	}
+	original := hash(1, "", base())
+
+	// Al leerlo de Postgres, el número llega como float64
+	read := base()
+	read.Metadata = map[string]interface{}{"intentos": float64(1)}
+	if got := hash(1, "", read); got != original {
+		t.Fatal("los metadatos leídos de JSONB cambian el hash")
+	}
+
+	tests := map[string]func(e *Event) (int64, string){
+		"seq":       func(e *Event) (int64, string) { return 2, "" },
+		"prev_hash": func(e *Event) (int64, string) { return 1, "abc" },
+		"fecha":     func(e *Event) (int64, string) { e.OccurredAt = e.OccurredAt.Add(time.Microsecond); return 1, "" },
+		"actor":     func(e *Event) (int64, string) { e.ActorID = nil; return 1, "" },
+		"tipo":      func(e *Event) (int64, string) { e.Type = EVENT_LOGOUT; return 1, "" },
+		"ip":        func(e *Event) (int64, string) { e.IP = "10.0.0.1"; return 1, "" },
+		"resultado": func(e *Event) (int64, string) { e.Outcome = OUTCOME_FAILURE; return 1, "" },
+		"metadatos": func(e *Event) (int64, string) { e.Metadata["intentos"] = 2; return 1, "" },
+	}
+	for name, tamper := range tests {
+		t.Run(name, func(t *testing.T) {
+			event := base()
+			seq, prev := tamper(event)
+			if hash(seq, prev, event) == original {
+				t.Fatal("el hash no cambió")
+			}
+		})
+	}
+}
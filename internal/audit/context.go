@@ -0,0 +1,14 @@
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+// withTimeout limita la duración de una operación del store de auditoría. Con timeout <= 0 solo rige el plazo de ctx.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WriteCSV escribe los eventos como CSV con encabezado. Los metadatos se serializan como JSON.
+func WriteCSV(w io.Writer, events []*Event) error {
+	writer := csv.NewWriter(w)
+	header := []string{"id", "occurred_at", "actor_id", "target_id", "event_type", "ip", "user_agent", "outcome", "metadata"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	optional := func(id *uuid.UUID) string {
+		if id == nil {
+			return ""
+		}
+		return id.String()
+	}
+	for _, e := range events {
+		metadata := ""
+		if len(e.Metadata) > 0 {
+			encoded, err := json.Marshal(e.Metadata)
+			if err != nil {
+				return err
+			}
+			metadata = string(encoded)
+		}
+		record := []string{
+			e.ID.String(),
+			e.OccurredAt.UTC().Format(time.RFC3339Nano),
+			optional(e.ActorID),
+			optional(e.TargetID),
+			string(e.Type),
+			e.IP,
+			e.UserAgent,
+			string(e.Outcome),
+			metadata,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
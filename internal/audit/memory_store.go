@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore implementa Repository en memoria para probar los handlers sin base de datos. No
+// encadena los eventos: Checkpoint y Verify solo existen sobre Store.
+type MemoryStore struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+// NewMemoryStore crea un MemoryStore vacío.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Record guarda una copia del evento. Completa el ID y la fecha si no vienen informados.
+func (s *MemoryStore) Record(ctx context.Context, event *Event) error {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	event.OccurredAt = event.OccurredAt.UTC().Truncate(time.Microsecond)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *event
+	s.events = append(s.events, &stored)
+	return nil
+}
+
+// Query devuelve los eventos que cumplen el filtro, del más reciente al más antiguo.
+func (s *MemoryStore) Query(ctx context.Context, filter Filter) ([]*Event, error) {
+	s.mu.Lock()
+	events := []*Event{}
+	for _, event := range s.events {
+		if filter.matches(event) {
+			c := *event
+			events = append(events, &c)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].OccurredAt.Equal(events[j].OccurredAt) {
+			return events[i].OccurredAt.After(events[j].OccurredAt)
+		}
+		return events[i].ID.String() < events[j].ID.String()
+	})
+
+	limit := filter.Limit
+	if limit <= 0 || limit > MaxQueryLimit {
+		limit = MaxQueryLimit
+	}
+	if filter.Offset >= len(events) {
+		return []*Event{}, nil
+	}
+	events = events[filter.Offset:]
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// matches aplica el filtro a un evento con la misma semántica que la consulta de Store.
+func (f Filter) matches(event *Event) bool {
+	if f.ActorID != nil && !sameID(event.ActorID, *f.ActorID) {
+		return false
+	}
+	if f.TargetID != nil && !sameID(event.TargetID, *f.TargetID) {
+		return false
+	}
+	if f.UserID != nil && !sameID(event.ActorID, *f.UserID) && !sameID(event.TargetID, *f.UserID) {
+		return false
+	}
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if event.Type == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Outcome != "" && event.Outcome != f.Outcome {
+		return false
+	}
+	if f.From != nil && event.OccurredAt.Before(*f.From) {
+		return false
+	}
+	if f.To != nil && !event.OccurredAt.Before(*f.To) {
+		return false
+	}
+	if f.After != nil && !event.OccurredAt.Before(f.After.OccurredAt) &&
+		!(event.OccurredAt.Equal(f.After.OccurredAt) && event.ID.String() > f.After.ID.String()) {
+		return false
+	}
+	return true
+}
+
+func sameID(id *uuid.UUID, want uuid.UUID) bool {
+	return id != nil && *id == want
+}
@@ -0,0 +1,191 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// MaxQueryLimit es el máximo de eventos devueltos por consulta.
+const MaxQueryLimit = 1000
+
+const eventColumns = `id, occurred_at, actor_id, target_id, event_type, ip, user_agent, outcome, metadata`
+
+// Repository es el registro de auditoría del que dependen los handlers. Store lo implementa sobre
+// Postgres, con la cadena de hashes, y MemoryStore en memoria para las pruebas.
+type Repository interface {
+	Record(ctx context.Context, event *Event) error
+	Query(ctx context.Context, filter Filter) ([]*Event, error)
+}
+
+var (
+	_ Repository = (*Store)(nil)
+	_ Repository = (*MemoryStore)(nil)
+)
+
+// Store persiste y consulta los eventos de auditoría.
+type Store struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewStore crea un Store sobre la conexión dada. timeout limita la duración de cada operación
+// (0 = sin límite propio, solo el del contexto).
+func NewStore(db *sql.DB, timeout time.Duration) *Store {
+	return &Store{db: db, timeout: timeout}
+}
+
+// Record guarda un evento sin encadenar: es un único INSERT, sin el bloqueo de la cadena, para no
+// serializar las peticiones que auditan. Chain lo encadena después en segundo plano.
+// Completa el ID y la fecha si no vienen informados.
+func (s *Store) Record(ctx context.Context, event *Event) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	// Postgres guarda microsegundos; el hash debe calcularse sobre el valor tal como se leerá.
+	event.OccurredAt = event.OccurredAt.UTC().Truncate(time.Microsecond)
+	metadata, err := canonicalMetadata(event.Metadata)
+	if err != nil {
+		return fmt.Errorf("error encoding audit metadata: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO audit_events (id, occurred_at, actor_id, target_id, event_type, ip, user_agent, outcome, metadata)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		event.ID, event.OccurredAt, event.ActorID, event.TargetID, string(event.Type),
+		event.IP, event.UserAgent, string(event.Outcome), string(metadata),
+	)
+	if err != nil {
+		return fmt.Errorf("error recording audit event: %w", err)
+	}
+	return nil
+}
+
+// Query devuelve los eventos que cumplen el filtro, del más reciente al más antiguo.
+func (s *Store) Query(ctx context.Context, filter Filter) ([]*Event, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID != nil {
+		add("actor_id = $%d", *filter.ActorID)
+	}
+	if filter.TargetID != nil {
+		add("target_id = $%d", *filter.TargetID)
+	}
+	if filter.UserID != nil {
+		add("(actor_id = $%[1]d OR target_id = $%[1]d)", *filter.UserID)
+	}
+	if len(filter.Types) > 0 {
+		types := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			types[i] = string(t)
+		}
+		add("event_type = ANY($%d)", pq.Array(types))
+	}
+	if filter.Outcome != "" {
+		add("outcome = $%d", string(filter.Outcome))
+	}
+	if filter.From != nil {
+		add("occurred_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("occurred_at < $%d", *filter.To)
+	}
+	if filter.After != nil {
+		// Mismo orden que el ORDER BY: occurred_at descendente y, a igualdad, id ascendente
+		args = append(args, filter.After.OccurredAt, filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(occurred_at < $%[1]d OR (occurred_at = $%[1]d AND id > $%[2]d))", len(args)-1, len(args)))
+	}
+
+	query := `SELECT ` + eventColumns + ` FROM audit_events`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	limit := filter.Limit
+	if limit <= 0 || limit > MaxQueryLimit {
+		limit = MaxQueryLimit
+	}
+	args = append(args, limit, filter.Offset)
+	query += fmt.Sprintf(` ORDER BY occurred_at DESC, id LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*Event{}
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error reading audit event: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// QueryAll devuelve todos los eventos que cumplen el filtro, sin el tope de MaxQueryLimit: recorre
+// las páginas con un cursor hasta que no quedan más. filter.Limit y filter.Offset se ignoran.
+func QueryAll(ctx context.Context, repo Repository, filter Filter) ([]*Event, error) {
+	filter.Limit = MaxQueryLimit
+	filter.Offset = 0
+	events := []*Event{}
+	for {
+		page, err := repo.Query(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, page...)
+		if len(page) < MaxQueryLimit {
+			return events, nil
+		}
+		filter.After = CursorAfter(page[len(page)-1])
+	}
+}
+
+// scanEvent lee una fila con eventColumns; prefix recibe las columnas seleccionadas antes de ellas.
+func scanEvent(rows *sql.Rows, prefix ...interface{}) (*Event, error) {
+	event := &Event{}
+	var actorID, targetID uuid.NullUUID
+	var eventType, outcome string
+	var metadata []byte
+	dest := append(prefix, &event.ID, &event.OccurredAt, &actorID, &targetID, &eventType,
+		&event.IP, &event.UserAgent, &outcome, &metadata)
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if actorID.Valid {
+		event.ActorID = &actorID.UUID
+	}
+	if targetID.Valid {
+		event.TargetID = &targetID.UUID
+	}
+	event.Type = EventType(eventType)
+	event.Outcome = Outcome(outcome)
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &event.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return event, nil
+}
@@ -6,15 +6,22 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	// ErrNoPassword indica que el usuario se registró con OAuth y no tiene contraseña.
+	ErrNoPassword = errors.New("user registered via OAuth and has no password")
+	// ErrInvalidPassword indica que la contraseña no coincide con el hash almacenado.
+	ErrInvalidPassword = errors.New("invalid password")
+)
+
 // CheckPassword compara la contraseña proporcionada con el hash almacenado.
 func CheckPassword(user *models.User, password string) error {
 	if user.Password == nil {
-		return errors.New("user registered via OAuth and has no password")
+		return ErrNoPassword
 	}
 
 	err := bcrypt.CompareHashAndPassword([]byte(*user.Password), []byte(password))
 	if err != nil {
-		return errors.New("invalid password")
+		return ErrInvalidPassword
 	}
 	return nil
 }
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"component-4/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const emailChangePurpose = "email_change"
+
+// EmailChangeTokenExpiry es la vigencia del enlace de verificación de un nuevo email.
+const EmailChangeTokenExpiry = time.Hour
+
+// EmailChangeClaims contiene los datos firmados dentro de un token de cambio de email.
+type EmailChangeClaims struct {
+	UserID   uuid.UUID `json:"uid"`
+	OldEmail string    `json:"old_email"`
+	NewEmail string    `json:"new_email"`
+	jwt.RegisteredClaims
+}
+
+// GenerateEmailChangeToken firma un token que autoriza a cambiar el email del usuario por newEmail.
+func GenerateEmailChangeToken(user *models.User, newEmail, secret string) (string, error) {
+	claims := &EmailChangeClaims{
+		UserID:   user.ID,
+		OldEmail: user.Email,
+		NewEmail: newEmail,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   emailChangePurpose,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(EmailChangeTokenExpiry)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(purposeKey(secret, emailChangePurpose))
+}
+
+// ValidateEmailChangeToken verifica la firma y la vigencia de un token de cambio de email.
+func ValidateEmailChangeToken(tokenString, secret string) (*EmailChangeClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &EmailChangeClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return purposeKey(secret, emailChangePurpose), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*EmailChangeClaims)
+	if !ok || !token.Valid || claims.Subject != emailChangePurpose {
+		return nil, fmt.Errorf("invalid email change token")
+	}
+	return claims, nil
+}
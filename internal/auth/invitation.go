@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"time"
+
+	"component-4/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const invitationPurpose = "invitation"
+
+// InvitationClaims contiene los datos firmados dentro de un token de invitación.
+type InvitationClaims struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// InvitationID devuelve el identificador de la invitación contenido en el token.
+func (c *InvitationClaims) InvitationID() (uuid.UUID, error) {
+	return uuid.Parse(c.ID)
+}
+
+// purposeKey deriva una clave de firma distinta para cada tipo de token, de modo que
+// un token de invitación nunca pueda ser aceptado como token de sesión ni viceversa.
+func purposeKey(secret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// GenerateInvitationToken firma un token ligado al email, rol y vigencia de la invitación.
+func GenerateInvitationToken(inv *models.Invitation, secret string) (string, error) {
+	claims := &InvitationClaims{
+		Email: inv.Email,
+		Role:  string(inv.Role),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        inv.ID.String(),
+			Subject:   invitationPurpose,
+			ExpiresAt: jwt.NewNumericDate(inv.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(purposeKey(secret, invitationPurpose))
+}
+
+// ValidateInvitationToken verifica la firma y la vigencia de un token de invitación.
+func ValidateInvitationToken(tokenString, secret string) (*InvitationClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &InvitationClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return purposeKey(secret, invitationPurpose), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*InvitationClaims)
+	if !ok || !token.Valid || claims.Subject != invitationPurpose {
+		return nil, fmt.Errorf("invalid invitation token")
+	}
+	return claims, nil
+}
+
+// InvitationAcceptURL construye el enlace del frontend que el invitado debe abrir para aceptar la invitación.
+func InvitationAcceptURL(frontendURL, token string) string {
+	return fmt.Sprintf("%s/invitations/accept?token=%s", frontendURL, url.QueryEscape(token))
+}
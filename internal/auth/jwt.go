@@ -4,6 +4,7 @@ package auth
 import (
 	"fmt"
     "time"
+    "strings"
     "github.com/golang-jwt/jwt/v5"
     "github.com/google/uuid"
     "component-4/internal/models"
@@ -11,22 +12,59 @@ import (
 
 const TokenExpirySeconds = 24 * 60 * 60 // 24 horas en segundos
 
+// Tipos de sujeto de un token. Los tokens emitidos antes de existir las cuentas de servicio no
+// llevan sub_type y se tratan como de usuario.
+const (
+    SUBJECT_TYPE_USER    = "user"
+    SUBJECT_TYPE_SERVICE = "service"
+)
+
+// Claims de los tokens de acceso. En los tokens de servicio UserID es el ID de la cuenta de
+// servicio, SessionID va vacío y los permisos se expresan en Scope en lugar de Role. Los tokens
+// emitidos a una aplicación cliente por OIDC llevan su client_id en AuthorizedParty.
 type Claims struct {
-    UserID uuid.UUID `json:"sub"`
-    Email  string    `json:"email"`
-    Name   string    `json:"name"`
-    Role   string    `json:"role"`
+    UserID          uuid.UUID `json:"sub"`
+    Email           string    `json:"email,omitempty"`
+    Name            string    `json:"name"`
+    Role            string    `json:"role,omitempty"`
+    SessionID       uuid.UUID `json:"sid"`
+    SubjectType     string    `json:"sub_type,omitempty"`
+    Scope           string    `json:"scope,omitempty"`
+    AuthorizedParty string    `json:"azp,omitempty"`
     jwt.RegisteredClaims
 }
 
-func GenerateToken(user *models.User, secret string) (string, error) {
+// IsService indica si el token pertenece a una cuenta de servicio.
+func (c *Claims) IsService() bool {
+    return c.SubjectType == SUBJECT_TYPE_SERVICE
+}
+
+// IsDelegated indica si el token se emitió a una aplicación cliente en nombre del usuario.
+func (c *Claims) IsDelegated() bool {
+    return c.AuthorizedParty != ""
+}
+
+// HasScope indica si el token incluye el scope dado.
+func (c *Claims) HasScope(scope string) bool {
+    return containsScope(strings.Fields(c.Scope), scope)
+}
+
+// TokenExpiry devuelve la fecha de vencimiento de un token emitido ahora.
+func TokenExpiry() time.Time {
+    return time.Now().Add(TokenExpirySeconds * time.Second)
+}
+
+// GenerateToken firma un token para el usuario ligado a la sesión dada; vence junto con la sesión.
+func GenerateToken(user *models.User, session *models.Session, secret string) (string, error) {
     claims := &Claims{
-        UserID: user.ID,
-        Email:  user.Email,
-        Name:   user.Name,
-        Role:   string(user.Role),
+        UserID:      user.ID,
+        Email:       user.Email,
+        Name:        user.Name,
+        Role:        string(user.Role),
+        SessionID:   session.ID,
+        SubjectType: SUBJECT_TYPE_USER,
         RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExpirySeconds * time.Second)),
+            ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
             IssuedAt:  jwt.NewNumericDate(time.Now()),
         },
     }
@@ -35,6 +73,25 @@ func GenerateToken(user *models.User, secret string) (string, error) {
     return token.SignedString([]byte(secret))
 }
 
+// GenerateServiceToken firma un token para la cuenta de servicio con los scopes concedidos
+// (separados por espacios). No está ligado a una sesión; vence tras ttl.
+func GenerateServiceToken(client *models.ServiceClient, scope string, ttl time.Duration, secret string) (string, error) {
+    now := time.Now()
+    claims := &Claims{
+        UserID:      client.ID,
+        Name:        client.Name,
+        SubjectType: SUBJECT_TYPE_SERVICE,
+        Scope:       scope,
+        RegisteredClaims: jwt.RegisteredClaims{
+            ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+            IssuedAt:  jwt.NewNumericDate(now),
+        },
+    }
+
+    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+    return token.SignedString([]byte(secret))
+}
+
 // ValidateToken analiza un token y devuelve el objeto *auth.Claims completo en lugar de solo el UserID.
 func ValidateToken(tokenString, jwtSecret string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
@@ -5,8 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"component-4/config"
+	"component-4/internal/metrics"
+	"component-4/internal/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
@@ -40,27 +45,58 @@ func GetGoogleLoginURL() string {
 }
 
 // GetGoogleUserInfo intercambia el código de autorización por la información del usuario de Google.
-func GetGoogleUserInfo(code string) (*GoogleUserInfo, error) {
-	token, err := googleOAuthConfig.Exchange(context.Background(), code)
+// El intercambio y la consulta de userinfo quedan como spans hijos del span presente en ctx.
+func GetGoogleUserInfo(ctx context.Context, code string) (*GoogleUserInfo, error) {
+	token, err := exchangeGoogleCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	return fetchGoogleUserInfo(ctx, token)
+}
+
+func exchangeGoogleCode(ctx context.Context, code string) (token *oauth2.Token, err error) {
+	ctx, span := tracing.Start(ctx, "google.token_exchange", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() { tracing.End(span, err) }()
+
+	start := time.Now()
+	token, err = googleOAuthConfig.Exchange(ctx, code)
+	metrics.ObserveGoogleCall("token_exchange", start, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange token: %w", err)
 	}
+	return token, nil
+}
+
+func fetchGoogleUserInfo(ctx context.Context, token *oauth2.Token) (userInfo *GoogleUserInfo, err error) {
+	ctx, span := tracing.Start(ctx, "google.userinfo", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() { tracing.End(span, err) }()
 
-	client := googleOAuthConfig.Client(context.Background(), token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+	client := googleOAuthConfig.Client(ctx, token)
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.googleapis.com/oauth2/v2/userinfo", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build user info request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		metrics.ObserveGoogleCall("userinfo", start, fmt.Errorf("status %d", resp.StatusCode))
+	} else {
+		metrics.ObserveGoogleCall("userinfo", start, err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user info: %w", err)
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
 
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("failed to get user info, status: %s", resp.Status)
 	}
 
-	var userInfo GoogleUserInfo
-	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
+	userInfo = &GoogleUserInfo{}
+	if err := json.NewDecoder(resp.Body).Decode(userInfo); err != nil {
 		return nil, fmt.Errorf("failed to decode user info: %w", err)
 	}
 
-	return &userInfo, nil
+	return userInfo, nil
 }
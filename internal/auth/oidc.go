@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"component-4/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// PKCE_METHOD_S256 es el único método de PKCE admitido; "plain" no protege frente a la interceptación del código.
+const PKCE_METHOD_S256 = "S256"
+
+// SigningKey es la clave RSA con la que se firman los ID tokens de OIDC. Las aplicaciones cliente
+// la obtienen del JWKS publicado para verificar los tokens.
+type SigningKey struct {
+	ID      string
+	Private *rsa.PrivateKey
+}
+
+// LoadSigningKey lee una clave privada RSA en PEM (PKCS#1 o PKCS#8).
+func LoadSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("signing key is not PEM encoded")
+	}
+
+	var key *rsa.PrivateKey
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		var parsed interface{}
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err == nil {
+			var ok bool
+			if key, ok = parsed.(*rsa.PrivateKey); !ok {
+				return nil, errors.New("signing key is not an RSA key")
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing signing key: %w", err)
+	}
+	return newSigningKey(key), nil
+}
+
+// GenerateSigningKey crea una clave efímera. Los ID tokens firmados con ella dejan de poder
+// verificarse al reiniciar el servicio, por lo que solo sirve para desarrollo.
+func GenerateSigningKey() (*SigningKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("error generating signing key: %w", err)
+	}
+	return newSigningKey(key), nil
+}
+
+// newSigningKey deriva el kid del hash de la clave pública, de modo que es estable entre reinicios.
+func newSigningKey(key *rsa.PrivateKey) *SigningKey {
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	sum := sha256.Sum256(der)
+	return &SigningKey{ID: base64.RawURLEncoding.EncodeToString(sum[:12]), Private: key}
+}
+
+// JWK es una clave pública en formato JSON Web Key (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKS es el conjunto de claves públicas que se publica en jwks_uri.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS devuelve la parte pública de la clave.
+func (k *SigningKey) JWKS() JWKS {
+	pub := k.Private.PublicKey
+	return JWKS{Keys: []JWK{{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: k.ID,
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}}
+}
+
+// IDTokenClaims son los claims del ID token de OIDC. Email y nombre solo se incluyen si se concedieron
+// los scopes email y profile.
+type IDTokenClaims struct {
+	Nonce    string `json:"nonce,omitempty"`
+	AuthTime int64  `json:"auth_time,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Role     string `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// IDTokenParams reúne los datos de la autorización que van en el ID token.
+type IDTokenParams struct {
+	Issuer   string
+	ClientID string
+	Scope    string
+	Nonce    string
+	AuthTime time.Time
+	TTL      time.Duration
+}
+
+// GenerateIDToken firma con RS256 el ID token del usuario para la aplicación cliente.
+func GenerateIDToken(user *models.User, p IDTokenParams, key *SigningKey) (string, error) {
+	now := time.Now()
+	claims := &IDTokenClaims{
+		Nonce:    p.Nonce,
+		AuthTime: p.AuthTime.Unix(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.Issuer,
+			Subject:   user.ID.String(),
+			Audience:  jwt.ClaimStrings{p.ClientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(p.TTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	scopes := strings.Fields(p.Scope)
+	if containsScope(scopes, models.SCOPE_EMAIL) {
+		claims.Email = user.Email
+	}
+	if containsScope(scopes, models.SCOPE_PROFILE) {
+		claims.Name = user.Name
+		claims.Role = string(user.Role)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// GenerateDelegatedToken firma el token de acceso que recibe una aplicación cliente en nombre del
+// usuario. Va ligado a una sesión como los tokens propios, pero lleva la aplicación en azp y los
+// scopes concedidos, y solo lo acepta el endpoint userinfo.
+func GenerateDelegatedToken(user *models.User, session *models.Session, clientID, scope, secret string) (string, error) {
+	claims := &Claims{
+		UserID:          user.ID,
+		Name:            user.Name,
+		SessionID:       session.ID,
+		SubjectType:     SUBJECT_TYPE_USER,
+		Scope:           scope,
+		AuthorizedParty: clientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
+}
+
+// VerifyPKCE comprueba que el code_verifier corresponde al code_challenge S256 de la autorización.
+func VerifyPKCE(verifier, challenge, method string) bool {
+	if method != PKCE_METHOD_S256 || len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
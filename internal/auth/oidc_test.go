@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// Ejemplo del apéndice B del RFC 7636
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	const challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		method    string
+		want      bool
+	}{
+		{"S256 válido", verifier, challenge, PKCE_METHOD_S256, true},
+		{"verifier distinto", strings.Replace(verifier, "d", "e", 1), challenge, PKCE_METHOD_S256, false},
+		{"challenge distinto", verifier, strings.Replace(challenge, "E", "F", 1), PKCE_METHOD_S256, false},
+		{"método plain", challenge, challenge, "plain", false},
+		{"sin método", verifier, challenge, "", false},
+		{"verifier vacío", "", challenge, PKCE_METHOD_S256, false},
+		{"challenge vacío", verifier, "", PKCE_METHOD_S256, false},
+		{"verifier corto", verifier[:42], challenge, PKCE_METHOD_S256, false},
+		{"verifier largo", strings.Repeat("a", 129), challenge, PKCE_METHOD_S256, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyPKCE(tt.verifier, tt.challenge, tt.method); got != tt.want {
+				t.Fatalf("VerifyPKCE = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"component-4/internal/models"
+)
+
+// Export escribe el directorio de usuarios en el formato indicado ("csv" o "json").
+// Nunca incluye los hashes de contraseña.
+func Export(w io.Writer, users []*models.User, format string) error {
+	switch strings.ToLower(format) {
+	case "csv":
+		return ExportCSV(w, users)
+	case "json":
+		return ExportJSON(w, users)
+	}
+	return fmt.Errorf("unsupported format %q", format)
+}
+
+// ExportJSON escribe los usuarios como un arreglo JSON.
+func ExportJSON(w io.Writer, users []*models.User) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(users)
+}
+
+// ExportCSV escribe los usuarios como CSV con encabezado. Las celdas se neutralizan con csvCell
+// para que abrir el archivo en una hoja de cálculo no ejecute fórmulas escritas por los usuarios.
+func ExportCSV(w io.Writer, users []*models.User) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"id", "email", "name", "role", "has_password", "google_id", "created_at", "updated_at"}); err != nil {
+		return err
+	}
+	for _, u := range users {
+		googleID := ""
+		if u.GoogleID != nil {
+			googleID = *u.GoogleID
+		}
+		record := []string{
+			u.ID.String(),
+			u.Email,
+			u.Name,
+			string(u.Role),
+			fmt.Sprint(u.Password != nil),
+			googleID,
+			u.CreatedAt.Format(time.RFC3339),
+			u.UpdatedAt.Format(time.RFC3339),
+		}
+		for i := range record {
+			record[i] = csvCell(record[i])
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvCell antepone una comilla simple a los valores que una hoja de cálculo interpretaría como
+// fórmula: los que empiezan por =, +, -, @, tabulador o retorno de carro.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,43 @@
+package bulk
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"component-4/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestExportCSVNeutralizesFormulas(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Ana", "Ana"},
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+34 600", "'+34 600"},
+		{"-1+1", "'-1+1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tcmd", "'\tcmd"},
+		{"\rcmd", "'\rcmd"},
+		{"Ana=1", "Ana=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &models.User{ID: uuid.New(), Email: "ana@colegio.edu", Name: tt.name, Role: models.ROLE_ESTUDIANTE, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+			var buf bytes.Buffer
+			if err := ExportCSV(&buf, []*models.User{user}); err != nil {
+				t.Fatalf("ExportCSV: %v", err)
+			}
+			records, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatalf("leyendo el CSV: %v", err)
+			}
+			if got := records[1][2]; got != tt.want {
+				t.Fatalf("name = %q, se esperaba %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package bulk
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/mail"
+	"strings"
+
+	"component-4/config"
+	"component-4/internal/auth"
+	mailer "component-4/internal/mail"
+	"component-4/internal/models"
+	"component-4/internal/store"
+	"github.com/google/uuid"
+)
+
+// BatchSize es el número de filas que se crean por transacción.
+const BatchSize = 100
+
+// Estados posibles de una fila en el reporte de importación.
+const (
+	StatusCreated = "created"
+	StatusInvited = "invited"
+	StatusSkipped = "skipped"
+	StatusInvalid = "invalid"
+	StatusFailed  = "failed"
+)
+
+// Row es un usuario a importar. Si Password está vacío, el usuario recibe una invitación en su lugar.
+type Row struct {
+	Line     int    `json:"-"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	Password string `json:"password,omitempty"`
+}
+
+// RowResult describe lo que ocurrió (o, en modo dry-run, lo que ocurriría) con una fila.
+type RowResult struct {
+	Line   int      `json:"line"`
+	Email  string   `json:"email"`
+	Status string   `json:"status"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Report resume una importación fila por fila.
+type Report struct {
+	DryRun  bool        `json:"dry_run"`
+	Total   int         `json:"total"`
+	Created int         `json:"created"`
+	Invited int         `json:"invited"`
+	Skipped int         `json:"skipped"`
+	Invalid int         `json:"invalid"`
+	Failed  int         `json:"failed"`
+	Rows    []RowResult `json:"rows"`
+}
+
+// Options controla el comportamiento de una importación.
+type Options struct {
+	DryRun          bool       // Validar y simular sin persistir cambios
+	SendInvitations bool       // Invitar por correo a las filas sin contraseña
+	InvitedBy       *uuid.UUID // Administrador que ejecuta la importación, si lo hay
+}
+
+// ParseCSV lee usuarios de un CSV con encabezado. Las columnas email, name y role son obligatorias; password es opcional.
+func ParseCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"email", "name", "role"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing CSV column %q", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []Row
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV line %d: %w", line, err)
+		}
+		rows = append(rows, Row{
+			Line:     line,
+			Email:    field(record, "email"),
+			Name:     field(record, "name"),
+			Role:     field(record, "role"),
+			Password: field(record, "password"),
+		})
+	}
+	return rows, nil
+}
+
+// ParseJSON lee usuarios de un arreglo JSON de objetos con los campos email, name, role y password.
+func ParseJSON(r io.Reader) ([]Row, error) {
+	var rows []Row
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("error decoding JSON: %w", err)
+	}
+	for i := range rows {
+		rows[i].Line = i + 1
+		rows[i].Email = strings.TrimSpace(rows[i].Email)
+		rows[i].Name = strings.TrimSpace(rows[i].Name)
+		rows[i].Role = strings.TrimSpace(rows[i].Role)
+	}
+	return rows, nil
+}
+
+// Parse lee usuarios en el formato indicado ("csv" o "json").
+func Parse(r io.Reader, format string) ([]Row, error) {
+	switch strings.ToLower(format) {
+	case "csv":
+		return ParseCSV(r)
+	case "json":
+		return ParseJSON(r)
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// Importer crea usuarios en lote sobre los stores existentes.
+type Importer struct {
+	Users       store.UserRepository
+	Invitations store.InvitationRepository
+	Mailer      mailer.Mailer
+	Config      *config.Config
+}
+
+// validate devuelve los errores de validación de una fila.
+func validate(row Row, opts Options) []string {
+	var errs []string
+	if _, err := mail.ParseAddress(row.Email); err != nil || row.Email == "" {
+		errs = append(errs, "email inválido")
+	}
+	if row.Name == "" {
+		errs = append(errs, "el nombre es obligatorio")
+	}
+	if !models.ValidRole(models.Role(strings.ToLower(row.Role))) {
+		errs = append(errs, "rol inválido")
+	}
+	if row.Password == "" && !opts.SendInvitations {
+		errs = append(errs, "la contraseña es obligatoria si no se envían invitaciones")
+	}
+	return errs
+}
+
+// Import valida todas las filas y crea los usuarios válidos en transacciones de BatchSize filas.
+// Las filas cuyo email ya existe se omiten, por lo que importar el mismo archivo dos veces es seguro.
+func (im *Importer) Import(ctx context.Context, rows []Row, opts Options) (*Report, error) {
+	report := &Report{DryRun: opts.DryRun, Total: len(rows), Rows: make([]RowResult, len(rows))}
+
+	seen := map[string]int{}
+	var native, invited []int
+	for i, row := range rows {
+		result := &report.Rows[i]
+		result.Line = row.Line
+		result.Email = row.Email
+
+		errs := validate(row, opts)
+		key := strings.ToLower(row.Email)
+		if first, dup := seen[key]; dup && row.Email != "" {
+			errs = append(errs, fmt.Sprintf("email duplicado en la línea %d", rows[first].Line))
+		} else {
+			seen[key] = i
+		}
+		if len(errs) > 0 {
+			result.Status = StatusInvalid
+			result.Errors = errs
+			continue
+		}
+		if row.Password != "" {
+			native = append(native, i)
+		} else {
+			invited = append(invited, i)
+		}
+	}
+
+	for start := 0; start < len(native); start += BatchSize {
+		end := start + BatchSize
+		if end > len(native) {
+			end = len(native)
+		}
+		batch := make([]store.NewNativeUser, 0, end-start)
+		for _, i := range native[start:end] {
+			batch = append(batch, store.NewNativeUser{
+				Email:    rows[i].Email,
+				Name:     rows[i].Name,
+				Password: rows[i].Password,
+				Role:     models.Role(strings.ToLower(rows[i].Role)),
+			})
+		}
+
+		results, err := im.Users.CreateNativeUsers(ctx, batch, opts.DryRun)
+		if err != nil {
+			return nil, err
+		}
+		for j, res := range results {
+			result := &report.Rows[native[start+j]]
+			switch {
+			case res.Err != nil:
+				result.Status = StatusFailed
+				result.Errors = []string{res.Err.Error()}
+			case res.Created:
+				result.Status = StatusCreated
+			default:
+				result.Status = StatusSkipped
+			}
+		}
+	}
+
+	for _, i := range invited {
+		im.invite(ctx, rows[i], &report.Rows[i], opts)
+	}
+
+	for _, row := range report.Rows {
+		switch row.Status {
+		case StatusCreated:
+			report.Created++
+		case StatusInvited:
+			report.Invited++
+		case StatusSkipped:
+			report.Skipped++
+		case StatusInvalid:
+			report.Invalid++
+		case StatusFailed:
+			report.Failed++
+		}
+	}
+	return report, nil
+}
+
+// invite emite una invitación para una fila sin contraseña, salvo que el usuario o una invitación vigente ya existan.
+func (im *Importer) invite(ctx context.Context, row Row, result *RowResult, opts Options) {
+	_, err := im.Users.FindByEmail(ctx, row.Email)
+	if err == nil {
+		result.Status = StatusSkipped
+		return
+	}
+	// Solo ErrUserNotFound indica que el usuario no existe; ante cualquier otro error no se sabe,
+	// y reinvitar a un usuario existente revocaría su invitación anterior
+	if !errors.Is(err, store.ErrUserNotFound) {
+		result.Status = StatusFailed
+		result.Errors = []string{err.Error()}
+		return
+	}
+	pending, err := im.Invitations.HasPending(ctx, row.Email)
+	if err != nil {
+		result.Status = StatusFailed
+		result.Errors = []string{err.Error()}
+		return
+	}
+	if pending {
+		result.Status = StatusSkipped
+		return
+	}
+	if opts.DryRun {
+		result.Status = StatusInvited
+		return
+	}
+
+	inv, err := im.Invitations.Create(ctx, row.Email, models.Role(strings.ToLower(row.Role)), opts.InvitedBy, im.Config.InvitationTTL)
+	if err != nil {
+		result.Status = StatusFailed
+		result.Errors = []string{err.Error()}
+		return
+	}
+	result.Status = StatusInvited
+
+	token, err := auth.GenerateInvitationToken(inv, im.Config.JWTSecret)
+	if err == nil {
+		err = im.Mailer.Send(row.Email, "Invitación a la plataforma del colegio", fmt.Sprintf(
+			"Hola %s,\n\nHas sido invitado a la plataforma del colegio con el rol %s.\n"+
+				"Para activar tu cuenta abre el siguiente enlace antes del %s:\n\n%s\n",
+			row.Name, inv.Role, inv.ExpiresAt.Format("02/01/2006 15:04"),
+			auth.InvitationAcceptURL(im.Config.FrontendURL, token),
+		))
+	}
+	if err != nil {
+		slog.Error("error enviando invitación", "email", row.Email, "error", err)
+		result.Errors = []string{"invitación creada pero no se pudo enviar el correo"}
+	}
+}
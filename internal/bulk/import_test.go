@@ -0,0 +1,56 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"component-4/config"
+	"component-4/internal/mail"
+	"component-4/internal/models"
+	"component-4/internal/store"
+	"github.com/google/uuid"
+)
+
+// failingUsers simula una base de datos caída en la búsqueda por email.
+type failingUsers struct {
+	store.UserRepository
+}
+
+func (failingUsers) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return nil, errors.New("connection refused")
+}
+
+// recordingInvitations registra las invitaciones creadas sin guardarlas.
+type recordingInvitations struct {
+	store.InvitationRepository
+	created []string
+}
+
+func (r *recordingInvitations) HasPending(ctx context.Context, email string) (bool, error) {
+	return false, nil
+}
+
+func (r *recordingInvitations) Create(ctx context.Context, email string, role models.Role, invitedBy *uuid.UUID, ttl time.Duration) (*models.Invitation, error) {
+	r.created = append(r.created, email)
+	return &models.Invitation{ID: uuid.New(), Email: email, Role: role, ExpiresAt: time.Now().Add(ttl)}, nil
+}
+
+func TestImportDoesNotInviteWhenUserLookupFails(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "test-secret", InvitationTTL: time.Hour}
+	invitations := &recordingInvitations{}
+	importer := &Importer{Users: failingUsers{}, Invitations: invitations, Mailer: mail.NewMailer(cfg), Config: cfg}
+
+	rows := []Row{{Line: 2, Email: "docente@colegio.edu", Name: "Ana", Role: "profesor"}}
+	report, err := importer.Import(context.Background(), rows, Options{SendInvitations: true})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if report.Rows[0].Status != StatusFailed {
+		t.Fatalf("estado = %q, se esperaba %q", report.Rows[0].Status, StatusFailed)
+	}
+	if len(invitations.created) != 0 {
+		t.Fatalf("se crearon invitaciones: %v", invitations.created)
+	}
+}
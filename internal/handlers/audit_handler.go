@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"component-4/internal/audit"
+	"component-4/internal/logging"
+	"component-4/internal/metrics"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditHandler contiene las dependencias para la consulta del registro de auditoría.
+type AuditHandler struct {
+	Store audit.Repository
+}
+
+// NewAuditHandler crea una nueva instancia de AuditHandler.
+func NewAuditHandler(s audit.Repository) *AuditHandler {
+	return &AuditHandler{Store: s}
+}
+
+// recordAudit completa el evento con la IP, el user agent y, si no se indicó, el actor autenticado,
+// y lo guarda. Un fallo al auditar se registra en el log pero no interrumpe la petición.
+func recordAudit(store audit.Repository, r *http.Request, event audit.Event) {
+	event.IP = clientIP(r)
+	event.UserAgent = r.UserAgent()
+	if event.ActorID == nil {
+		if claims, ok := claimsFromContext(r); ok {
+			event.ActorID = &claims.UserID
+		}
+	}
+	observeLogin(event)
+	// Sin la cancelación de la petición: el evento debe quedar registrado aunque el cliente se desconecte.
+	if err := store.Record(context.WithoutCancel(r.Context()), &event); err != nil {
+		logging.FromContext(r.Context()).Error("error registrando evento de auditoría", "event_type", event.Type, "error", err)
+	}
+}
+
+// ListAuditEventsHandler godoc
+// @Summary Consultar el registro de auditoría
+// @Description Devuelve los eventos de autenticación que cumplen los filtros, del más reciente al más antiguo. Con `format=csv` se descarga un CSV.
+// @Tags admin
+// @Produce json
+// @Produce text/csv
+// @Security BearerAuth
+// @Param   actor_id query string false "ID del usuario que realizó la acción"
+// @Param   target_id query string false "ID del usuario afectado"
+// @Param   user_id query string false "ID de un usuario como actor u objetivo"
+// @Param   type query string false "Tipos de evento separados por comas (p. ej. login,google_link)"
+// @Param   outcome query string false "success o failure"
+// @Param   from query string false "Fecha inicial (RFC 3339)"
+// @Param   to query string false "Fecha final exclusiva (RFC 3339)"
+// @Param   limit query int false "Máximo de eventos (por defecto y máximo 1000)"
+// @Param   offset query int false "Eventos a omitir"
+// @Param   format query string false "json (por defecto) o csv"
+// @Success 200 {array} audit.Event "Eventos de auditoría."
+// @Failure 400 {object} ErrorResponse "Filtro inválido."
+// @Failure 403 {string} string "El usuario no es administrador."
+// @Router /api/v1/admin/audit-events [get]
+func (h *AuditHandler) ListAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Filtro inválido: " + err.Error()})
+		return
+	}
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format != "" && format != "json" && format != "csv" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Formato inválido. Debe ser csv o json."})
+		return
+	}
+
+	events, err := h.Store.Query(r.Context(), filter)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo consultar el registro de auditoría."})
+		return
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename=audit-events.csv")
+		audit.WriteCSV(w, events)
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// parseAuditFilter construye el filtro a partir de los parámetros de la consulta.
+func parseAuditFilter(r *http.Request) (audit.Filter, error) {
+	query := r.URL.Query()
+	var filter audit.Filter
+
+	for name, target := range map[string]**uuid.UUID{
+		"actor_id":  &filter.ActorID,
+		"target_id": &filter.TargetID,
+		"user_id":   &filter.UserID,
+	} {
+		if value := query.Get(name); value != "" {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				return filter, err
+			}
+			*target = &id
+		}
+	}
+	for name, target := range map[string]**time.Time{
+		"from": &filter.From,
+		"to":   &filter.To,
+	} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, err
+			}
+			*target = &t
+		}
+	}
+	for name, target := range map[string]*int{
+		"limit":  &filter.Limit,
+		"offset": &filter.Offset,
+	} {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return filter, strconv.ErrSyntax
+			}
+			*target = n
+		}
+	}
+	if value := query.Get("type"); value != "" {
+		for _, t := range strings.Split(value, ",") {
+			filter.Types = append(filter.Types, audit.EventType(strings.TrimSpace(t)))
+		}
+	}
+	filter.Outcome = audit.Outcome(query.Get("outcome"))
+	return filter, nil
+}
+
+// loginMethods asocia los eventos de inicio de sesión con el método que se reporta en las métricas.
+var loginMethods = map[audit.EventType]string{
+	audit.EVENT_LOGIN:        "native",
+	audit.EVENT_GOOGLE_LOGIN: "google",
+}
+
+// observeLogin cuenta en las métricas los intentos de inicio de sesión a partir de su evento de auditoría,
+// de modo que métricas y auditoría comparten el mismo motivo de fallo.
+func observeLogin(event audit.Event) {
+	method, ok := loginMethods[event.Type]
+	if !ok {
+		return
+	}
+	reason, _ := event.Metadata["reason"].(string)
+	metrics.ObserveLogin(method, string(event.Outcome), reason)
+}
@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"component-4/config"
+	"component-4/internal/audit"
 	"component-4/internal/auth"
+	"component-4/internal/logging"
+	"component-4/internal/metrics"
 	"component-4/internal/store"
 	"component-4/internal/models"
+	"component-4/internal/tracing"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 )
@@ -84,17 +88,33 @@ type ProtectedResponse struct {
 
 // AuthHandler contiene las dependencias para los manejadores de autenticación.
 type AuthHandler struct {
-	Store  *store.UserStore
-	Config *config.Config
+	Store    store.UserRepository
+	Sessions store.SessionRepository
+	Audit    audit.Repository
+	Config   *config.Config
 }
 
 // NewAuthHandler crea una nueva instancia de AuthHandler.
-func NewAuthHandler(s *store.UserStore, c *config.Config) *AuthHandler {
-	return &AuthHandler{Store: s, Config: c}
+func NewAuthHandler(s store.UserRepository, sessions store.SessionRepository, a audit.Repository, c *config.Config) *AuthHandler {
+	return &AuthHandler{Store: s, Sessions: sessions, Audit: a, Config: c}
+}
+
+// audit registra un evento de autenticación con el usuario afectado (si se conoce) y metadatos opcionales.
+func (h *AuthHandler) audit(r *http.Request, eventType audit.EventType, outcome audit.Outcome, target *models.User, metadata map[string]interface{}) {
+	event := audit.Event{Type: eventType, Outcome: outcome, Metadata: metadata}
+	if target != nil {
+		event.TargetID = &target.ID
+	}
+	recordAudit(h.Audit, r, event)
 }
 
 // writeJSON responde con un payload JSON y un código de estado.
 func (h *AuthHandler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	writeJSON(w, status, data)
+}
+
+// writeJSON responde con un payload JSON y un código de estado.
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if data != nil {
@@ -111,7 +131,8 @@ func (h *AuthHandler) writeJSON(w http.ResponseWriter, status int, data interfac
 // @Param   body body RegisterNativeRequest true "Registro de Usuario"
 // @Success 201 {object} MessageResponse "Usuario registrado exitosamente."
 // @Failure 400 {object} ErrorResponse "Payload de solicitud inválido."
-// @Failure 409 {object} ErrorResponse "El usuario ya existe."
+// @Failure 409 {object} ErrorResponse "El email ya está en uso."
+// @Failure 500 {object} ErrorResponse "No se pudo registrar el usuario."
 // @Router /api/v1/register [post]
 // RegisterNativeHandler maneja el registro de usuarios con email y contraseña.
 func (h *AuthHandler) RegisterNativeHandler(w http.ResponseWriter, r *http.Request) {
@@ -127,11 +148,18 @@ func (h *AuthHandler) RegisterNativeHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if _, err := h.Store.CreateNativeUser(req.Email, req.Name, req.Password, models.Role(req.Role)); err != nil {
-		h.writeJSON(w, http.StatusConflict, ErrorResponse{Error: "El usuario ya existe."})
+	user, err := h.Store.CreateNativeUser(r.Context(), req.Email, req.Name, req.Password, models.Role(req.Role))
+	if err != nil {
+		reason := "store_error"
+		if errors.Is(err, store.ErrEmailTaken) {
+			reason = "user_exists"
+		}
+		h.audit(r, audit.EVENT_REGISTER, audit.OUTCOME_FAILURE, nil, map[string]interface{}{"email": req.Email, "reason": reason})
+		writeError(w, err, "No se pudo registrar el usuario.")
 		return
 	}
 
+	h.audit(r, audit.EVENT_REGISTER, audit.OUTCOME_SUCCESS, user, map[string]interface{}{"role": user.Role})
 	h.writeJSON(w, http.StatusCreated, MessageResponse{Message: "Usuario registrado exitosamente."})
 }
 
@@ -146,7 +174,7 @@ func (h *AuthHandler) RegisterNativeHandler(w http.ResponseWriter, r *http.Reque
 // @Failure 400 {object} ErrorResponse "Payload de solicitud inválido."
 // @Failure 401 {object} ErrorResponse "Email o contraseña inválidos."
 // @Failure 401 {object} LoginOAuthNeededResponse "Usuario registrado con Google, debe usar el inicio de sesión de Google."
-// @Failure 500 {object} ErrorResponse "No se pudo generar el token."
+// @Failure 500 {object} ErrorResponse "No se pudo obtener el usuario o generar el token."
 // @Router /api/v1/login [post]
 // LoginNativeHandler maneja el inicio de sesión con email y contraseña.
 func (h *AuthHandler) LoginNativeHandler(w http.ResponseWriter, r *http.Request) {
@@ -156,28 +184,41 @@ func (h *AuthHandler) LoginNativeHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	user, err := h.Store.FindByEmail(req.Email)
-	if err != nil {
+	user, err := h.Store.FindByEmail(r.Context(), req.Email)
+	if errors.Is(err, store.ErrUserNotFound) {
+		h.audit(r, audit.EVENT_LOGIN, audit.OUTCOME_FAILURE, nil, map[string]interface{}{"email": req.Email, "reason": "unknown_email"})
 		h.writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "Email o contraseña inválidos."})
 		return
 	}
-
-	// Verificar que el usuario tenga contraseña
-	if user.Password == nil {
-		h.writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "Credenciales inválidas, falta la contraseña."})
+	if err != nil {
+		h.audit(r, audit.EVENT_LOGIN, audit.OUTCOME_FAILURE, nil, map[string]interface{}{"email": req.Email, "reason": "store_error"})
+		h.writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo obtener el usuario."})
 		return
 	}
 
-	if err := auth.CheckPassword(user, req.Password); err != nil {
+	// El coste de bcrypt domina la latencia del login: se mide en su propio span
+	_, span := tracing.Start(r.Context(), "bcrypt.compare")
+	err = auth.CheckPassword(user, req.Password)
+	span.End()
+	switch {
+	case errors.Is(err, auth.ErrNoPassword):
+		h.audit(r, audit.EVENT_LOGIN, audit.OUTCOME_FAILURE, user, map[string]interface{}{"reason": "no_password"})
+		writeError(w, err, "Email o contraseña inválidos.")
+		return
+	case err != nil:
+		// No se distingue la contraseña incorrecta del email desconocido
+		h.audit(r, audit.EVENT_LOGIN, audit.OUTCOME_FAILURE, user, map[string]interface{}{"reason": "invalid_password"})
 		h.writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "Email o contraseña inválidos."})
 		return
 	}
 
-	token, err := auth.GenerateToken(user, h.Config.JWTSecret)
+	token, err := issueToken(h.Sessions, h.Config.JWTSecret, r, user, models.AUTH_METHOD_NATIVE)
 	if err != nil {
+		h.audit(r, audit.EVENT_LOGIN, audit.OUTCOME_FAILURE, user, map[string]interface{}{"reason": "token_error"})
 		h.writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo generar el token."})
 		return
 	}
+	h.audit(r, audit.EVENT_LOGIN, audit.OUTCOME_SUCCESS, user, nil)
 
 	// Enviar el token en el header y en la respuesta JSON
 	w.Header().Set("Authorization", "Bearer "+token)
@@ -211,52 +252,54 @@ func (h *AuthHandler) GoogleLoginHandler(w http.ResponseWriter, r *http.Request)
 // @Router /api/v1/auth/google/callback [get]
 // GoogleCallbackHandler maneja el callback de Google.
 func (h *AuthHandler) GoogleCallbackHandler(w http.ResponseWriter, r *http.Request) {
-	log.Printf("GoogleCallbackHandler: Iniciando callback de Google")
-	
+	logger := logging.FromContext(r.Context())
+
 	// Obtener el código de autorización
 	code := r.URL.Query().Get("code")
 	if code == "" {
-		log.Printf("GoogleCallbackHandler: No se recibió código de autorización")
+		logger.Warn("google callback sin código de autorización")
+		h.audit(r, audit.EVENT_GOOGLE_LOGIN, audit.OUTCOME_FAILURE, nil, map[string]interface{}{"reason": "missing_code"})
 		http.Error(w, "No se recibió código de autorización", http.StatusBadRequest)
 		return
 	}
-	log.Printf("GoogleCallbackHandler: Código recibido: %s", code)
 
 	// Obtener información del usuario de Google
-	userInfo, err := auth.GetGoogleUserInfo(code)
+	userInfo, err := auth.GetGoogleUserInfo(r.Context(), code)
 	if err != nil {
-		log.Printf("GoogleCallbackHandler: Error al obtener información del usuario: %v", err)
+		logger.Error("error obteniendo información del usuario de Google", "error", err)
+		h.audit(r, audit.EVENT_GOOGLE_LOGIN, audit.OUTCOME_FAILURE, nil, map[string]interface{}{"reason": "google_error"})
 		http.Error(w, "Error al obtener información del usuario", http.StatusInternalServerError)
 		return
 	}
-	log.Printf("GoogleCallbackHandler: Información del usuario obtenida: %+v", userInfo)
 
 	// Buscar o crear el usuario en la base de datos
 	user, err := h.Store.UpsertGoogleUser(
+		r.Context(),
 		userInfo.Email,
 		userInfo.Name,
 		userInfo.ID,
 		models.ROLE_ESTUDIANTE, // Rol por defecto
 	)
 	if err != nil {
-		log.Printf("GoogleCallbackHandler: Error al buscar/crear usuario: %v", err)
+		logger.Error("error buscando/creando usuario de Google", "error", err)
+		h.audit(r, audit.EVENT_GOOGLE_LOGIN, audit.OUTCOME_FAILURE, nil, map[string]interface{}{"email": userInfo.Email, "reason": "user_error"})
 		http.Error(w, "Error al procesar usuario", http.StatusInternalServerError)
 		return
 	}
-	log.Printf("GoogleCallbackHandler: Usuario encontrado/creado: %+v", user)
+	logger.Debug("usuario de Google encontrado/creado", "user_id", user.ID)
 
 	// Generar token JWT
-	jwtToken, err := auth.GenerateToken(user, h.Config.JWTSecret)
+	jwtToken, err := issueToken(h.Sessions, h.Config.JWTSecret, r, user, models.AUTH_METHOD_GOOGLE)
 	if err != nil {
-		log.Printf("GoogleCallbackHandler: Error al generar token JWT: %v", err)
+		logger.Error("error generando token JWT", "error", err, "user_id", user.ID)
+		h.audit(r, audit.EVENT_GOOGLE_LOGIN, audit.OUTCOME_FAILURE, user, map[string]interface{}{"reason": "token_error"})
 		http.Error(w, "Error al generar token", http.StatusInternalServerError)
 		return
 	}
-	log.Printf("GoogleCallbackHandler: Token JWT generado: %s", jwtToken)
+	h.audit(r, audit.EVENT_GOOGLE_LOGIN, audit.OUTCOME_SUCCESS, user, nil)
 
 	// Redirigir al frontend con el token como parámetro de URL
 	frontendURL := fmt.Sprintf("%s/auth/callback?token=%s", h.Config.FrontendURL, jwtToken)
-	log.Printf("GoogleCallbackHandler: URL de redirección completa: %s", frontendURL)
 	
 	// Establecer headers para evitar caché
 	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, max-age=0")
@@ -287,61 +330,84 @@ func (h *AuthHandler) LinkGoogleAccountHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	user, err := h.Store.FindByEmail(req.Email)
+	user, err := h.Store.FindByEmail(r.Context(), req.Email)
 	if err != nil {
-		h.writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Usuario no encontrado."})
+		h.audit(r, audit.EVENT_GOOGLE_LINK, audit.OUTCOME_FAILURE, nil, map[string]interface{}{"email": req.Email, "reason": "unknown_email"})
+		writeError(w, err, "No se pudo obtener el usuario.")
 		return
 	}
 
 	if err := auth.CheckPassword(user, req.Password); err != nil {
-		h.writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "Contraseña inválida."})
+		h.audit(r, audit.EVENT_GOOGLE_LINK, audit.OUTCOME_FAILURE, user, map[string]interface{}{"reason": "invalid_password"})
+		writeError(w, err, "No se pudo verificar la contraseña.")
 		return
 	}
 
-	googleUserInfo, err := auth.GetGoogleUserInfo(req.GoogleAuthCode)
+	googleUserInfo, err := auth.GetGoogleUserInfo(r.Context(), req.GoogleAuthCode)
 	if err != nil {
+		h.audit(r, audit.EVENT_GOOGLE_LINK, audit.OUTCOME_FAILURE, user, map[string]interface{}{"reason": "google_error"})
 		h.writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Fallo al verificar con Google."})
 		return
 	}
 	
-	if googleUserInfo.Email != user.Email {
+	if !strings.EqualFold(googleUserInfo.Email, user.Email) {
+		h.audit(r, audit.EVENT_GOOGLE_LINK, audit.OUTCOME_FAILURE, user, map[string]interface{}{"reason": "email_mismatch"})
 		h.writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "El email de la cuenta de Google no coincide con el email de la cuenta."})
 		return
 	}
 
 	// Usar UpsertGoogleUser para vincular la cuenta
 	_, err = h.Store.UpsertGoogleUser(
+		r.Context(),
 		user.Email,
 		user.Name, // Mantener el nombre actual
 		googleUserInfo.ID,
 		user.Role, // Mantener el rol actual
 	)
 	if err != nil {
+		h.audit(r, audit.EVENT_GOOGLE_LINK, audit.OUTCOME_FAILURE, user, map[string]interface{}{"reason": "store_error"})
 		h.writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Fallo al vincular la cuenta."})
 		return
 	}
 
+	h.audit(r, audit.EVENT_GOOGLE_LINK, audit.OUTCOME_SUCCESS, user, map[string]interface{}{"google_id": googleUserInfo.ID})
 	h.writeJSON(w, http.StatusOK, MessageResponse{Message: "Cuenta de Google vinculada exitosamente."})
 }
 
 // ProtectedHandler es un ejemplo de una ruta protegida.
 func (h *AuthHandler) ProtectedHandler(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(UserIDKey).(string)
+	claims, _ := claimsFromContext(r)
 	h.writeJSON(w, http.StatusOK, ProtectedResponse{
 		Message: "Esta es una ruta protegida.",
-		UserID:  userID,
+		UserID:  claims.UserID.String(),
 	})
 }
 
 // LogoutHandler godoc
 // @Summary Cerrar sesión del usuario
-// @Description Elimina el token JWT del cliente.
+// @Description Revoca la sesión asociada al token enviado, de modo que deja de ser aceptado, y el cliente debe descartarlo.
 // @Tags auth
 // @Produce json
+// @Security BearerAuth
 // @Success 200 {object} MessageResponse "Sesión cerrada exitosamente."
 // @Router /api/v1/logout [post]
 func (h *AuthHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
-	// No es necesario hacer nada en el servidor ya que el cliente manejará el token
+	// Revocar la sesión si el token es válido; un token ausente o inválido no tiene sesión que cerrar
+	tokenString := strings.Replace(r.Header.Get("Authorization"), "Bearer ", "", 1)
+	if claims, err := auth.ValidateToken(tokenString, h.Config.JWTSecret); err == nil {
+		outcome := audit.OUTCOME_SUCCESS
+		if err := h.Sessions.Revoke(r.Context(), claims.SessionID, &claims.UserID); err != nil {
+			logging.FromContext(r.Context()).Error("error revocando la sesión", "error", err, "session_id", claims.SessionID)
+			outcome = audit.OUTCOME_FAILURE
+		}
+		recordAudit(h.Audit, r, audit.Event{
+			Type:     audit.EVENT_LOGOUT,
+			Outcome:  outcome,
+			ActorID:  &claims.UserID,
+			TargetID: &claims.UserID,
+			Metadata: map[string]interface{}{"session_id": claims.SessionID},
+		})
+	}
 	h.writeJSON(w, http.StatusOK, MessageResponse{Message: "Sesión cerrada exitosamente."})
 }
 
@@ -354,12 +420,9 @@ func (h *AuthHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} AuthStatusResponse "Información del usuario"
 // @Router /api/v1/auth-status [get]
 func (h *AuthHandler) AuthStatusHandler(w http.ResponseWriter, r *http.Request) {
-	log.Printf("AuthStatusHandler: Iniciando verificación de estado de autenticación")
-	
 	// Obtener el token del header Authorization
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
-		log.Printf("AuthStatusHandler: No se encontró header Authorization")
 		// Si no hay token, devolver usuario anónimo
 		h.writeJSON(w, http.StatusOK, AuthStatusResponse{
 			User: UserInfo{
@@ -376,7 +439,6 @@ func (h *AuthHandler) AuthStatusHandler(w http.ResponseWriter, r *http.Request)
 	// Extraer el token del header
 	tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
 	if tokenString == "" || tokenString == "undefined" {
-		log.Printf("AuthStatusHandler: Token vacío o undefined")
 		h.writeJSON(w, http.StatusOK, AuthStatusResponse{
 			User: UserInfo{
 				ID:    "",
@@ -389,13 +451,25 @@ func (h *AuthHandler) AuthStatusHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	log.Printf("AuthStatusHandler: Token encontrado en header: %s", tokenString)
-
 	// Validar y desencriptar el token
-	log.Printf("AuthStatusHandler: Intentando validar token con secret: %s", h.Config.JWTSecret)
 	claims, err := auth.ValidateToken(tokenString, h.Config.JWTSecret)
+	result := metrics.TokenInvalid
+	if err == nil && (claims.IsService() || claims.IsDelegated()) {
+		// Los tokens de servicio y los emitidos a aplicaciones cliente no representan una sesión del frontend
+		err = fmt.Errorf("not a user session token")
+	}
+	if err == nil {
+		var active bool
+		if active, err = h.Sessions.Validate(r.Context(), claims.SessionID); err != nil {
+			result = metrics.TokenError
+		} else if !active {
+			result = metrics.TokenRevoked
+			err = fmt.Errorf("session revoked")
+		}
+	}
 	if err != nil {
-		log.Printf("AuthStatusHandler: Error al validar token: %v", err)
+		metrics.ObserveTokenValidation(result)
+		logging.FromContext(r.Context()).Debug("token inválido en auth-status", "error", err)
 		// Si el token no es válido, devolver usuario anónimo
 		h.writeJSON(w, http.StatusOK, AuthStatusResponse{
 			User: UserInfo{
@@ -408,7 +482,8 @@ func (h *AuthHandler) AuthStatusHandler(w http.ResponseWriter, r *http.Request)
 		})
 		return
 	}
-	log.Printf("AuthStatusHandler: Token validado exitosamente. Claims: %+v", claims)
+
+	metrics.ObserveTokenValidation(metrics.TokenValid)
 
 	// Devolver información del usuario desde el token
 	response := AuthStatusResponse{
@@ -420,8 +495,6 @@ func (h *AuthHandler) AuthStatusHandler(w http.ResponseWriter, r *http.Request)
 		},
 		IsAuthenticated: true,
 	}
-	log.Printf("AuthStatusHandler: Enviando respuesta con usuario autenticado: %+v", response)
-	
 	h.writeJSON(w, http.StatusOK, response)
 }
 
@@ -432,7 +505,11 @@ func (h *AuthHandler) UserExists(w http.ResponseWriter, r *http.Request) {
 		h.writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "email required"})
 		return
 	}
-	user, err := h.Store.FindByEmail(email)
+	user, err := h.Store.FindByEmail(r.Context(), email)
+	if err != nil && !errors.Is(err, store.ErrUserNotFound) {
+		h.writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo comprobar el email."})
+		return
+	}
 	exists := err == nil && user != nil
 	h.writeJSON(w, http.StatusOK, map[string]bool{"exists": exists})
 }
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPMiddleware(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		trusted    bool
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"sin proxies de confianza se ignora la cabecera", false, "203.0.113.7:4000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"conexión directa de un cliente que falsea la cabecera", true, "203.0.113.7:4000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"proxy de confianza", true, "10.1.2.3:4000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"se salta la cadena de proxies de confianza", true, "10.1.2.3:4000", []string{"198.51.100.1, 192.0.2.1"}, "198.51.100.1"},
+		{"el cliente antepone una IP falsa", true, "10.1.2.3:4000", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"varias cabeceras", true, "10.1.2.3:4000", []string{"1.1.1.1", "198.51.100.1"}, "198.51.100.1"},
+		{"entrada malformada", true, "10.1.2.3:4000", []string{"198.51.100.1, basura"}, "10.1.2.3"},
+		{"proxy de confianza sin cabecera", true, "10.1.2.3:4000", nil, "10.1.2.3"},
+		{"IPv6", true, "[2001:db8::1]:4000", []string{"198.51.100.1"}, "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxies := trusted
+			if !tt.trusted {
+				proxies = nil
+			}
+			var got string
+			handler := ClientIPMiddleware(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clientIP(r)
+			}))
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Fatalf("clientIP = %q, se esperaba %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesRejectsInvalid(t *testing.T) {
+	for _, entry := range []string{"proxy.local", "10.0.0.0/33", "300.1.1.1"} {
+		if _, err := ParseTrustedProxies([]string{entry}); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) no devolvió error", entry)
+		}
+	}
+}
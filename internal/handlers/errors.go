@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"component-4/internal/auth"
+	"component-4/internal/store"
+)
+
+// errorMapping asocia un error centinela con el código HTTP y el mensaje que ve el cliente.
+type errorMapping struct {
+	err     error
+	status  int
+	message string
+}
+
+// errorMappings es la tabla central con la que writeError traduce los errores de store y auth.
+// Los errores del flujo OAuth (credenciales de cliente, códigos de autorización) no están aquí:
+// se responden con writeOAuthError en el formato de RFC 6749.
+var errorMappings = []errorMapping{
+	{store.ErrUserNotFound, http.StatusNotFound, "Usuario no encontrado."},
+	{store.ErrEmailTaken, http.StatusConflict, "El email ya está en uso."},
+	{store.ErrInvitationNotFound, http.StatusNotFound, "Invitación no encontrada."},
+	{store.ErrInvitationNotPending, http.StatusConflict, "La invitación ya no está pendiente."},
+	{store.ErrSessionNotFound, http.StatusNotFound, "Sesión no encontrada."},
+	{store.ErrServiceClientNotFound, http.StatusNotFound, "Cuenta de servicio no encontrada o ya revocada."},
+	{store.ErrOAuthClientNotFound, http.StatusNotFound, "Aplicación no encontrada o ya revocada."},
+	{store.ErrAuthorizationNotFound, http.StatusNotFound, "Solicitud de autorización no encontrada."},
+	{store.ErrAuthorizationNotPending, http.StatusConflict, "La solicitud de autorización ya no está pendiente."},
+	{auth.ErrInvalidPassword, http.StatusUnauthorized, "Contraseña inválida."},
+	{auth.ErrNoPassword, http.StatusUnauthorized, "Credenciales inválidas, falta la contraseña."},
+}
+
+// writeError responde con el código y el mensaje asociados al error en errorMappings. Los errores
+// no reconocidos se responden con 500 y fallback, sin exponer el detalle al cliente.
+func writeError(w http.ResponseWriter, err error, fallback string) {
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			writeJSON(w, m.status, ErrorResponse{Error: m.message})
+			return
+		}
+	}
+	writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: fallback})
+}
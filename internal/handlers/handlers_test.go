@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"component-4/config"
+	"component-4/internal/audit"
+	"component-4/internal/mail"
+	"component-4/internal/models"
+	"component-4/internal/store"
+	"github.com/gorilla/mux"
+)
+
+// testServer monta las rutas de autenticación, perfil y sesiones de cmd/main.go sobre los stores
+// en memoria, de modo que las pruebas recorren los handlers y AuthMiddleware sin base de datos.
+type testServer struct {
+	users    *store.MemoryUserStore
+	sessions *store.MemorySessionStore
+	events   *audit.MemoryStore
+	handler  http.Handler
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	cfg := &config.Config{JWTSecret: "test-secret", FrontendURL: "http://localhost:3000"}
+	s := &testServer{
+		users:    store.NewMemoryUserStore(),
+		sessions: store.NewMemorySessionStore(),
+		events:   audit.NewMemoryStore(),
+	}
+	authHandler := NewAuthHandler(s.users, s.sessions, s.events, cfg)
+	meHandler := NewMeHandler(s.users, s.sessions, s.events, mail.NewMailer(cfg), cfg)
+	sessionHandler := NewSessionHandler(s.sessions, s.events)
+
+	r := mux.NewRouter()
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.HandleFunc("/register", authHandler.RegisterNativeHandler).Methods("POST")
+	api.HandleFunc("/login", authHandler.LoginNativeHandler).Methods("POST")
+
+	me := api.PathPrefix("/me").Subrouter()
+	me.Use(AuthMiddleware(cfg.JWTSecret, s.sessions))
+	me.HandleFunc("", meHandler.GetMeHandler).Methods("GET")
+	me.HandleFunc("/export", meHandler.ExportMeHandler).Methods("GET")
+	me.HandleFunc("/sessions", sessionHandler.ListMySessionsHandler).Methods("GET")
+	me.HandleFunc("/sessions/{id}", sessionHandler.RevokeMySessionHandler).Methods("DELETE")
+
+	s.handler = r
+	return s
+}
+
+// do envía una petición con body JSON (si no es nil) y el token indicado (si no está vacío).
+func (s *testServer) do(t *testing.T, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("codificando el body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// login inicia sesión y devuelve el token emitido.
+func (s *testServer) login(t *testing.T, email, password string) string {
+	t.Helper()
+	rec := s.do(t, "POST", "/api/v1/login", "", LoginInput{Email: email, Password: password})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var resp TokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.Token == "" {
+		t.Fatalf("login: respuesta sin token: %v", err)
+	}
+	return resp.Token
+}
+
+// countEvents cuenta los eventos auditados del tipo y resultado dados.
+func (s *testServer) countEvents(t *testing.T, eventType audit.EventType, outcome audit.Outcome) int {
+	t.Helper()
+	events, err := s.events.Query(context.Background(), audit.Filter{Types: []audit.EventType{eventType}, Outcome: outcome})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	return len(events)
+}
+
+func TestRegisterNative(t *testing.T) {
+	s := newTestServer(t)
+	req := RegisterNativeRequest{Email: "ana@test.invalid", Password: "secreta123", Name: "Ana", Role: "estudiante"}
+
+	if rec := s.do(t, "POST", "/api/v1/register", "", req); rec.Code != http.StatusCreated {
+		t.Fatalf("registro: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if rec := s.do(t, "POST", "/api/v1/register", "", req); rec.Code != http.StatusConflict {
+		t.Fatalf("registro duplicado: status = %d, se esperaba %d", rec.Code, http.StatusConflict)
+	}
+
+	user, err := s.users.FindByEmail(context.Background(), req.Email)
+	if err != nil {
+		t.Fatalf("FindByEmail: %v", err)
+	}
+	if user.Role != models.ROLE_ESTUDIANTE {
+		t.Fatalf("rol = %s, se esperaba %s", user.Role, models.ROLE_ESTUDIANTE)
+	}
+	if n := s.countEvents(t, audit.EVENT_REGISTER, audit.OUTCOME_SUCCESS); n != 1 {
+		t.Fatalf("registros auditados con éxito = %d, se esperaba 1", n)
+	}
+	if n := s.countEvents(t, audit.EVENT_REGISTER, audit.OUTCOME_FAILURE); n != 1 {
+		t.Fatalf("registros auditados fallidos = %d, se esperaba 1", n)
+	}
+}
+
+func TestLoginNative(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	if _, err := s.users.CreateNativeUser(ctx, "ana@test.invalid", "Ana", "secreta123", models.ROLE_ESTUDIANTE); err != nil {
+		t.Fatalf("CreateNativeUser: %v", err)
+	}
+	if _, err := s.users.CreateGoogleUser(ctx, "google@test.invalid", "Luis", "google-1", models.ROLE_ESTUDIANTE); err != nil {
+		t.Fatalf("CreateGoogleUser: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		status   int
+	}{
+		{"correcto", "ana@test.invalid", "secreta123", http.StatusOK},
+		{"contraseña incorrecta", "ana@test.invalid", "otra", http.StatusUnauthorized},
+		{"email desconocido", "nadie@test.invalid", "secreta123", http.StatusUnauthorized},
+		{"usuario de Google", "google@test.invalid", "secreta123", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(t, "POST", "/api/v1/login", "", LoginInput{Email: tt.email, Password: tt.password})
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, se esperaba %d (body = %s)", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+
+	user, _ := s.users.FindByEmail(ctx, "ana@test.invalid")
+	sessions, err := s.sessions.ListActiveByUser(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("ListActiveByUser: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].AuthMethod != models.AUTH_METHOD_NATIVE {
+		t.Fatalf("sesiones tras el login = %+v, se esperaba una sesión nativa", sessions)
+	}
+}
+
+func TestGetMeRequiresActiveSession(t *testing.T) {
+	s := newTestServer(t)
+	if _, err := s.users.CreateNativeUser(context.Background(), "ana@test.invalid", "Ana", "secreta123", models.ROLE_PROFESOR); err != nil {
+		t.Fatalf("CreateNativeUser: %v", err)
+	}
+
+	if rec := s.do(t, "GET", "/api/v1/me", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("sin token: status = %d, se esperaba %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := s.do(t, "GET", "/api/v1/me", "no-es-un-jwt", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("token inválido: status = %d, se esperaba %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	token := s.login(t, "ana@test.invalid", "secreta123")
+	rec := s.do(t, "GET", "/api/v1/me", token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("con token: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var user models.User
+	if err := json.NewDecoder(rec.Body).Decode(&user); err != nil {
+		t.Fatalf("decodificando el usuario: %v", err)
+	}
+	if user.Email != "ana@test.invalid" || user.Role != models.ROLE_PROFESOR {
+		t.Fatalf("usuario = %+v", user)
+	}
+}
+
+func TestRevokeMySession(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	if _, err := s.users.CreateNativeUser(ctx, "ana@test.invalid", "Ana", "secreta123", models.ROLE_ESTUDIANTE); err != nil {
+		t.Fatalf("CreateNativeUser: %v", err)
+	}
+	if _, err := s.users.CreateNativeUser(ctx, "luis@test.invalid", "Luis", "secreta123", models.ROLE_ESTUDIANTE); err != nil {
+		t.Fatalf("CreateNativeUser: %v", err)
+	}
+	laptop := s.login(t, "ana@test.invalid", "secreta123")
+	phone := s.login(t, "ana@test.invalid", "secreta123")
+	other := s.login(t, "luis@test.invalid", "secreta123")
+
+	rec := s.do(t, "GET", "/api/v1/me/sessions", laptop, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("listar sesiones: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var sessions []SessionResponse
+	if err := json.NewDecoder(rec.Body).Decode(&sessions); err != nil {
+		t.Fatalf("decodificando las sesiones: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("sesiones = %d, se esperaban 2", len(sessions))
+	}
+	var phoneSession string
+	for _, session := range sessions {
+		if !session.Current {
+			phoneSession = session.ID.String()
+		}
+	}
+	if phoneSession == "" {
+		t.Fatal("ninguna sesión distinta de la actual")
+	}
+
+	// Otro usuario no puede revocar la sesión
+	if rec := s.do(t, "DELETE", "/api/v1/me/sessions/"+phoneSession, other, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("revocar sesión ajena: status = %d, se esperaba %d", rec.Code, http.StatusNotFound)
+	}
+
+	if rec := s.do(t, "DELETE", "/api/v1/me/sessions/"+phoneSession, laptop, nil); rec.Code != http.StatusOK {
+		t.Fatalf("revocar sesión: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if rec := s.do(t, "GET", "/api/v1/me", phone, nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("token de la sesión revocada: status = %d, se esperaba %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := s.do(t, "GET", "/api/v1/me", laptop, nil); rec.Code != http.StatusOK {
+		t.Fatalf("token de la sesión actual: status = %d, se esperaba %d", rec.Code, http.StatusOK)
+	}
+	if n := s.countEvents(t, audit.EVENT_SESSION_REVOKE, audit.OUTCOME_SUCCESS); n != 1 {
+		t.Fatalf("revocaciones auditadas = %d, se esperaba 1", n)
+	}
+}
+
+// TestExportMeIncludesAllAuditEvents comprueba que la exportación no se corta en MaxQueryLimit,
+// incluso con muchos eventos registrados en el mismo instante.
+func TestExportMeIncludesAllAuditEvents(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	user, err := s.users.CreateNativeUser(ctx, "ana@test.invalid", "Ana", "secreta123", models.ROLE_ESTUDIANTE)
+	if err != nil {
+		t.Fatalf("CreateNativeUser: %v", err)
+	}
+
+	const recorded = audit.MaxQueryLimit + 250
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < recorded; i++ {
+		event := &audit.Event{
+			Type:       audit.EVENT_PROFILE_UPDATE,
+			Outcome:    audit.OUTCOME_SUCCESS,
+			TargetID:   &user.ID,
+			OccurredAt: start.Add(time.Duration(i/10) * time.Second),
+		}
+		if err := s.events.Record(ctx, event); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	token := s.login(t, "ana@test.invalid", "secreta123")
+
+	rec := s.do(t, "GET", "/api/v1/me/export", token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("exportar: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var export DataExportResponse
+	if err := json.NewDecoder(rec.Body).Decode(&export); err != nil {
+		t.Fatalf("decodificando la exportación: %v", err)
+	}
+	// Los eventos registrados más el del login
+	if got, want := len(export.AuditEvents), recorded+1; got != want {
+		t.Fatalf("eventos exportados = %d, se esperaban %d", got, want)
+	}
+	seen := map[string]bool{}
+	for _, event := range export.AuditEvents {
+		if seen[event.ID.String()] {
+			t.Fatalf("evento %s exportado dos veces", event.ID)
+		}
+		seen[event.ID.String()] = true
+	}
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"component-4/config"
+	"component-4/internal/auth"
+	"component-4/internal/metrics"
+	"component-4/internal/models"
+	"component-4/internal/store"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SessionStatusActive es el estado de sesión de los tokens activos. Los inactivos no informan
+// de su sesión.
+const SessionStatusActive = "active"
+
+// maxIntrospectionCacheEntries limita la memoria de la caché; al alcanzarlo se vacía.
+const maxIntrospectionCacheEntries = 10000
+
+// IntrospectionResponse es la respuesta de introspección de RFC 7662. Para tokens inactivos solo se
+// informa active=false, sin revelar si el token era auténtico ni qué le ocurrió a su sesión (§2.2).
+type IntrospectionResponse struct {
+	Active        bool   `json:"active"`
+	Sub           string `json:"sub,omitempty" example:"7f1c2e4a-3b5d-4c6e-8f9a-0b1c2d3e4f5a"`
+	Role          string `json:"role,omitempty" example:"profesor"`
+	Email         string `json:"email,omitempty" example:"docente@colegio.edu"`
+	Scope         string `json:"scope,omitempty" example:"users:read"`
+	ClientID      string `json:"client_id,omitempty" example:"svc_3f9a1c2b4d5e6f70"`
+	SubjectType   string `json:"sub_type,omitempty" example:"user"`
+	Exp           int64  `json:"exp,omitempty" example:"1735689600"`
+	Iat           int64  `json:"iat,omitempty" example:"1735603200"`
+	SessionID     string `json:"sid,omitempty"`
+	SessionStatus string `json:"session_status,omitempty" example:"active"`
+	TokenType     string `json:"token_type,omitempty" example:"Bearer"`
+}
+
+// IntrospectionHandler permite a otros componentes validar tokens sin conocer el secreto de firma,
+// respetando la revocación de sesiones y el estado actual del usuario o de la cuenta de servicio.
+type IntrospectionHandler struct {
+	Users    store.UserRepository
+	Sessions store.SessionRepository
+	Clients  store.ServiceClientRepository
+	Config   *config.Config
+	cache    *introspectionCache
+	auths    *clientAuthCache
+}
+
+// NewIntrospectionHandler crea una nueva instancia de IntrospectionHandler.
+func NewIntrospectionHandler(users store.UserRepository, sessions store.SessionRepository, clients store.ServiceClientRepository, c *config.Config) *IntrospectionHandler {
+	return &IntrospectionHandler{
+		Users:    users,
+		Sessions: sessions,
+		Clients:  clients,
+		Config:   c,
+		cache:    newIntrospectionCache(c.IntrospectionCacheTTL),
+		auths:    newClientAuthCache(c.IntrospectionCacheTTL),
+	}
+}
+
+// IntrospectHandler godoc
+// @Summary Introspección de tokens (RFC 7662)
+// @Description Indica si un token sigue activo (firma válida, no vencido, sesión no revocada y usuario vigente) y devuelve sus datos. Requiere por HTTP Basic las credenciales de un servicio de INTROSPECTION_CLIENTS o de una cuenta de servicio con el scope tokens:introspect. Las respuestas y las credenciales de las cuentas de servicio se cachean unos segundos, por lo que una revocación puede tardar ese tiempo en reflejarse.
+// @Tags oauth
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param   token formData string true "Token a inspeccionar"
+// @Param   token_type_hint formData string false "Tipo de token (solo se admite access_token)"
+// @Success 200 {object} IntrospectionResponse "Resultado de la introspección."
+// @Failure 400 {object} ErrorResponse "Falta el token."
+// @Failure 401 {object} ErrorResponse "Credenciales de servicio inválidas."
+// @Router /api/v1/oauth/introspect [post]
+func (h *IntrospectionHandler) IntrospectHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticateClient(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="introspection"`)
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "Credenciales de servicio inválidas."})
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Falta el parámetro token."})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	now := time.Now()
+	key := sha256.Sum256([]byte(token))
+	if response, ok := h.cache.get(key, now); ok {
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	response, expiresAt, err := h.introspect(r, token, now)
+	if err != nil {
+		metrics.ObserveTokenValidation(metrics.TokenError)
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo validar el token."})
+		return
+	}
+	h.cache.put(key, response, now, expiresAt)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// introspect valida el token y devuelve la respuesta junto con el instante hasta el que puede cachearse.
+func (h *IntrospectionHandler) introspect(r *http.Request, token string, now time.Time) (IntrospectionResponse, time.Time, error) {
+	claims, err := auth.ValidateToken(token, h.Config.JWTSecret)
+	if err != nil {
+		metrics.ObserveTokenValidation(metrics.TokenInvalid)
+		return IntrospectionResponse{Active: false}, time.Time{}, nil
+	}
+	expiresAt := claims.ExpiresAt.Time
+	if claims.IsService() {
+		return h.introspectService(r.Context(), claims, expiresAt)
+	}
+
+	inactive := func() (IntrospectionResponse, time.Time, error) {
+		metrics.ObserveTokenValidation(metrics.TokenRevoked)
+		return IntrospectionResponse{Active: false}, expiresAt, nil
+	}
+
+	session, err := h.Sessions.FindByID(r.Context(), claims.SessionID)
+	if errors.Is(err, store.ErrSessionNotFound) {
+		return inactive()
+	}
+	if err != nil {
+		return IntrospectionResponse{}, time.Time{}, err
+	}
+	if session.RevokedAt != nil {
+		return inactive()
+	}
+	if !session.IsActive(now) {
+		return inactive()
+	}
+
+	// El rol y la vigencia se toman del usuario actual, no de los claims: un cambio de rol o una
+	// anonimización se aplica aunque el token se haya emitido antes.
+	user, err := h.Users.FindByID(r.Context(), claims.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			return inactive()
+		}
+		return IntrospectionResponse{}, time.Time{}, err
+	}
+	if user.AnonymizedAt != nil {
+		return inactive()
+	}
+
+	metrics.ObserveTokenValidation(metrics.TokenValid)
+	response := IntrospectionResponse{
+		Active:        true,
+		Sub:           user.ID.String(),
+		Role:          string(user.Role),
+		Email:         user.Email,
+		Scope:         claims.Scope,
+		ClientID:      claims.AuthorizedParty,
+		Exp:           expiresAt.Unix(),
+		SessionID:     session.ID.String(),
+		SessionStatus: SessionStatusActive,
+		SubjectType:   auth.SUBJECT_TYPE_USER,
+		TokenType:     "Bearer",
+	}
+	if claims.IssuedAt != nil {
+		response.Iat = claims.IssuedAt.Unix()
+	}
+	if session.ExpiresAt.Before(expiresAt) {
+		expiresAt = session.ExpiresAt
+	}
+	return response, expiresAt, nil
+}
+
+// introspectService resuelve un token de cuenta de servicio: sigue activo mientras la cuenta no esté revocada.
+func (h *IntrospectionHandler) introspectService(ctx context.Context, claims *auth.Claims, expiresAt time.Time) (IntrospectionResponse, time.Time, error) {
+	client, err := h.Clients.FindByID(ctx, claims.UserID)
+	if err != nil && !errors.Is(err, store.ErrServiceClientNotFound) {
+		return IntrospectionResponse{}, time.Time{}, err
+	}
+	if err != nil || !client.IsActive() {
+		metrics.ObserveTokenValidation(metrics.TokenRevoked)
+		return IntrospectionResponse{Active: false}, expiresAt, nil
+	}
+
+	metrics.ObserveTokenValidation(metrics.TokenValid)
+	response := IntrospectionResponse{
+		Active:      true,
+		Sub:         client.ID.String(),
+		Scope:       claims.Scope,
+		ClientID:    client.ClientID,
+		SubjectType: auth.SUBJECT_TYPE_SERVICE,
+		Exp:         expiresAt.Unix(),
+		TokenType:   "Bearer",
+	}
+	if claims.IssuedAt != nil {
+		response.Iat = claims.IssuedAt.Unix()
+	}
+	return response, expiresAt, nil
+}
+
+// authenticateClient verifica las credenciales HTTP Basic del servicio que llama: primero contra
+// INTROSPECTION_CLIENTS y, si no coinciden, contra las cuentas de servicio con el scope tokens:introspect.
+// Las credenciales de cuentas de servicio ya verificadas se recuerdan durante INTROSPECTION_CACHE_TTL
+// para no repetir la consulta y el bcrypt en cada llamada.
+func (h *IntrospectionHandler) authenticateClient(r *http.Request) bool {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	expected, known := h.Config.IntrospectionClients[clientID]
+	// Se compara siempre, aunque el cliente no exista, para no revelar qué IDs son válidos por tiempo de respuesta
+	match := subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) == 1
+	if known && match {
+		return true
+	}
+
+	// Basic no admite ":" en el usuario, así que la concatenación no es ambigua
+	key := sha256.Sum256([]byte(clientID + ":" + secret))
+	now := time.Now()
+	if h.auths.valid(key, now) {
+		return true
+	}
+	client, err := h.Clients.Authenticate(r.Context(), clientID, secret)
+	if err != nil || !client.HasScope(models.SCOPE_TOKENS_INTROSPECT) {
+		return false
+	}
+	h.auths.add(key, now)
+	return true
+}
+
+// clientAuthCache recuerda durante poco tiempo las credenciales de cuentas de servicio ya verificadas,
+// indexadas por el hash de client_id y secreto. Solo guarda los aciertos: una credencial incorrecta
+// siempre se comprueba contra la base de datos.
+type clientAuthCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]time.Time
+}
+
+func newClientAuthCache(ttl time.Duration) *clientAuthCache {
+	return &clientAuthCache{ttl: ttl, entries: make(map[[sha256.Size]byte]time.Time)}
+}
+
+func (c *clientAuthCache) valid(key [sha256.Size]byte, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiresAt, ok := c.entries[key]
+	if ok && !now.Before(expiresAt) {
+		delete(c.entries, key)
+		return false
+	}
+	return ok
+}
+
+func (c *clientAuthCache) add(key [sha256.Size]byte, now time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxIntrospectionCacheEntries {
+		c.entries = make(map[[sha256.Size]byte]time.Time)
+	}
+	c.entries[key] = now.Add(c.ttl)
+}
+
+type introspectionEntry struct {
+	response  IntrospectionResponse
+	expiresAt time.Time
+}
+
+// introspectionCache guarda las respuestas durante poco tiempo, indexadas por el hash del token.
+type introspectionCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]introspectionEntry
+}
+
+func newIntrospectionCache(ttl time.Duration) *introspectionCache {
+	return &introspectionCache{ttl: ttl, entries: make(map[[sha256.Size]byte]introspectionEntry)}
+}
+
+func (c *introspectionCache) get(key [sha256.Size]byte, now time.Time) (IntrospectionResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return IntrospectionResponse{}, false
+	}
+	if !now.Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return IntrospectionResponse{}, false
+	}
+	return entry.response, true
+}
+
+// put guarda la respuesta hasta now+ttl, o hasta tokenExpiry si el token vence antes.
+func (c *introspectionCache) put(key [sha256.Size]byte, response IntrospectionResponse, now, tokenExpiry time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+	expiresAt := now.Add(c.ttl)
+	if response.Active && tokenExpiry.Before(expiresAt) {
+		expiresAt = tokenExpiry
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxIntrospectionCacheEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxIntrospectionCacheEntries {
+			c.entries = make(map[[sha256.Size]byte]introspectionEntry)
+		}
+	}
+	c.entries[key] = introspectionEntry{response: response, expiresAt: expiresAt}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"component-4/config"
+	"component-4/internal/auth"
+	"component-4/internal/models"
+	"component-4/internal/store"
+	"github.com/google/uuid"
+)
+
+// countingClients es una cuenta de servicio con tokens:introspect que cuenta las veces que se
+// verifican sus credenciales. Los demás métodos de ServiceClientRepository no se usan.
+type countingClients struct {
+	store.ServiceClientRepository
+	clientID, secret string
+	calls            int
+}
+
+func (c *countingClients) Authenticate(ctx context.Context, clientID, secret string) (*models.ServiceClient, error) {
+	c.calls++
+	if clientID != c.clientID || secret != c.secret {
+		return nil, store.ErrInvalidClientCredentials
+	}
+	return &models.ServiceClient{ID: uuid.New(), ClientID: clientID, Scopes: []string{models.SCOPE_TOKENS_INTROSPECT}}, nil
+}
+
+func newTestIntrospection(users store.UserRepository, sessions store.SessionRepository, clients store.ServiceClientRepository) *IntrospectionHandler {
+	cfg := &config.Config{JWTSecret: "test-secret", IntrospectionCacheTTL: time.Minute}
+	return NewIntrospectionHandler(users, sessions, clients, cfg)
+}
+
+func introspect(h *IntrospectionHandler, clientID, secret, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/v1/oauth/introspect", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientID, secret)
+	rec := httptest.NewRecorder()
+	h.IntrospectHandler(rec, req)
+	return rec
+}
+
+func TestIntrospectionCachesClientAuthentication(t *testing.T) {
+	clients := &countingClients{clientID: "svc_notas", secret: "secreto"}
+	h := newTestIntrospection(store.NewMemoryUserStore(), store.NewMemorySessionStore(), clients)
+
+	for _, token := range []string{"uno", "dos", "tres"} {
+		if rec := introspect(h, "svc_notas", "secreto", token); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, se esperaba 200: %s", rec.Code, rec.Body)
+		}
+	}
+	if clients.calls != 1 {
+		t.Fatalf("Authenticate se llamó %d veces, se esperaba 1", clients.calls)
+	}
+
+	// Un secreto incorrecto no aprovecha la caché del correcto y siempre se verifica
+	for i := 0; i < 2; i++ {
+		if rec := introspect(h, "svc_notas", "otro", "token"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, se esperaba 401", rec.Code)
+		}
+	}
+	if clients.calls != 3 {
+		t.Fatalf("Authenticate se llamó %d veces, se esperaba 3", clients.calls)
+	}
+}
+
+// TestIntrospectionInactiveRevealsNothing comprueba que un token inactivo solo devuelve active=false,
+// tanto si su sesión fue revocada como si nunca existió (RFC 7662 §2.2).
+func TestIntrospectionInactiveRevealsNothing(t *testing.T) {
+	ctx := context.Background()
+	users := store.NewMemoryUserStore()
+	sessions := store.NewMemorySessionStore()
+	clients := &countingClients{clientID: "svc_notas", secret: "secreto"}
+	h := newTestIntrospection(users, sessions, clients)
+
+	user, err := users.CreateNativeUser(ctx, "ana@colegio.edu", "Ana", "secreta123", models.ROLE_ESTUDIANTE)
+	if err != nil {
+		t.Fatalf("CreateNativeUser: %v", err)
+	}
+	revoked, err := sessions.Create(ctx, user.ID, "test", "127.0.0.1", models.AUTH_METHOD_NATIVE, auth.TokenExpiry())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := sessions.Revoke(ctx, revoked.ID, nil); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	unknown := &models.Session{ID: uuid.New(), UserID: user.ID, ExpiresAt: auth.TokenExpiry()}
+
+	for name, session := range map[string]*models.Session{"revocada": revoked, "inexistente": unknown} {
+		t.Run(name, func(t *testing.T) {
+			token, err := auth.GenerateToken(user, session, "test-secret")
+			if err != nil {
+				t.Fatalf("GenerateToken: %v", err)
+			}
+			rec := introspect(h, "svc_notas", "secreto", token)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, se esperaba 200", rec.Code)
+			}
+			var body map[string]interface{}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decodificando la respuesta: %v", err)
+			}
+			if len(body) != 1 || body["active"] != false {
+				t.Fatalf("respuesta = %v, se esperaba solo active=false", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"component-4/config"
+	"component-4/internal/audit"
+	"component-4/internal/auth"
+	"component-4/internal/models"
+	"component-4/internal/store"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// CreateInvitationRequest representa el cuerpo de la solicitud para invitar a un miembro del personal.
+type CreateInvitationRequest struct {
+	Email string `json:"email" example:"docente@colegio.edu"`
+	Role  string `json:"role" example:"profesor"`
+}
+
+// InvitationResponse representa una invitación recién creada junto con su token firmado.
+type InvitationResponse struct {
+	Invitation *models.Invitation `json:"invitation"`
+	Token      string             `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	AcceptURL  string             `json:"accept_url" example:"http://localhost:3001/invitations/accept?token=eyJhbGci..."`
+}
+
+// AcceptInvitationRequest representa el cuerpo de la solicitud para aceptar una invitación.
+// Se debe enviar una contraseña (cuenta nativa) o un código de autorización de Google (cuenta vinculada a Google).
+type AcceptInvitationRequest struct {
+	Token          string `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	Name           string `json:"name" example:"Ana Gómez"`
+	Password       string `json:"password,omitempty" example:"password123"`
+	GoogleAuthCode string `json:"google_auth_code,omitempty" example:"4/0AY0e-g7..."`
+}
+
+// InvitationHandler contiene las dependencias para los manejadores de invitaciones.
+type InvitationHandler struct {
+	Store    store.InvitationRepository
+	Sessions store.SessionRepository
+	Audit    audit.Repository
+	Config   *config.Config
+}
+
+// NewInvitationHandler crea una nueva instancia de InvitationHandler.
+func NewInvitationHandler(s store.InvitationRepository, sessions store.SessionRepository, a audit.Repository, c *config.Config) *InvitationHandler {
+	return &InvitationHandler{Store: s, Sessions: sessions, Audit: a, Config: c}
+}
+
+// CreateInvitationHandler godoc
+// @Summary Crear una invitación para un miembro del personal
+// @Description Crea una invitación firmada y con vencimiento, ligada al email y al rol indicados. Reinvitar a un email revoca la invitación pendiente anterior.
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Param   body body CreateInvitationRequest true "Datos de la invitación"
+// @Success 201 {object} InvitationResponse "Invitación creada."
+// @Failure 400 {object} ErrorResponse "Payload de solicitud inválido o rol inválido."
+// @Failure 403 {string} string "El usuario no es administrador."
+// @Failure 409 {object} ErrorResponse "Ya existe un usuario con este email."
+// @Failure 500 {object} ErrorResponse "No se pudo crear la invitación."
+// @Router /api/v1/admin/invitations [post]
+func (h *InvitationHandler) CreateInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Payload de solicitud inválido."})
+		return
+	}
+
+	role := models.Role(strings.ToLower(req.Role))
+	if !models.ValidRole(role) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Rol inválido. Debe ser ADMINISTRADOR, PROFESOR o ESTUDIANTE."})
+		return
+	}
+
+	claims, _ := claimsFromContext(r)
+	inv, err := h.Store.Create(r.Context(), req.Email, role, &claims.UserID, h.Config.InvitationTTL)
+	if err != nil {
+		writeError(w, err, "No se pudo crear la invitación.")
+		return
+	}
+
+	token, err := auth.GenerateInvitationToken(inv, h.Config.JWTSecret)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo generar el token de invitación."})
+		return
+	}
+	recordAudit(h.Audit, r, audit.Event{
+		Type:     audit.EVENT_INVITATION_CREATE,
+		Outcome:  audit.OUTCOME_SUCCESS,
+		Metadata: map[string]interface{}{"invitation_id": inv.ID, "email": inv.Email, "role": inv.Role},
+	})
+
+	writeJSON(w, http.StatusCreated, InvitationResponse{
+		Invitation: inv,
+		Token:      token,
+		AcceptURL:  auth.InvitationAcceptURL(h.Config.FrontendURL, token),
+	})
+}
+
+// ListInvitationsHandler godoc
+// @Summary Listar invitaciones pendientes
+// @Description Devuelve las invitaciones que aún no han sido aceptadas, revocadas ni han expirado.
+// @Tags admin
+// @Produce  json
+// @Security BearerAuth
+// @Success 200 {array} models.Invitation "Invitaciones pendientes."
+// @Failure 403 {string} string "El usuario no es administrador."
+// @Failure 500 {object} ErrorResponse "No se pudieron listar las invitaciones."
+// @Router /api/v1/admin/invitations [get]
+func (h *InvitationHandler) ListInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	invitations, err := h.Store.ListPending(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudieron listar las invitaciones."})
+		return
+	}
+	writeJSON(w, http.StatusOK, invitations)
+}
+
+// RevokeInvitationHandler godoc
+// @Summary Revocar una invitación pendiente
+// @Description Invalida una invitación para que su token ya no pueda ser aceptado.
+// @Tags admin
+// @Produce  json
+// @Security BearerAuth
+// @Param   id path string true "ID de la invitación"
+// @Success 200 {object} MessageResponse "Invitación revocada."
+// @Failure 400 {object} ErrorResponse "ID inválido."
+// @Failure 404 {object} ErrorResponse "Invitación no encontrada."
+// @Failure 409 {object} ErrorResponse "La invitación ya no está pendiente."
+// @Router /api/v1/admin/invitations/{id} [delete]
+func (h *InvitationHandler) RevokeInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID inválido."})
+		return
+	}
+
+	if err := h.Store.Revoke(r.Context(), id); err != nil {
+		writeError(w, err, "No se pudo revocar la invitación.")
+		return
+	}
+
+	recordAudit(h.Audit, r, audit.Event{
+		Type:     audit.EVENT_INVITATION_REVOKE,
+		Outcome:  audit.OUTCOME_SUCCESS,
+		Metadata: map[string]interface{}{"invitation_id": id},
+	})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Invitación revocada."})
+}
+
+// AcceptInvitationHandler godoc
+// @Summary Aceptar una invitación
+// @Description Crea la cuenta del invitado con el rol preasignado. Con `password` se crea una cuenta nativa; con `google_auth_code` se crea una cuenta vinculada a Google cuyo email debe coincidir con el de la invitación. Devuelve un token JWT.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   body body AcceptInvitationRequest true "Aceptación de la invitación"
+// @Success 201 {object} TokenResponse "Cuenta creada, token devuelto."
+// @Failure 400 {object} ErrorResponse "Payload inválido, token inválido o el email de Google no coincide."
+// @Failure 404 {object} ErrorResponse "Invitación no encontrada."
+// @Failure 409 {object} ErrorResponse "La invitación ya no está pendiente o el usuario ya existe."
+// @Failure 500 {object} ErrorResponse "Fallo al verificar con Google o al crear la cuenta."
+// @Router /api/v1/invitations/accept [post]
+func (h *InvitationHandler) AcceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var req AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Payload de solicitud inválido."})
+		return
+	}
+	if (req.Password == "") == (req.GoogleAuthCode == "") {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Debe enviar una contraseña o un código de Google."})
+		return
+	}
+
+	claims, err := auth.ValidateInvitationToken(req.Token, h.Config.JWTSecret)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Token de invitación inválido o expirado."})
+		return
+	}
+	id, err := claims.InvitationID()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Token de invitación inválido o expirado."})
+		return
+	}
+
+	// El token debe corresponder al email y rol registrados; las invitaciones anteriores a la
+	// normalización de emails pueden llevar el email con mayúsculas
+	inv, err := h.Store.FindByID(r.Context(), id)
+	if err != nil {
+		h.writeAcceptError(w, err)
+		return
+	}
+	if !strings.EqualFold(inv.Email, claims.Email) || string(inv.Role) != claims.Role {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Token de invitación inválido o expirado."})
+		return
+	}
+
+	var user *models.User
+	if req.GoogleAuthCode != "" {
+		googleUserInfo, err := auth.GetGoogleUserInfo(r.Context(), req.GoogleAuthCode)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Fallo al verificar con Google."})
+			return
+		}
+		// Google puede devolver el email con otras mayúsculas que las usadas al invitar
+		if !strings.EqualFold(googleUserInfo.Email, inv.Email) {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "El email de la cuenta de Google no coincide con el de la invitación."})
+			return
+		}
+		name := req.Name
+		if name == "" {
+			name = googleUserInfo.Name
+		}
+		user, err = h.Store.AcceptGoogle(r.Context(), id, name, googleUserInfo.ID)
+		if err != nil {
+			h.writeAcceptError(w, err)
+			return
+		}
+	} else {
+		if req.Name == "" {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "El nombre es obligatorio."})
+			return
+		}
+		user, err = h.Store.AcceptNative(r.Context(), id, req.Name, req.Password)
+		if err != nil {
+			h.writeAcceptError(w, err)
+			return
+		}
+	}
+
+	recordAudit(h.Audit, r, audit.Event{
+		Type:     audit.EVENT_INVITATION_ACCEPT,
+		Outcome:  audit.OUTCOME_SUCCESS,
+		ActorID:  &user.ID,
+		TargetID: &user.ID,
+		Metadata: map[string]interface{}{"invitation_id": id, "role": user.Role, "google": user.GoogleID != nil},
+	})
+
+	token, err := issueToken(h.Sessions, h.Config.JWTSecret, r, user, models.AUTH_METHOD_INVITATION)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo generar el token."})
+		return
+	}
+
+	w.Header().Set("Authorization", "Bearer "+token)
+	writeJSON(w, http.StatusCreated, TokenResponse{Token: token})
+}
+
+// writeAcceptError traduce los errores del store al aceptar una invitación.
+func (h *InvitationHandler) writeAcceptError(w http.ResponseWriter, err error) {
+	writeError(w, err, "No se pudo crear la cuenta.")
+}
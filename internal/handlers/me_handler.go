@@ -0,0 +1,395 @@
+package handlers
+
+import (
+	"component-4/config"
+	"component-4/internal/audit"
+	"component-4/internal/auth"
+	"component-4/internal/logging"
+	"component-4/internal/mail"
+	"component-4/internal/models"
+	"component-4/internal/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// UpdateMeRequest representa los campos editables del perfil. Los campos omitidos no se modifican
+// y las preferencias se fusionan con las existentes.
+type UpdateMeRequest struct {
+	Name        *string         `json:"name,omitempty" example:"Juan Pérez"`
+	Preferences json.RawMessage `json:"preferences,omitempty" swaggertype:"object"`
+}
+
+// ChangeEmailRequest representa la solicitud de cambio de email.
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" example:"nuevo@example.com"`
+	Password string `json:"password" example:"password123"`
+}
+
+// VerifyEmailRequest representa la confirmación de un cambio de email.
+type VerifyEmailRequest struct {
+	Token string `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+}
+
+// DeleteAccountRequest representa la reconfirmación de identidad para eliminar la cuenta.
+// Las cuentas con contraseña deben enviarla; las cuentas solo de Google deben enviar un código de autorización de Google.
+type DeleteAccountRequest struct {
+	Password       string `json:"password,omitempty" example:"password123"`
+	GoogleAuthCode string `json:"google_auth_code,omitempty" example:"4/0AY0e-g7..."`
+}
+
+// IdentityExport describe un método de inicio de sesión asociado a la cuenta.
+type IdentityExport struct {
+	Provider string `json:"provider" example:"google"`
+	Subject  string `json:"subject,omitempty" example:"104729384756"`
+}
+
+// DataExportResponse es el archivo con todos los datos personales del usuario.
+type DataExportResponse struct {
+	ExportedAt  time.Time         `json:"exported_at"`
+	User        *models.User      `json:"user"`
+	Identities  []IdentityExport  `json:"identities"`
+	Sessions    []*models.Session `json:"sessions"`
+	AuditEvents []*audit.Event    `json:"audit_events"`
+}
+
+// MeHandler contiene las dependencias para los manejadores de autoservicio del perfil.
+type MeHandler struct {
+	Store    store.UserRepository
+	Sessions store.SessionRepository
+	Audit    audit.Repository
+	Mailer   mail.Mailer
+	Config   *config.Config
+}
+
+// NewMeHandler crea una nueva instancia de MeHandler.
+func NewMeHandler(s store.UserRepository, sessions store.SessionRepository, a audit.Repository, m mail.Mailer, c *config.Config) *MeHandler {
+	return &MeHandler{Store: s, Sessions: sessions, Audit: a, Mailer: m, Config: c}
+}
+
+// audit registra un evento de autoservicio cuyo actor y objetivo es el usuario autenticado.
+func (h *MeHandler) audit(r *http.Request, eventType audit.EventType, outcome audit.Outcome, metadata map[string]interface{}) {
+	event := audit.Event{Type: eventType, Outcome: outcome, Metadata: metadata}
+	if claims, ok := claimsFromContext(r); ok {
+		event.TargetID = &claims.UserID
+	}
+	recordAudit(h.Audit, r, event)
+}
+
+// currentUser carga el usuario autenticado desde la base de datos.
+func (h *MeHandler) currentUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	claims, ok := claimsFromContext(r)
+	if !ok {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return nil, false
+	}
+	user, err := h.Store.FindByID(r.Context(), claims.UserID)
+	if err != nil {
+		writeError(w, err, "No se pudo obtener el usuario.")
+		return nil, false
+	}
+	return user, true
+}
+
+// GetMeHandler godoc
+// @Summary Obtener el perfil del usuario autenticado
+// @Description Devuelve el registro completo del usuario autenticado, sin secretos.
+// @Tags me
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.User "Perfil del usuario."
+// @Failure 401 {string} string "Token inválido."
+// @Failure 404 {object} ErrorResponse "Usuario no encontrado."
+// @Router /api/v1/me [get]
+func (h *MeHandler) GetMeHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// UpdateMeHandler godoc
+// @Summary Actualizar el perfil del usuario autenticado
+// @Description Actualiza el nombre y fusiona las preferencias enviadas con las existentes.
+// @Tags me
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param   body body UpdateMeRequest true "Campos a actualizar"
+// @Success 200 {object} models.User "Perfil actualizado."
+// @Failure 400 {object} ErrorResponse "Payload de solicitud inválido."
+// @Failure 401 {string} string "Token inválido."
+// @Failure 404 {object} ErrorResponse "Usuario no encontrado."
+// @Router /api/v1/me [patch]
+func (h *MeHandler) UpdateMeHandler(w http.ResponseWriter, r *http.Request) {
+	var req UpdateMeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Payload de solicitud inválido."})
+		return
+	}
+	if req.Name != nil {
+		trimmed := strings.TrimSpace(*req.Name)
+		if trimmed == "" {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "El nombre no puede estar vacío."})
+			return
+		}
+		req.Name = &trimmed
+	}
+	if len(req.Preferences) > 0 {
+		var prefs map[string]interface{}
+		if err := json.Unmarshal(req.Preferences, &prefs); err != nil || prefs == nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Las preferencias deben ser un objeto JSON."})
+			return
+		}
+	}
+
+	claims, _ := claimsFromContext(r)
+	user, err := h.Store.UpdateProfile(r.Context(), claims.UserID, req.Name, req.Preferences)
+	if err != nil {
+		writeError(w, err, "No se pudo actualizar el perfil.")
+		return
+	}
+	h.audit(r, audit.EVENT_PROFILE_UPDATE, audit.OUTCOME_SUCCESS, map[string]interface{}{"name_changed": req.Name != nil, "preferences_changed": len(req.Preferences) > 0})
+	writeJSON(w, http.StatusOK, user)
+}
+
+// ChangeEmailHandler godoc
+// @Summary Solicitar el cambio de email
+// @Description Envía un enlace de verificación a la nueva dirección. El email no cambia hasta que se confirme el enlace. Requiere la contraseña actual. Las cuentas vinculadas a Google no pueden cambiar su email.
+// @Tags me
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param   body body ChangeEmailRequest true "Nuevo email y contraseña actual"
+// @Success 202 {object} MessageResponse "Enlace de verificación enviado."
+// @Failure 400 {object} ErrorResponse "Email inválido o cuenta vinculada a Google."
+// @Failure 401 {object} ErrorResponse "Contraseña inválida."
+// @Failure 409 {object} ErrorResponse "El email ya está en uso."
+// @Router /api/v1/me/email [post]
+func (h *MeHandler) ChangeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Payload de solicitud inválido."})
+		return
+	}
+	req.NewEmail = strings.TrimSpace(req.NewEmail)
+	if _, err := netmail.ParseAddress(req.NewEmail); err != nil || req.NewEmail == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Email inválido."})
+		return
+	}
+
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if user.GoogleID != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Las cuentas vinculadas a Google no pueden cambiar su email."})
+		return
+	}
+	if err := auth.CheckPassword(user, req.Password); err != nil {
+		h.audit(r, audit.EVENT_EMAIL_CHANGE, audit.OUTCOME_FAILURE, map[string]interface{}{"reason": "invalid_password"})
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "Contraseña inválida."})
+		return
+	}
+	if strings.EqualFold(req.NewEmail, user.Email) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "El nuevo email es igual al actual."})
+		return
+	}
+	if _, err := h.Store.FindByEmail(r.Context(), req.NewEmail); err == nil {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "El email ya está en uso."})
+		return
+	}
+
+	token, err := auth.GenerateEmailChangeToken(user, req.NewEmail, h.Config.JWTSecret)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo generar el enlace de verificación."})
+		return
+	}
+	link := fmt.Sprintf("%s/me/email/verify?token=%s", h.Config.FrontendURL, url.QueryEscape(token))
+	err = h.Mailer.Send(req.NewEmail, "Confirma tu nuevo email", fmt.Sprintf(
+		"Hola %s,\n\nPara confirmar que quieres usar esta dirección en tu cuenta abre el siguiente enlace en la próxima hora:\n\n%s\n\n"+
+			"Si no solicitaste este cambio, ignora este mensaje.\n",
+		user.Name, link,
+	))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("error enviando verificación de email", "error", err, "user_id", user.ID)
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo enviar el enlace de verificación."})
+		return
+	}
+
+	h.audit(r, audit.EVENT_EMAIL_CHANGE, audit.OUTCOME_SUCCESS, map[string]interface{}{"new_email": req.NewEmail})
+	writeJSON(w, http.StatusAccepted, MessageResponse{Message: "Enlace de verificación enviado al nuevo email."})
+}
+
+// VerifyEmailHandler godoc
+// @Summary Confirmar el cambio de email
+// @Description Aplica el cambio de email usando el token recibido en la nueva dirección. El token debe pertenecer al usuario autenticado.
+// @Tags me
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param   body body VerifyEmailRequest true "Token de verificación"
+// @Success 200 {object} models.User "Email actualizado."
+// @Failure 400 {object} ErrorResponse "Token inválido, expirado o ya utilizado."
+// @Failure 409 {object} ErrorResponse "El email ya está en uso."
+// @Router /api/v1/me/email/verify [post]
+func (h *MeHandler) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Payload de solicitud inválido."})
+		return
+	}
+
+	changeClaims, err := auth.ValidateEmailChangeToken(req.Token, h.Config.JWTSecret)
+	claims, _ := claimsFromContext(r)
+	if err != nil || changeClaims.UserID != claims.UserID {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Token de verificación inválido o expirado."})
+		return
+	}
+
+	user, err := h.Store.UpdateEmail(r.Context(), changeClaims.UserID, changeClaims.OldEmail, changeClaims.NewEmail)
+	if err != nil {
+		// Sin coincidencia con el email anterior el token ya se consumió: no es un 404
+		if errors.Is(err, store.ErrUserNotFound) {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "El token de verificación ya fue utilizado."})
+			return
+		}
+		writeError(w, err, "No se pudo actualizar el email.")
+		return
+	}
+	h.audit(r, audit.EVENT_EMAIL_VERIFY, audit.OUTCOME_SUCCESS, map[string]interface{}{"old_email": changeClaims.OldEmail, "new_email": changeClaims.NewEmail})
+	writeJSON(w, http.StatusOK, user)
+}
+
+// DeleteMeHandler godoc
+// @Summary Solicitar la eliminación de la cuenta
+// @Description Programa la eliminación definitiva de la cuenta tras el periodo de gracia configurado. Requiere reconfirmar la identidad con la contraseña o, en cuentas solo de Google, con un código de autorización de Google. La solicitud puede cancelarse antes de que venza el plazo.
+// @Tags me
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param   body body DeleteAccountRequest true "Reconfirmación de identidad"
+// @Success 202 {object} models.User "Eliminación programada."
+// @Failure 400 {object} ErrorResponse "Payload de solicitud inválido."
+// @Failure 401 {object} ErrorResponse "No se pudo reconfirmar la identidad."
+// @Router /api/v1/me [delete]
+func (h *MeHandler) DeleteMeHandler(w http.ResponseWriter, r *http.Request) {
+	var req DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Payload de solicitud inválido."})
+		return
+	}
+
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if user.Password != nil {
+		if err := auth.CheckPassword(user, req.Password); err != nil {
+			h.audit(r, audit.EVENT_DELETION_REQUEST, audit.OUTCOME_FAILURE, map[string]interface{}{"reason": "invalid_password"})
+			writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "Contraseña inválida."})
+			return
+		}
+	} else {
+		if req.GoogleAuthCode == "" {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Debe reconfirmar su identidad con Google."})
+			return
+		}
+		googleUserInfo, err := auth.GetGoogleUserInfo(r.Context(), req.GoogleAuthCode)
+		if err != nil || user.GoogleID == nil || googleUserInfo.ID != *user.GoogleID {
+			h.audit(r, audit.EVENT_DELETION_REQUEST, audit.OUTCOME_FAILURE, map[string]interface{}{"reason": "google_reauth_failed"})
+			writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "No se pudo reconfirmar la identidad con Google."})
+			return
+		}
+	}
+
+	user, err := h.Store.ScheduleDeletion(r.Context(), user.ID, time.Now().Add(h.Config.AccountDeletionGrace))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo programar la eliminación."})
+		return
+	}
+	h.audit(r, audit.EVENT_DELETION_REQUEST, audit.OUTCOME_SUCCESS, map[string]interface{}{"deletion_scheduled_at": user.DeletionScheduledAt})
+	writeJSON(w, http.StatusAccepted, user)
+}
+
+// CancelDeletionHandler godoc
+// @Summary Cancelar la eliminación de la cuenta
+// @Description Anula una eliminación programada que aún no se ha ejecutado.
+// @Tags me
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.User "Eliminación cancelada."
+// @Failure 409 {object} ErrorResponse "No hay una eliminación programada."
+// @Router /api/v1/me/deletion/cancel [post]
+func (h *MeHandler) CancelDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if user.DeletionScheduledAt == nil {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "No hay una eliminación programada."})
+		return
+	}
+
+	user, err := h.Store.CancelDeletion(r.Context(), user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo cancelar la eliminación."})
+		return
+	}
+	h.audit(r, audit.EVENT_DELETION_CANCEL, audit.OUTCOME_SUCCESS, nil)
+	writeJSON(w, http.StatusOK, user)
+}
+
+// ExportMeHandler godoc
+// @Summary Exportar los datos personales
+// @Description Devuelve un archivo JSON con el registro del usuario, sus identidades vinculadas, sus sesiones y sus eventos de auditoría.
+// @Tags me
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} DataExportResponse "Archivo de datos personales."
+// @Failure 404 {object} ErrorResponse "Usuario no encontrado."
+// @Router /api/v1/me/export [get]
+func (h *MeHandler) ExportMeHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.Sessions.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudieron exportar los datos."})
+		return
+	}
+
+	// Todos los eventos, no solo la primera página de MaxQueryLimit
+	events, err := audit.QueryAll(r.Context(), h.Audit, audit.Filter{UserID: &user.ID})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudieron exportar los datos."})
+		return
+	}
+
+	export := DataExportResponse{
+		ExportedAt:  time.Now().UTC(),
+		User:        user,
+		Identities:  []IdentityExport{},
+		Sessions:    sessions,
+		AuditEvents: events,
+	}
+	if user.Password != nil {
+		export.Identities = append(export.Identities, IdentityExport{Provider: "native", Subject: user.Email})
+	}
+	if user.GoogleID != nil {
+		export.Identities = append(export.Identities, IdentityExport{Provider: "google", Subject: *user.GoogleID})
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename=mis-datos.json")
+	h.audit(r, audit.EVENT_DATA_EXPORT, audit.OUTCOME_SUCCESS, nil)
+	writeJSON(w, http.StatusOK, export)
+}
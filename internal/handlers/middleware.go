@@ -3,15 +3,34 @@ package handlers
 
 import (
     "context"
+    "errors"
     "net/http"
     // "os"
     "strings"
+    "time"
     "github.com/golang-jwt/jwt/v5"
     "github.com/gorilla/mux"
     "component-4/internal/auth"
+    "component-4/internal/logging"
+    "component-4/internal/metrics"
+    "component-4/internal/models"
+    "component-4/internal/store"
 )
 
-func AuthMiddleware(JWTSecret string) mux.MiddlewareFunc {
+// AuthMiddleware valida el token JWT y que la sesión a la que pertenece no haya sido revocada.
+// Solo acepta tokens de usuario.
+func AuthMiddleware(JWTSecret string, sessions store.SessionRepository) mux.MiddlewareFunc {
+    return authMiddleware(JWTSecret, sessions, nil)
+}
+
+// ServiceAuthMiddleware es como AuthMiddleware pero acepta además tokens de cuentas de servicio,
+// siempre que la cuenta no haya sido revocada. Se combina con RequireRoleOrScope.
+func ServiceAuthMiddleware(JWTSecret string, sessions store.SessionRepository, clients store.ServiceClientRepository) mux.MiddlewareFunc {
+    return authMiddleware(JWTSecret, sessions, clients)
+}
+
+// authMiddleware valida el token; si clients es nil se rechazan los tokens de servicio.
+func authMiddleware(JWTSecret string, sessions store.SessionRepository, clients store.ServiceClientRepository) mux.MiddlewareFunc {
     return func(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
             authHeader := r.Header.Get("Authorization")
@@ -28,13 +47,129 @@ func AuthMiddleware(JWTSecret string) mux.MiddlewareFunc {
             })
 
             if err != nil || !token.Valid {
+                metrics.ObserveTokenValidation(metrics.TokenInvalid)
                 http.Error(w, "Invalid token", http.StatusUnauthorized)
                 return
             }
 
+            // Los tokens emitidos a aplicaciones cliente solo sirven en el endpoint userinfo
+            if claims.IsDelegated() {
+                metrics.ObserveTokenValidation(metrics.TokenInvalid)
+                http.Error(w, "Delegated tokens are not allowed", http.StatusUnauthorized)
+                return
+            }
+
+            if claims.IsService() {
+                if clients == nil {
+                    metrics.ObserveTokenValidation(metrics.TokenInvalid)
+                    http.Error(w, "Service tokens are not allowed", http.StatusUnauthorized)
+                    return
+                }
+                client, err := clients.FindByID(r.Context(), claims.UserID)
+                if err != nil && !errors.Is(err, store.ErrServiceClientNotFound) {
+                    metrics.ObserveTokenValidation(metrics.TokenError)
+                    http.Error(w, "Could not validate service client", http.StatusInternalServerError)
+                    return
+                }
+                if err != nil || !client.IsActive() {
+                    metrics.ObserveTokenValidation(metrics.TokenRevoked)
+                    http.Error(w, "Service client revoked", http.StatusUnauthorized)
+                    return
+                }
+            } else {
+                active, err := sessions.Validate(r.Context(), claims.SessionID)
+                if err != nil {
+                    metrics.ObserveTokenValidation(metrics.TokenError)
+                    http.Error(w, "Could not validate session", http.StatusInternalServerError)
+                    return
+                }
+                if !active {
+                    metrics.ObserveTokenValidation(metrics.TokenRevoked)
+                    http.Error(w, "Session revoked", http.StatusUnauthorized)
+                    return
+                }
+            }
+
+            metrics.ObserveTokenValidation(metrics.TokenValid)
+
             // Añadir claims al contexto
-            ctx := context.WithValue(r.Context(), "user", claims)
+            ctx := context.WithValue(r.Context(), UserIDKey, claims)
             next.ServeHTTP(w, r.WithContext(ctx))
         })
     }
-}
\ No newline at end of file
+}
+
+// ExtendDeadlines amplía los plazos de lectura y escritura de la conexión a timeout desde ahora, para
+// las rutas que reciben o generan archivos grandes (importación y exportaciones CSV) y no caben en
+// HTTP_READ_TIMEOUT ni HTTP_WRITE_TIMEOUT. El resto de rutas conserva los plazos del servidor.
+func ExtendDeadlines(timeout time.Duration) mux.MiddlewareFunc {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            rc := http.NewResponseController(w)
+            deadline := time.Now().Add(timeout)
+            if err := rc.SetReadDeadline(deadline); err != nil {
+                logging.FromContext(r.Context()).Warn("no se pudo ampliar el plazo de lectura", "error", err)
+            }
+            if err := rc.SetWriteDeadline(deadline); err != nil {
+                logging.FromContext(r.Context()).Warn("no se pudo ampliar el plazo de escritura", "error", err)
+            }
+            next.ServeHTTP(w, r)
+        })
+    }
+}
+
+// claimsFromContext obtiene los claims que AuthMiddleware dejó en el contexto de la petición.
+func claimsFromContext(r *http.Request) (*auth.Claims, bool) {
+    claims, ok := r.Context().Value(UserIDKey).(*auth.Claims)
+    return claims, ok
+}
+
+// RequireRole restringe las rutas a usuarios autenticados con alguno de los roles indicados.
+// Debe usarse después de AuthMiddleware.
+func RequireRole(roles ...models.Role) mux.MiddlewareFunc {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            claims, ok := claimsFromContext(r)
+            if !ok {
+                http.Error(w, "Invalid token", http.StatusUnauthorized)
+                return
+            }
+            for _, role := range roles {
+                if models.Role(claims.Role) == role {
+                    next.ServeHTTP(w, r)
+                    return
+                }
+            }
+            http.Error(w, "Forbidden", http.StatusForbidden)
+        })
+    }
+}
+
+// RequireRoleOrScope admite a los usuarios con alguno de los roles indicados y a las cuentas de
+// servicio cuyo token incluya el scope. Debe usarse después de ServiceAuthMiddleware.
+func RequireRoleOrScope(scope string, roles ...models.Role) mux.MiddlewareFunc {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            claims, ok := claimsFromContext(r)
+            if !ok {
+                http.Error(w, "Invalid token", http.StatusUnauthorized)
+                return
+            }
+            if claims.IsService() {
+                if claims.HasScope(scope) {
+                    next.ServeHTTP(w, r)
+                    return
+                }
+                http.Error(w, "Forbidden", http.StatusForbidden)
+                return
+            }
+            for _, role := range roles {
+                if models.Role(claims.Role) == role {
+                    next.ServeHTTP(w, r)
+                    return
+                }
+            }
+            http.Error(w, "Forbidden", http.StatusForbidden)
+        })
+    }
+}
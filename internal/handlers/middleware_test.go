@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"component-4/internal/logging"
+	"component-4/internal/metrics"
+)
+
+// TestExtendDeadlines comprueba que una respuesta más lenta que el WriteTimeout del servidor llega
+// completa con ExtendDeadlines, también a través de los ResponseWriter de logging y metrics.
+func TestExtendDeadlines(t *testing.T) {
+	const writeTimeout = 100 * time.Millisecond
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(3 * writeTimeout)
+		io.WriteString(w, "ok")
+	})
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		wantOK  bool
+	}{
+		{"sin ampliar", logging.Middleware(metrics.Middleware(slow)), false},
+		{"ampliado", logging.Middleware(metrics.Middleware(ExtendDeadlines(time.Minute)(slow))), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewUnstartedServer(tt.handler)
+			srv.Config.WriteTimeout = writeTimeout
+			srv.Start()
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL)
+			var body []byte
+			if err == nil {
+				body, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+			gotOK := err == nil && string(body) == "ok"
+			if gotOK != tt.wantOK {
+				t.Fatalf("respuesta completa = %v, se esperaba %v (err = %v)", gotOK, tt.wantOK, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"component-4/internal/audit"
+	"component-4/internal/models"
+	"component-4/internal/store"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// CreateOAuthClientRequest representa el cuerpo de la solicitud para registrar una aplicación cliente.
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" example:"Biblioteca"`
+	RedirectURIs []string `json:"redirect_uris" example:"https://biblioteca.colegio.edu/oidc/callback"`
+	Public       bool     `json:"public" example:"false"`
+}
+
+// OAuthClientSecretResponse devuelve la aplicación recién registrada junto con su secreto, que solo
+// se muestra en esta respuesta. Las aplicaciones públicas no tienen secreto.
+type OAuthClientSecretResponse struct {
+	Client       *models.OAuthClient `json:"client"`
+	ClientSecret string              `json:"client_secret,omitempty" example:"q3Xo9vH2..."`
+}
+
+// OAuthClientHandler contiene las dependencias para administrar las aplicaciones cliente de OIDC.
+type OAuthClientHandler struct {
+	Store store.OAuthRepository
+	Audit audit.Repository
+}
+
+// NewOAuthClientHandler crea una nueva instancia de OAuthClientHandler.
+func NewOAuthClientHandler(s store.OAuthRepository, a audit.Repository) *OAuthClientHandler {
+	return &OAuthClientHandler{Store: s, Audit: a}
+}
+
+// validRedirectURI exige una URL absoluta http(s) sin fragmento, como pide RFC 6749 (sección 3.1.2).
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.Fragment != "" {
+		return false
+	}
+	return u.Scheme == "https" || u.Scheme == "http"
+}
+
+// CreateOAuthClientHandler godoc
+// @Summary Registrar una aplicación cliente de OIDC
+// @Description Registra una aplicación que podrá usar "Iniciar sesión con la cuenta del colegio". Las redirect_uris deben coincidir exactamente con las que envíe la aplicación. Las aplicaciones confidenciales reciben un secreto que solo se muestra en esta respuesta; las públicas (SPA, móviles) dependen solo de PKCE.
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Param   body body CreateOAuthClientRequest true "Datos de la aplicación"
+// @Success 201 {object} OAuthClientSecretResponse "Aplicación registrada."
+// @Failure 400 {object} ErrorResponse "Payload de solicitud inválido o redirect_uri inválida."
+// @Failure 403 {string} string "El usuario no es administrador."
+// @Failure 500 {object} ErrorResponse "No se pudo registrar la aplicación."
+// @Router /api/v1/admin/oauth-clients [post]
+func (h *OAuthClientHandler) CreateOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateOAuthClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" || len(req.RedirectURIs) == 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Payload de solicitud inválido."})
+		return
+	}
+	for _, uri := range req.RedirectURIs {
+		if !validRedirectURI(uri) {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "redirect_uri inválida: " + uri})
+			return
+		}
+	}
+
+	claims, _ := claimsFromContext(r)
+	client, secret, err := h.Store.CreateClient(r.Context(), strings.TrimSpace(req.Name), req.RedirectURIs, req.Public, &claims.UserID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo registrar la aplicación."})
+		return
+	}
+	recordAudit(h.Audit, r, audit.Event{
+		Type:     audit.EVENT_OAUTH_CLIENT_CREATE,
+		Outcome:  audit.OUTCOME_SUCCESS,
+		Metadata: map[string]interface{}{"oauth_client_id": client.ID, "client_id": client.ClientID, "redirect_uris": client.RedirectURIs},
+	})
+
+	writeJSON(w, http.StatusCreated, OAuthClientSecretResponse{Client: client, ClientSecret: secret})
+}
+
+// ListOAuthClientsHandler godoc
+// @Summary Listar aplicaciones cliente de OIDC
+// @Description Devuelve todas las aplicaciones registradas, incluidas las revocadas. Nunca incluye los secretos.
+// @Tags admin
+// @Produce  json
+// @Security BearerAuth
+// @Success 200 {array} models.OAuthClient "Aplicaciones registradas."
+// @Failure 403 {string} string "El usuario no es administrador."
+// @Failure 500 {object} ErrorResponse "No se pudieron listar las aplicaciones."
+// @Router /api/v1/admin/oauth-clients [get]
+func (h *OAuthClientHandler) ListOAuthClientsHandler(w http.ResponseWriter, r *http.Request) {
+	clients, err := h.Store.ListClients(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudieron listar las aplicaciones."})
+		return
+	}
+	writeJSON(w, http.StatusOK, clients)
+}
+
+// RevokeOAuthClientHandler godoc
+// @Summary Revocar una aplicación cliente de OIDC
+// @Description La aplicación deja de poder iniciar autorizaciones y canjear códigos. Los tokens ya emitidos siguen vigentes hasta que se revoquen sus sesiones.
+// @Tags admin
+// @Produce  json
+// @Security BearerAuth
+// @Param   id path string true "ID de la aplicación"
+// @Success 200 {object} MessageResponse "Aplicación revocada."
+// @Failure 400 {object} ErrorResponse "ID inválido."
+// @Failure 404 {object} ErrorResponse "Aplicación no encontrada o ya revocada."
+// @Failure 500 {object} ErrorResponse "No se pudo revocar la aplicación."
+// @Router /api/v1/admin/oauth-clients/{id} [delete]
+func (h *OAuthClientHandler) RevokeOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID inválido."})
+		return
+	}
+
+	if err := h.Store.RevokeClient(r.Context(), id); err != nil {
+		writeError(w, err, "No se pudo revocar la aplicación.")
+		return
+	}
+	recordAudit(h.Audit, r, audit.Event{
+		Type:     audit.EVENT_OAUTH_CLIENT_REVOKE,
+		Outcome:  audit.OUTCOME_SUCCESS,
+		Metadata: map[string]interface{}{"oauth_client_id": id},
+	})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Aplicación revocada."})
+}
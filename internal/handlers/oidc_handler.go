@@ -0,0 +1,630 @@
+package handlers
+
+import (
+	"component-4/config"
+	"component-4/internal/audit"
+	"component-4/internal/auth"
+	"component-4/internal/logging"
+	"component-4/internal/metrics"
+	"component-4/internal/models"
+	"component-4/internal/store"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Códigos de error de /authorize (RFC 6749, sección 4.1.2.1) que no comparte el endpoint de tokens.
+const (
+	OAuthErrUnsupportedResponseType = "unsupported_response_type"
+	OAuthErrAccessDenied            = "access_denied"
+)
+
+// DiscoveryResponse es el documento de descubrimiento de OpenID Connect.
+type DiscoveryResponse struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// ConsentClient describe la aplicación que solicita acceso en la pantalla de consentimiento.
+type ConsentClient struct {
+	ClientID string `json:"client_id" example:"app_3f9a1c2b4d5e6f70"`
+	Name     string `json:"name" example:"Biblioteca"`
+}
+
+// ConsentRequestResponse es lo que el frontend necesita para mostrar la pantalla de consentimiento.
+type ConsentRequestResponse struct {
+	RequestID         uuid.UUID     `json:"request_id"`
+	Client            ConsentClient `json:"client"`
+	Scopes            []string      `json:"scopes" example:"openid,email"`
+	PreviouslyGranted bool          `json:"previously_granted"`
+	ExpiresAt         time.Time     `json:"expires_at"`
+}
+
+// ConsentDecisionRequest es la decisión del usuario sobre una solicitud de autorización.
+type ConsentDecisionRequest struct {
+	Approve bool `json:"approve" example:"true"`
+}
+
+// ConsentDecisionResponse indica a qué URL de la aplicación cliente debe navegar el frontend.
+type ConsentDecisionResponse struct {
+	RedirectTo string `json:"redirect_to" example:"https://lms.colegio.edu/callback?code=...&state=..."`
+}
+
+// OIDCTokenResponse es la respuesta del grant authorization_code.
+type OIDCTokenResponse struct {
+	AccessToken string `json:"access_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	TokenType   string `json:"token_type" example:"Bearer"`
+	ExpiresIn   int64  `json:"expires_in" example:"86400"`
+	Scope       string `json:"scope" example:"openid email profile"`
+	IDToken     string `json:"id_token" example:"eyJhbGciOiJSUzI1NiIsImtpZCI6Ii4uLiJ9..."`
+}
+
+// UserInfoResponse es la respuesta del endpoint userinfo; los campos dependen de los scopes concedidos.
+type UserInfoResponse struct {
+	Sub   string `json:"sub" example:"7f1c2e4a-3b5d-4c6e-8f9a-0b1c2d3e4f5a"`
+	Name  string `json:"name,omitempty" example:"Ana Gómez"`
+	Role  string `json:"role,omitempty" example:"profesor"`
+	Email string `json:"email,omitempty" example:"docente@colegio.edu"`
+}
+
+// OIDCHandler implementa el servidor de autorización OAuth 2.0 / OpenID Connect para las demás
+// aplicaciones del colegio. El inicio de sesión lo resuelve el frontend con el login nativo o de Google.
+type OIDCHandler struct {
+	Store    store.OAuthRepository
+	Users    store.UserRepository
+	Sessions store.SessionRepository
+	Services *ServiceClientHandler
+	Audit    audit.Repository
+	Config   *config.Config
+	Key      *auth.SigningKey
+}
+
+// NewOIDCHandler crea una nueva instancia de OIDCHandler.
+func NewOIDCHandler(s store.OAuthRepository, users store.UserRepository, sessions store.SessionRepository, services *ServiceClientHandler, a audit.Repository, c *config.Config, key *auth.SigningKey) *OIDCHandler {
+	return &OIDCHandler{Store: s, Users: users, Sessions: sessions, Services: services, Audit: a, Config: c, Key: key}
+}
+
+// DiscoveryHandler godoc
+// @Summary Documento de descubrimiento de OpenID Connect
+// @Tags oauth
+// @Produce  json
+// @Success 200 {object} DiscoveryResponse "Metadatos del proveedor."
+// @Router /.well-known/openid-configuration [get]
+func (h *OIDCHandler) DiscoveryHandler(w http.ResponseWriter, r *http.Request) {
+	issuer := h.Config.OIDCIssuer
+	writeJSON(w, http.StatusOK, DiscoveryResponse{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/api/v1/oauth/authorize",
+		TokenEndpoint:                     issuer + "/api/v1/oauth/token",
+		UserinfoEndpoint:                  issuer + "/api/v1/oauth/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		IntrospectionEndpoint:             issuer + "/api/v1/oauth/introspect",
+		ScopesSupported:                   []string{models.SCOPE_OPENID, models.SCOPE_PROFILE, models.SCOPE_EMAIL},
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{"RS256"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{auth.PKCE_METHOD_S256},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "role", "email"},
+	})
+}
+
+// JWKSHandler godoc
+// @Summary Claves públicas para verificar los ID tokens
+// @Tags oauth
+// @Produce  json
+// @Success 200 {object} auth.JWKS "Conjunto de claves."
+// @Router /.well-known/jwks.json [get]
+func (h *OIDCHandler) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	writeJSON(w, http.StatusOK, h.Key.JWKS())
+}
+
+// AuthorizeHandler godoc
+// @Summary Iniciar una autorización con código y PKCE
+// @Description Valida la aplicación, la redirect_uri y los parámetros, guarda la solicitud y redirige a la pantalla de consentimiento del frontend con `request_id`. Los errores posteriores a validar la redirect_uri se devuelven a la aplicación por redirección.
+// @Tags oauth
+// @Param   response_type query string true "Debe ser code"
+// @Param   client_id query string true "ID de la aplicación"
+// @Param   redirect_uri query string true "URI de redirección registrada"
+// @Param   scope query string true "Scopes separados por espacios; debe incluir openid"
+// @Param   state query string false "Valor opaco que se devuelve a la aplicación"
+// @Param   nonce query string false "Valor que se incluye en el ID token"
+// @Param   code_challenge query string true "Reto PKCE"
+// @Param   code_challenge_method query string true "Debe ser S256"
+// @Success 302 {string} string "Redirección a la pantalla de consentimiento o a la aplicación con el error."
+// @Failure 400 {object} ErrorResponse "Aplicación desconocida o redirect_uri no registrada."
+// @Router /api/v1/oauth/authorize [get]
+func (h *OIDCHandler) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	client, err := h.Store.FindClient(r.Context(), query.Get("client_id"))
+	if err != nil && !errors.Is(err, store.ErrOAuthClientNotFound) {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo validar la aplicación."})
+		return
+	}
+	if err != nil || !client.IsActive() {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Aplicación cliente desconocida."})
+		return
+	}
+	// Con una redirect_uri no registrada no se redirige: sería un redirector abierto
+	redirectURI := query.Get("redirect_uri")
+	if !client.AllowsRedirect(redirectURI) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "redirect_uri no registrada para la aplicación."})
+		return
+	}
+
+	state := query.Get("state")
+	fail := func(code, description string) {
+		http.Redirect(w, r, authorizationRedirect(redirectURI, url.Values{
+			"error": {code}, "error_description": {description}, "state": {state},
+		}), http.StatusFound)
+	}
+
+	if query.Get("response_type") != "code" {
+		fail(OAuthErrUnsupportedResponseType, "Solo se admite response_type=code.")
+		return
+	}
+	scopes := strings.Fields(query.Get("scope"))
+	if !containsString(scopes, models.SCOPE_OPENID) {
+		fail(OAuthErrInvalidScope, "El scope debe incluir openid.")
+		return
+	}
+	for _, scope := range scopes {
+		if !models.ValidOIDCScope(scope) {
+			fail(OAuthErrInvalidScope, "Scope desconocido: "+scope)
+			return
+		}
+	}
+	challenge := query.Get("code_challenge")
+	if query.Get("code_challenge_method") != auth.PKCE_METHOD_S256 || len(challenge) < 43 || len(challenge) > 128 {
+		fail(OAuthErrInvalidRequest, "Se requiere PKCE con code_challenge_method=S256.")
+		return
+	}
+
+	now := time.Now()
+	authorization := &models.Authorization{
+		ID:                  uuid.New(),
+		ClientID:            client.ID,
+		RedirectURI:         redirectURI,
+		Scope:               strings.Join(scopes, " "),
+		State:               state,
+		Nonce:               query.Get("nonce"),
+		CodeChallenge:       challenge,
+		CodeChallengeMethod: auth.PKCE_METHOD_S256,
+		ExpiresAt:           now.Add(h.Config.OIDCAuthRequestTTL),
+		CreatedAt:           now,
+	}
+	if err := h.Store.CreateAuthorization(r.Context(), authorization); err != nil {
+		fail(OAuthErrServerError, "No se pudo registrar la solicitud.")
+		return
+	}
+
+	http.Redirect(w, r, h.Config.OIDCConsentURL+"?request_id="+authorization.ID.String(), http.StatusFound)
+}
+
+// GetConsentHandler godoc
+// @Summary Obtener una solicitud de autorización pendiente
+// @Description Devuelve la aplicación y los scopes que solicita, e indica si el usuario ya los había concedido (en cuyo caso el frontend puede aprobarla sin preguntar).
+// @Tags oauth
+// @Produce  json
+// @Security BearerAuth
+// @Param   id path string true "ID de la solicitud (request_id)"
+// @Success 200 {object} ConsentRequestResponse "Solicitud pendiente."
+// @Failure 400 {object} ErrorResponse "ID inválido."
+// @Failure 404 {object} ErrorResponse "Solicitud no encontrada."
+// @Failure 409 {object} ErrorResponse "La solicitud ya no está pendiente."
+// @Router /api/v1/oauth/consent/{id} [get]
+func (h *OIDCHandler) GetConsentHandler(w http.ResponseWriter, r *http.Request) {
+	authorization, client, ok := h.pendingAuthorization(w, r)
+	if !ok {
+		return
+	}
+
+	claims, _ := claimsFromContext(r)
+	granted, err := h.Store.FindConsent(r.Context(), claims.UserID, client.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo obtener el consentimiento."})
+		return
+	}
+	scopes := strings.Fields(authorization.Scope)
+	previouslyGranted := granted != nil
+	for _, scope := range scopes {
+		if !containsString(granted, scope) {
+			previouslyGranted = false
+		}
+	}
+
+	writeJSON(w, http.StatusOK, ConsentRequestResponse{
+		RequestID:         authorization.ID,
+		Client:            ConsentClient{ClientID: client.ClientID, Name: client.Name},
+		Scopes:            scopes,
+		PreviouslyGranted: previouslyGranted,
+		ExpiresAt:         authorization.ExpiresAt,
+	})
+}
+
+// ConsentHandler godoc
+// @Summary Aprobar o rechazar una solicitud de autorización
+// @Description Registra la decisión del usuario autenticado. Si la aprueba se emite un código de un solo uso; en ambos casos se devuelve la URL de la aplicación a la que el frontend debe redirigir.
+// @Tags oauth
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Param   id path string true "ID de la solicitud (request_id)"
+// @Param   body body ConsentDecisionRequest true "Decisión del usuario"
+// @Success 200 {object} ConsentDecisionResponse "URL de redirección a la aplicación."
+// @Failure 400 {object} ErrorResponse "ID o payload inválido."
+// @Failure 404 {object} ErrorResponse "Solicitud no encontrada."
+// @Failure 409 {object} ErrorResponse "La solicitud ya no está pendiente."
+// @Router /api/v1/oauth/consent/{id} [post]
+func (h *OIDCHandler) ConsentHandler(w http.ResponseWriter, r *http.Request) {
+	var req ConsentDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Payload de solicitud inválido."})
+		return
+	}
+	authorization, client, ok := h.pendingAuthorization(w, r)
+	if !ok {
+		return
+	}
+	claims, _ := claimsFromContext(r)
+	metadata := map[string]interface{}{"client_id": client.ClientID, "scope": authorization.Scope}
+
+	if !req.Approve {
+		if err := h.Store.DenyAuthorization(r.Context(), authorization.ID); err != nil {
+			writeConsentError(w, err)
+			return
+		}
+		metadata["reason"] = "denied"
+		recordAudit(h.Audit, r, audit.Event{Type: audit.EVENT_OAUTH_CONSENT, Outcome: audit.OUTCOME_FAILURE, TargetID: &claims.UserID, Metadata: metadata})
+		writeJSON(w, http.StatusOK, ConsentDecisionResponse{RedirectTo: authorizationRedirect(authorization.RedirectURI, url.Values{
+			"error": {OAuthErrAccessDenied}, "error_description": {"El usuario rechazó la solicitud."}, "state": {authorization.State},
+		})})
+		return
+	}
+
+	code, codeHash, err := generateAuthorizationCode()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo generar el código de autorización."})
+		return
+	}
+	authTime := time.Now()
+	if claims.IssuedAt != nil {
+		authTime = claims.IssuedAt.Time
+	}
+	if err := h.Store.ApproveAuthorization(r.Context(), authorization.ID, claims.UserID, authTime, codeHash, time.Now().Add(h.Config.OIDCCodeTTL)); err != nil {
+		writeConsentError(w, err)
+		return
+	}
+	if err := h.Store.SaveConsent(r.Context(), claims.UserID, client.ID, strings.Fields(authorization.Scope)); err != nil {
+		logging.FromContext(r.Context()).Error("error guardando el consentimiento", "error", err, "client_id", client.ClientID)
+	}
+	recordAudit(h.Audit, r, audit.Event{Type: audit.EVENT_OAUTH_CONSENT, Outcome: audit.OUTCOME_SUCCESS, TargetID: &claims.UserID, Metadata: metadata})
+
+	writeJSON(w, http.StatusOK, ConsentDecisionResponse{RedirectTo: authorizationRedirect(authorization.RedirectURI, url.Values{
+		"code": {code}, "state": {authorization.State},
+	})})
+}
+
+// TokenHandler godoc
+// @Summary Endpoint de tokens de OAuth 2.0
+// @Description Con `grant_type=authorization_code` canjea un código de autorización (con `code_verifier` de PKCE) por un token de acceso y un ID token; las aplicaciones confidenciales se autentican con su secreto y las públicas solo envían `client_id`. Con `grant_type=client_credentials` emite un token para una cuenta de servicio; si no se indica scope se conceden todos los de la cuenta.
+// @Tags oauth
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param   grant_type formData string true "authorization_code o client_credentials"
+// @Param   code formData string false "Código de autorización (authorization_code)"
+// @Param   redirect_uri formData string false "La misma redirect_uri de la autorización (authorization_code)"
+// @Param   code_verifier formData string false "Verificador PKCE (authorization_code)"
+// @Param   scope formData string false "Scopes solicitados, separados por espacios (client_credentials)"
+// @Param   client_id formData string false "ID del cliente (si no se usa HTTP Basic)"
+// @Param   client_secret formData string false "Secreto del cliente (si no se usa HTTP Basic)"
+// @Success 200 {object} OIDCTokenResponse "Token emitido (client_credentials devuelve ServiceTokenResponse)."
+// @Failure 400 {object} OAuthErrorResponse "Solicitud inválida, grant no soportado, código inválido o scope no permitido."
+// @Failure 401 {object} OAuthErrorResponse "Credenciales de cliente inválidas."
+// @Failure 500 {object} OAuthErrorResponse "No se pudo emitir el token."
+// @Router /api/v1/oauth/token [post]
+func (h *OIDCHandler) TokenHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, OAuthErrInvalidRequest, "Cuerpo de la solicitud inválido.")
+		return
+	}
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		h.authorizationCodeGrant(w, r)
+	case "client_credentials":
+		h.Services.clientCredentialsGrant(w, r)
+	case "":
+		writeOAuthError(w, http.StatusBadRequest, OAuthErrInvalidRequest, "Falta el parámetro grant_type.")
+	default:
+		writeOAuthError(w, http.StatusBadRequest, OAuthErrUnsupportedGrantType, "Solo se admiten los grants authorization_code y client_credentials.")
+	}
+}
+
+// authorizationCodeGrant canjea un código de autorización. El código se valida (cliente, PKCE y
+// usuario) antes de canjearlo, y el canje registra la sesión abierta para él. Un código reutilizado
+// revoca esa sesión, como recomienda RFC 6749 (sección 4.1.2).
+func (h *OIDCHandler) authorizationCodeGrant(w http.ResponseWriter, r *http.Request) {
+	clientID, secret := clientCredentials(r)
+	if clientID == "" {
+		writeOAuthError(w, http.StatusUnauthorized, OAuthErrInvalidClient, "Faltan las credenciales del cliente.")
+		return
+	}
+	client, err := h.Store.AuthenticateClient(r.Context(), clientID, secret)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidClientCredentials) {
+			writeOAuthError(w, http.StatusUnauthorized, OAuthErrInvalidClient, "Credenciales de cliente inválidas.")
+			return
+		}
+		writeOAuthError(w, http.StatusInternalServerError, OAuthErrServerError, "No se pudo validar el cliente.")
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	if code == "" {
+		writeOAuthError(w, http.StatusBadRequest, OAuthErrInvalidRequest, "Falta el parámetro code.")
+		return
+	}
+	invalidGrant := func(reason string) {
+		recordAudit(h.Audit, r, audit.Event{
+			Type:     audit.EVENT_OAUTH_TOKEN,
+			Outcome:  audit.OUTCOME_FAILURE,
+			Metadata: map[string]interface{}{"client_id": client.ClientID, "reason": reason},
+		})
+		writeOAuthError(w, http.StatusBadRequest, OAuthErrInvalidGrant, "Código de autorización inválido, vencido o ya utilizado.")
+	}
+
+	// codeFailed responde a un error al buscar o canjear el código. Un código reutilizado revoca la
+	// sesión que se abrió con él, que ConsumeCode registra en la misma actualización que el canje.
+	codeFailed := func(authorization *models.Authorization, err error) {
+		switch {
+		case errors.Is(err, store.ErrAuthorizationCodeReused):
+			if authorization.SessionID != nil {
+				if err := h.Sessions.Revoke(r.Context(), *authorization.SessionID, nil); err != nil {
+					logging.FromContext(r.Context()).Error("error revocando la sesión de un código reutilizado", "error", err)
+				}
+			}
+			invalidGrant("code_reused")
+		case errors.Is(err, store.ErrInvalidAuthorizationCode):
+			invalidGrant("invalid_code")
+		default:
+			writeOAuthError(w, http.StatusInternalServerError, OAuthErrServerError, "No se pudo validar el código.")
+		}
+	}
+
+	codeHash := hashAuthorizationCode(code)
+	authorization, err := h.Store.FindAuthorizationByCode(r.Context(), codeHash)
+	if err != nil {
+		codeFailed(authorization, err)
+		return
+	}
+	if authorization.ClientID != client.ID || authorization.RedirectURI != r.PostForm.Get("redirect_uri") {
+		invalidGrant("client_mismatch")
+		return
+	}
+	if !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), authorization.CodeChallenge, authorization.CodeChallengeMethod) {
+		invalidGrant("pkce_mismatch")
+		return
+	}
+
+	user, err := h.Users.FindByID(r.Context(), *authorization.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			invalidGrant("user_not_found")
+			return
+		}
+		writeOAuthError(w, http.StatusInternalServerError, OAuthErrServerError, "No se pudo obtener el usuario.")
+		return
+	}
+	if user.AnonymizedAt != nil {
+		invalidGrant("user_anonymized")
+		return
+	}
+
+	session, err := h.Sessions.Create(r.Context(), user.ID, client.Name, clientIP(r), models.AUTH_METHOD_OIDC, auth.TokenExpiry())
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, OAuthErrServerError, "No se pudo abrir la sesión.")
+		return
+	}
+	// El canje se hace después de abrir la sesión para registrarlas juntas. Si falla (p. ej. otro
+	// canje simultáneo ganó), la sesión nueva se revoca sin haber llegado a emitir un token.
+	if consumed, err := h.Store.ConsumeCode(r.Context(), codeHash, session.ID); err != nil {
+		if err := h.Sessions.Revoke(r.Context(), session.ID, nil); err != nil {
+			logging.FromContext(r.Context()).Error("error revocando la sesión de un canje fallido", "error", err)
+		}
+		codeFailed(consumed, err)
+		return
+	}
+	accessToken, err := auth.GenerateDelegatedToken(user, session, client.ClientID, authorization.Scope, h.Config.JWTSecret)
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, OAuthErrServerError, "No se pudo emitir el token.")
+		return
+	}
+	idToken, err := auth.GenerateIDToken(user, auth.IDTokenParams{
+		Issuer:   h.Config.OIDCIssuer,
+		ClientID: client.ClientID,
+		Scope:    authorization.Scope,
+		Nonce:    authorization.Nonce,
+		AuthTime: *authorization.AuthTime,
+		TTL:      h.Config.OIDCIDTokenTTL,
+	}, h.Key)
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, OAuthErrServerError, "No se pudo emitir el ID token.")
+		return
+	}
+
+	metrics.ObserveTokenIssued("authorization_code")
+	recordAudit(h.Audit, r, audit.Event{
+		Type:     audit.EVENT_OAUTH_TOKEN,
+		Outcome:  audit.OUTCOME_SUCCESS,
+		ActorID:  &user.ID,
+		TargetID: &user.ID,
+		Metadata: map[string]interface{}{"client_id": client.ClientID, "scope": authorization.Scope, "session_id": session.ID},
+	})
+
+	writeJSON(w, http.StatusOK, OIDCTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(session.ExpiresAt).Seconds()),
+		Scope:       authorization.Scope,
+		IDToken:     idToken,
+	})
+}
+
+// UserInfoHandler godoc
+// @Summary Datos del usuario para aplicaciones cliente (OIDC userinfo)
+// @Description Devuelve los datos del usuario según los scopes concedidos. Solo acepta tokens emitidos a una aplicación cliente con el scope openid.
+// @Tags oauth
+// @Produce  json
+// @Security BearerAuth
+// @Success 200 {object} UserInfoResponse "Datos del usuario."
+// @Failure 401 {object} ErrorResponse "Token inválido, revocado o sin el scope openid."
+// @Router /api/v1/oauth/userinfo [get]
+func (h *OIDCHandler) UserInfoHandler(w http.ResponseWriter, r *http.Request) {
+	unauthorized := func(result string) {
+		metrics.ObserveTokenValidation(result)
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "Token inválido."})
+	}
+
+	tokenString := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	claims, err := auth.ValidateToken(tokenString, h.Config.JWTSecret)
+	if err != nil || !claims.IsDelegated() || !claims.HasScope(models.SCOPE_OPENID) {
+		unauthorized(metrics.TokenInvalid)
+		return
+	}
+	active, err := h.Sessions.Validate(r.Context(), claims.SessionID)
+	if err != nil {
+		metrics.ObserveTokenValidation(metrics.TokenError)
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo validar la sesión."})
+		return
+	}
+	if !active {
+		unauthorized(metrics.TokenRevoked)
+		return
+	}
+	user, err := h.Users.FindByID(r.Context(), claims.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			unauthorized(metrics.TokenRevoked)
+			return
+		}
+		metrics.ObserveTokenValidation(metrics.TokenError)
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo obtener el usuario."})
+		return
+	}
+	if user.AnonymizedAt != nil {
+		unauthorized(metrics.TokenRevoked)
+		return
+	}
+
+	metrics.ObserveTokenValidation(metrics.TokenValid)
+	response := UserInfoResponse{Sub: user.ID.String()}
+	if claims.HasScope(models.SCOPE_PROFILE) {
+		response.Name = user.Name
+		response.Role = string(user.Role)
+	}
+	if claims.HasScope(models.SCOPE_EMAIL) {
+		response.Email = user.Email
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// pendingAuthorization carga la solicitud de la ruta y su aplicación, y responde con el error
+// correspondiente si ya no puede decidirse.
+func (h *OIDCHandler) pendingAuthorization(w http.ResponseWriter, r *http.Request) (*models.Authorization, *models.OAuthClient, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID inválido."})
+		return nil, nil, false
+	}
+	authorization, err := h.Store.FindAuthorization(r.Context(), id)
+	if err != nil {
+		writeConsentError(w, err)
+		return nil, nil, false
+	}
+	if !authorization.IsPending(time.Now()) {
+		writeConsentError(w, store.ErrAuthorizationNotPending)
+		return nil, nil, false
+	}
+	client, err := h.Store.FindClientByID(r.Context(), authorization.ClientID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo obtener la aplicación."})
+		return nil, nil, false
+	}
+	if !client.IsActive() {
+		writeConsentError(w, store.ErrAuthorizationNotPending)
+		return nil, nil, false
+	}
+	return authorization, client, true
+}
+
+func writeConsentError(w http.ResponseWriter, err error) {
+	writeError(w, err, "No se pudo procesar la solicitud de autorización.")
+}
+
+// authorizationRedirect añade los parámetros a la redirect_uri conservando los que ya tuviera.
+func authorizationRedirect(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := u.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// generateAuthorizationCode devuelve un código aleatorio y el hash con el que se guarda.
+func generateAuthorizationCode() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	code := base64.RawURLEncoding.EncodeToString(raw)
+	return code, hashAuthorizationCode(code), nil
+}
+
+func hashAuthorizationCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"component-4/config"
+	"component-4/internal/audit"
+	"component-4/internal/auth"
+	"component-4/internal/metrics"
+	"component-4/internal/models"
+	"component-4/internal/store"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Códigos de error de OAuth 2.0 (RFC 6749, sección 5.2).
+const (
+	OAuthErrInvalidRequest       = "invalid_request"
+	OAuthErrInvalidClient        = "invalid_client"
+	OAuthErrUnsupportedGrantType = "unsupported_grant_type"
+	OAuthErrInvalidScope         = "invalid_scope"
+	OAuthErrInvalidGrant         = "invalid_grant"
+	OAuthErrServerError          = "server_error"
+)
+
+// OAuthErrorResponse es el cuerpo de error del endpoint de tokens según RFC 6749.
+type OAuthErrorResponse struct {
+	Error            string `json:"error" example:"invalid_client"`
+	ErrorDescription string `json:"error_description,omitempty" example:"Credenciales de cliente inválidas."`
+}
+
+// ServiceTokenResponse es la respuesta del grant client_credentials.
+type ServiceTokenResponse struct {
+	AccessToken string `json:"access_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	TokenType   string `json:"token_type" example:"Bearer"`
+	ExpiresIn   int64  `json:"expires_in" example:"3600"`
+	Scope       string `json:"scope" example:"users:read audit:read"`
+}
+
+// CreateServiceClientRequest representa el cuerpo de la solicitud para crear una cuenta de servicio.
+type CreateServiceClientRequest struct {
+	Name   string   `json:"name" example:"Sincronización de notas"`
+	Scopes []string `json:"scopes" example:"users:read"`
+}
+
+// ServiceClientSecretResponse devuelve la cuenta de servicio junto con su secreto, que solo se
+// muestra al crearla o rotarla.
+type ServiceClientSecretResponse struct {
+	Client       *models.ServiceClient `json:"client"`
+	ClientSecret string                `json:"client_secret" example:"q3Xo9vH2..."`
+}
+
+// ServiceClientHandler contiene las dependencias para el grant client_credentials y la
+// administración de cuentas de servicio.
+type ServiceClientHandler struct {
+	Store  store.ServiceClientRepository
+	Audit  audit.Repository
+	Config *config.Config
+}
+
+// NewServiceClientHandler crea una nueva instancia de ServiceClientHandler.
+func NewServiceClientHandler(s store.ServiceClientRepository, a audit.Repository, c *config.Config) *ServiceClientHandler {
+	return &ServiceClientHandler{Store: s, Audit: a, Config: c}
+}
+
+// clientCredentials obtiene las credenciales del cliente por HTTP Basic o, si no vienen, de los
+// campos client_id y client_secret del formulario.
+func clientCredentials(r *http.Request) (string, string) {
+	if clientID, secret, ok := r.BasicAuth(); ok {
+		return clientID, secret
+	}
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+	}
+	writeJSON(w, status, OAuthErrorResponse{Error: code, ErrorDescription: description})
+}
+
+// clientCredentialsGrant atiende el grant client_credentials del endpoint de tokens. Si no se indica
+// scope se conceden todos los de la cuenta; si se indica, debe ser un subconjunto de ellos.
+func (h *ServiceClientHandler) clientCredentialsGrant(w http.ResponseWriter, r *http.Request) {
+	clientID, secret := clientCredentials(r)
+	if clientID == "" || secret == "" {
+		writeOAuthError(w, http.StatusUnauthorized, OAuthErrInvalidClient, "Faltan las credenciales del cliente.")
+		return
+	}
+
+	client, err := h.Store.Authenticate(r.Context(), clientID, secret)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidClientCredentials) {
+			recordAudit(h.Audit, r, audit.Event{
+				Type:     audit.EVENT_SERVICE_TOKEN,
+				Outcome:  audit.OUTCOME_FAILURE,
+				Metadata: map[string]interface{}{"client_id": clientID, "reason": "invalid_client"},
+			})
+			writeOAuthError(w, http.StatusUnauthorized, OAuthErrInvalidClient, "Credenciales de cliente inválidas.")
+			return
+		}
+		writeOAuthError(w, http.StatusInternalServerError, OAuthErrServerError, "No se pudo validar el cliente.")
+		return
+	}
+
+	scopes := client.Scopes
+	if requested := strings.Fields(r.PostForm.Get("scope")); len(requested) > 0 {
+		for _, scope := range requested {
+			if !client.HasScope(scope) {
+				recordAudit(h.Audit, r, audit.Event{
+					Type:     audit.EVENT_SERVICE_TOKEN,
+					Outcome:  audit.OUTCOME_FAILURE,
+					ActorID:  &client.ID,
+					Metadata: map[string]interface{}{"client_id": client.ClientID, "reason": "invalid_scope", "scope": scope},
+				})
+				writeOAuthError(w, http.StatusBadRequest, OAuthErrInvalidScope, "Scope no permitido para este cliente: "+scope)
+				return
+			}
+		}
+		scopes = requested
+	}
+	scope := strings.Join(scopes, " ")
+
+	token, err := auth.GenerateServiceToken(client, scope, h.Config.ServiceTokenTTL, h.Config.JWTSecret)
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, OAuthErrServerError, "No se pudo emitir el token.")
+		return
+	}
+	metrics.ObserveTokenIssued("client_credentials")
+	recordAudit(h.Audit, r, audit.Event{
+		Type:     audit.EVENT_SERVICE_TOKEN,
+		Outcome:  audit.OUTCOME_SUCCESS,
+		ActorID:  &client.ID,
+		Metadata: map[string]interface{}{"client_id": client.ClientID, "scope": scope},
+	})
+
+	writeJSON(w, http.StatusOK, ServiceTokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(h.Config.ServiceTokenTTL.Seconds()),
+		Scope:       scope,
+	})
+}
+
+// CreateServiceClientHandler godoc
+// @Summary Crear una cuenta de servicio
+// @Description Registra una cuenta de servicio con los scopes indicados y devuelve su secreto. El secreto solo se muestra en esta respuesta.
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Param   body body CreateServiceClientRequest true "Datos de la cuenta de servicio"
+// @Success 201 {object} ServiceClientSecretResponse "Cuenta de servicio creada."
+// @Failure 400 {object} ErrorResponse "Payload de solicitud inválido o scope desconocido."
+// @Failure 403 {string} string "El usuario no es administrador."
+// @Failure 500 {object} ErrorResponse "No se pudo crear la cuenta de servicio."
+// @Router /api/v1/admin/service-clients [post]
+func (h *ServiceClientHandler) CreateServiceClientHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateServiceClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" || len(req.Scopes) == 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Payload de solicitud inválido."})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !models.ValidScope(scope) {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Scope desconocido: " + scope})
+			return
+		}
+	}
+
+	claims, _ := claimsFromContext(r)
+	client, secret, err := h.Store.Create(r.Context(), strings.TrimSpace(req.Name), req.Scopes, &claims.UserID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo crear la cuenta de servicio."})
+		return
+	}
+	recordAudit(h.Audit, r, audit.Event{
+		Type:     audit.EVENT_SERVICE_CLIENT_CREATE,
+		Outcome:  audit.OUTCOME_SUCCESS,
+		Metadata: map[string]interface{}{"service_client_id": client.ID, "client_id": client.ClientID, "scopes": client.Scopes},
+	})
+
+	writeJSON(w, http.StatusCreated, ServiceClientSecretResponse{Client: client, ClientSecret: secret})
+}
+
+// ListServiceClientsHandler godoc
+// @Summary Listar cuentas de servicio
+// @Description Devuelve todas las cuentas de servicio, incluidas las revocadas. Nunca incluye los secretos.
+// @Tags admin
+// @Produce  json
+// @Security BearerAuth
+// @Success 200 {array} models.ServiceClient "Cuentas de servicio."
+// @Failure 403 {string} string "El usuario no es administrador."
+// @Failure 500 {object} ErrorResponse "No se pudieron listar las cuentas de servicio."
+// @Router /api/v1/admin/service-clients [get]
+func (h *ServiceClientHandler) ListServiceClientsHandler(w http.ResponseWriter, r *http.Request) {
+	clients, err := h.Store.List(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudieron listar las cuentas de servicio."})
+		return
+	}
+	writeJSON(w, http.StatusOK, clients)
+}
+
+// RotateServiceClientHandler godoc
+// @Summary Rotar el secreto de una cuenta de servicio
+// @Description Genera un secreto nuevo; el anterior deja de servir para obtener tokens. Los tokens ya emitidos siguen vigentes hasta vencer.
+// @Tags admin
+// @Produce  json
+// @Security BearerAuth
+// @Param   id path string true "ID de la cuenta de servicio"
+// @Success 200 {object} ServiceClientSecretResponse "Secreto rotado."
+// @Failure 400 {object} ErrorResponse "ID inválido."
+// @Failure 404 {object} ErrorResponse "Cuenta de servicio no encontrada o revocada."
+// @Failure 500 {object} ErrorResponse "No se pudo rotar el secreto."
+// @Router /api/v1/admin/service-clients/{id}/rotate [post]
+func (h *ServiceClientHandler) RotateServiceClientHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID inválido."})
+		return
+	}
+
+	client, secret, err := h.Store.Rotate(r.Context(), id)
+	if err != nil {
+		writeError(w, err, "No se pudo rotar el secreto.")
+		return
+	}
+	recordAudit(h.Audit, r, audit.Event{
+		Type:     audit.EVENT_SERVICE_CLIENT_ROTATE,
+		Outcome:  audit.OUTCOME_SUCCESS,
+		Metadata: map[string]interface{}{"service_client_id": client.ID, "client_id": client.ClientID},
+	})
+
+	writeJSON(w, http.StatusOK, ServiceClientSecretResponse{Client: client, ClientSecret: secret})
+}
+
+// RevokeServiceClientHandler godoc
+// @Summary Revocar una cuenta de servicio
+// @Description Desactiva la cuenta de servicio. Sus tokens dejan de aceptarse de inmediato.
+// @Tags admin
+// @Produce  json
+// @Security BearerAuth
+// @Param   id path string true "ID de la cuenta de servicio"
+// @Success 200 {object} MessageResponse "Cuenta de servicio revocada."
+// @Failure 400 {object} ErrorResponse "ID inválido."
+// @Failure 404 {object} ErrorResponse "Cuenta de servicio no encontrada o ya revocada."
+// @Failure 500 {object} ErrorResponse "No se pudo revocar la cuenta de servicio."
+// @Router /api/v1/admin/service-clients/{id} [delete]
+func (h *ServiceClientHandler) RevokeServiceClientHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID inválido."})
+		return
+	}
+
+	if err := h.Store.Revoke(r.Context(), id); err != nil {
+		writeError(w, err, "No se pudo revocar la cuenta de servicio.")
+		return
+	}
+	recordAudit(h.Audit, r, audit.Event{
+		Type:     audit.EVENT_SERVICE_CLIENT_REVOKE,
+		Outcome:  audit.OUTCOME_SUCCESS,
+		Metadata: map[string]interface{}{"service_client_id": id},
+	})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Cuenta de servicio revocada."})
+}
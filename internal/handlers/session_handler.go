@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"component-4/internal/audit"
+	"component-4/internal/auth"
+	"component-4/internal/metrics"
+	"component-4/internal/models"
+	"component-4/internal/store"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// SessionResponse representa una sesión indicando si corresponde al token usado en la petición.
+type SessionResponse struct {
+	*models.Session
+	Current bool `json:"current"`
+}
+
+// SessionHandler contiene las dependencias para los manejadores de sesiones.
+type SessionHandler struct {
+	Store store.SessionRepository
+	Audit audit.Repository
+}
+
+// NewSessionHandler crea una nueva instancia de SessionHandler.
+func NewSessionHandler(s store.SessionRepository, a audit.Repository) *SessionHandler {
+	return &SessionHandler{Store: s, Audit: a}
+}
+
+// issueToken abre una sesión para el dispositivo de la petición y firma un token ligado a ella.
+func issueToken(sessions store.SessionRepository, secret string, r *http.Request, user *models.User, method models.AuthMethod) (string, error) {
+	session, err := sessions.Create(r.Context(), user.ID, r.UserAgent(), clientIP(r), method, auth.TokenExpiry())
+	if err != nil {
+		return "", err
+	}
+	token, err := auth.GenerateToken(user, session, secret)
+	if err != nil {
+		return "", err
+	}
+	metrics.ObserveTokenIssued(string(method))
+	return token, nil
+}
+
+func (h *SessionHandler) writeSessions(w http.ResponseWriter, r *http.Request, sessions []*models.Session) {
+	var current uuid.UUID
+	if claims, ok := claimsFromContext(r); ok {
+		current = claims.SessionID
+	}
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{Session: session, Current: session.ID == current})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *SessionHandler) revoke(w http.ResponseWriter, r *http.Request, userID *uuid.UUID) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID inválido."})
+		return
+	}
+	if err := h.Store.Revoke(r.Context(), id, userID); err != nil {
+		writeError(w, err, "No se pudo revocar la sesión.")
+		return
+	}
+	recordAudit(h.Audit, r, audit.Event{
+		Type:     audit.EVENT_SESSION_REVOKE,
+		Outcome:  audit.OUTCOME_SUCCESS,
+		Metadata: map[string]interface{}{"session_id": id},
+	})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Sesión revocada."})
+}
+
+// ListMySessionsHandler godoc
+// @Summary Listar las sesiones activas del usuario
+// @Description Devuelve los dispositivos con una sesión vigente, indicando cuál corresponde a la petición actual.
+// @Tags me
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} SessionResponse "Sesiones activas."
+// @Router /api/v1/me/sessions [get]
+func (h *SessionHandler) ListMySessionsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := claimsFromContext(r)
+	sessions, err := h.Store.ListActiveByUser(r.Context(), claims.UserID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudieron listar las sesiones."})
+		return
+	}
+	h.writeSessions(w, r, sessions)
+}
+
+// RevokeMySessionHandler godoc
+// @Summary Cerrar la sesión de un dispositivo
+// @Description Revoca una de las sesiones del usuario; los tokens emitidos para ella dejan de ser aceptados.
+// @Tags me
+// @Produce json
+// @Security BearerAuth
+// @Param   id path string true "ID de la sesión"
+// @Success 200 {object} MessageResponse "Sesión revocada."
+// @Failure 404 {object} ErrorResponse "Sesión no encontrada."
+// @Router /api/v1/me/sessions/{id} [delete]
+func (h *SessionHandler) RevokeMySessionHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := claimsFromContext(r)
+	h.revoke(w, r, &claims.UserID)
+}
+
+// ListUserSessionsHandler godoc
+// @Summary Listar las sesiones de un usuario
+// @Description Devuelve todas las sesiones del usuario indicado, incluidas las revocadas y expiradas.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param   id path string true "ID del usuario"
+// @Success 200 {array} SessionResponse "Sesiones del usuario."
+// @Failure 400 {object} ErrorResponse "ID inválido."
+// @Failure 403 {string} string "El usuario no es administrador."
+// @Router /api/v1/admin/users/{id}/sessions [get]
+func (h *SessionHandler) ListUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID inválido."})
+		return
+	}
+	sessions, err := h.Store.ListByUser(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudieron listar las sesiones."})
+		return
+	}
+	h.writeSessions(w, r, sessions)
+}
+
+// RevokeSessionHandler godoc
+// @Summary Revocar cualquier sesión
+// @Description Revoca la sesión indicada sin importar a qué usuario pertenece.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param   id path string true "ID de la sesión"
+// @Success 200 {object} MessageResponse "Sesión revocada."
+// @Failure 403 {string} string "El usuario no es administrador."
+// @Failure 404 {object} ErrorResponse "Sesión no encontrada."
+// @Router /api/v1/admin/sessions/{id} [delete]
+func (h *SessionHandler) RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	h.revoke(w, r, nil)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"component-4/internal/audit"
+	"component-4/internal/bulk"
+	"component-4/internal/store"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxImportSize limita el tamaño del archivo de importación.
+const maxImportSize = 10 << 20
+
+// UserAdminHandler contiene las dependencias para la administración del directorio de usuarios.
+type UserAdminHandler struct {
+	Store    store.UserRepository
+	Importer *bulk.Importer
+	Audit    audit.Repository
+}
+
+// NewUserAdminHandler crea una nueva instancia de UserAdminHandler.
+func NewUserAdminHandler(s store.UserRepository, importer *bulk.Importer, a audit.Repository) *UserAdminHandler {
+	return &UserAdminHandler{Store: s, Importer: importer, Audit: a}
+}
+
+// ImportUsersHandler godoc
+// @Summary Importar usuarios en lote
+// @Description Importa usuarios desde un CSV (columnas email, name, role y password opcional) o un arreglo JSON. Las filas cuyo email ya existe se omiten. Las filas sin contraseña reciben una invitación si `send_invitations=true`. Con `dry_run=true` solo se valida y se devuelve el reporte.
+// @Tags admin
+// @Accept  text/csv
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Param   format query string false "Formato del archivo: csv o json (por defecto según Content-Type)"
+// @Param   dry_run query bool false "Validar sin persistir cambios"
+// @Param   send_invitations query bool false "Enviar invitaciones a las filas sin contraseña"
+// @Success 200 {object} bulk.Report "Reporte de la importación."
+// @Failure 400 {object} ErrorResponse "Archivo o parámetros inválidos."
+// @Failure 403 {string} string "El usuario no es administrador."
+// @Failure 500 {object} ErrorResponse "No se pudo completar la importación."
+// @Router /api/v1/admin/users/import [post]
+func (h *UserAdminHandler) ImportUsersHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+		if strings.Contains(r.Header.Get("Content-Type"), "csv") {
+			format = "csv"
+		}
+	}
+	dryRun, _ := strconv.ParseBool(query.Get("dry_run"))
+	sendInvitations, _ := strconv.ParseBool(query.Get("send_invitations"))
+
+	rows, err := bulk.Parse(http.MaxBytesReader(w, r.Body, maxImportSize), format)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Archivo de importación inválido: " + err.Error()})
+		return
+	}
+
+	// Las invitaciones emitidas por una cuenta de servicio no tienen un usuario que las firme
+	opts := bulk.Options{DryRun: dryRun, SendInvitations: sendInvitations}
+	if claims, _ := claimsFromContext(r); !claims.IsService() {
+		opts.InvitedBy = &claims.UserID
+	}
+	report, err := h.Importer.Import(r.Context(), rows, opts)
+	if err != nil {
+		recordAudit(h.Audit, r, audit.Event{Type: audit.EVENT_USER_IMPORT, Outcome: audit.OUTCOME_FAILURE})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo completar la importación."})
+		return
+	}
+	recordAudit(h.Audit, r, audit.Event{
+		Type:    audit.EVENT_USER_IMPORT,
+		Outcome: audit.OUTCOME_SUCCESS,
+		Metadata: map[string]interface{}{
+			"dry_run": report.DryRun, "total": report.Total, "created": report.Created,
+			"invited": report.Invited, "skipped": report.Skipped, "invalid": report.Invalid, "failed": report.Failed,
+		},
+	})
+	writeJSON(w, http.StatusOK, report)
+}
+
+// ExportUsersHandler godoc
+// @Summary Exportar el directorio de usuarios
+// @Description Descarga todos los usuarios en CSV o JSON. Nunca incluye contraseñas.
+// @Tags admin
+// @Produce  text/csv
+// @Produce  json
+// @Security BearerAuth
+// @Param   format query string false "Formato de salida: csv o json (por defecto json)"
+// @Success 200 {array} models.User "Directorio de usuarios."
+// @Failure 400 {object} ErrorResponse "Formato inválido."
+// @Failure 403 {string} string "El usuario no es administrador."
+// @Failure 500 {object} ErrorResponse "No se pudo exportar el directorio."
+// @Router /api/v1/admin/users/export [get]
+func (h *UserAdminHandler) ExportUsersHandler(w http.ResponseWriter, r *http.Request) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "json"
+	}
+	contentType := map[string]string{"csv": "text/csv; charset=utf-8", "json": "application/json"}[format]
+	if contentType == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Formato inválido. Debe ser csv o json."})
+		return
+	}
+
+	users, err := h.Store.ListUsers(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "No se pudo exportar el directorio."})
+		return
+	}
+
+	recordAudit(h.Audit, r, audit.Event{
+		Type:     audit.EVENT_USER_EXPORT,
+		Outcome:  audit.OUTCOME_SUCCESS,
+		Metadata: map[string]interface{}{"format": format, "count": len(users)},
+	})
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=users."+format)
+	bulk.Export(w, users, format)
+}
+
+// AnonymizeUserHandler godoc
+// @Summary Anonimizar un usuario
+// @Description Reemplaza el email y el nombre del usuario por valores neutros y elimina sus credenciales y preferencias. La cuenta deja de permitir el inicio de sesión. Esta acción no se puede deshacer.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param   id path string true "ID del usuario"
+// @Success 200 {object} models.User "Usuario anonimizado."
+// @Failure 400 {object} ErrorResponse "ID inválido."
+// @Failure 403 {string} string "El usuario no es administrador."
+// @Failure 404 {object} ErrorResponse "Usuario no encontrado."
+// @Router /api/v1/admin/users/{id}/anonymize [post]
+func (h *UserAdminHandler) AnonymizeUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID inválido."})
+		return
+	}
+
+	user, err := h.Store.Anonymize(r.Context(), id)
+	outcome := audit.OUTCOME_SUCCESS
+	if err != nil {
+		outcome = audit.OUTCOME_FAILURE
+	}
+	recordAudit(h.Audit, r, audit.Event{Type: audit.EVENT_USER_ANONYMIZE, Outcome: outcome, TargetID: &id})
+	if err != nil {
+		writeError(w, err, "No se pudo anonimizar el usuario.")
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
@@ -0,0 +1,52 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+
+	"component-4/config"
+)
+
+// DatabaseCheck hace ping a la base de datos; el timeout lo impone el contexto del Checker.
+func DatabaseCheck(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// MigrationsCheck falla hasta que se llame a la función devuelta, que se invoca una vez aplicadas las migraciones.
+func MigrationsCheck() (Check, func()) {
+	var applied atomic.Bool
+	check := func(ctx context.Context) error {
+		if !applied.Load() {
+			return errors.New("migrations not applied")
+		}
+		return nil
+	}
+	return check, func() { applied.Store(true) }
+}
+
+// SigningKeysCheck comprueba que las claves de firma de tokens y de auditoría estén cargadas.
+func SigningKeysCheck(cfg *config.Config) Check {
+	return func(ctx context.Context) error {
+		if cfg.JWTSecret == "" {
+			return errors.New("JWT_SECRET not set")
+		}
+		if cfg.AuditSigningKey == "" {
+			return errors.New("AUDIT_SIGNING_KEY not set")
+		}
+		return nil
+	}
+}
+
+// GoogleConfigCheck comprueba que la configuración de OAuth de Google esté completa.
+func GoogleConfigCheck(cfg *config.Config) Check {
+	return func(ctx context.Context) error {
+		if cfg.GoogleClient == "" || cfg.GoogleSecret == "" || cfg.GoogleRedirect == "" {
+			return errors.New("GOOGLE_CLIENT_ID, GOOGLE_CLIENT_SECRET and GOOGLE_REDIRECT_URL are required")
+		}
+		return nil
+	}
+}
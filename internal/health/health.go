@@ -0,0 +1,128 @@
+// Package health implementa las sondas de liveness (/healthz) y readiness (/readyz).
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check comprueba un componente; devuelve un error si no está disponible.
+type Check func(ctx context.Context) error
+
+// Estados reportados por las sondas.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// ComponentStatus es el resultado de un check individual.
+type ComponentStatus struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Response es el cuerpo JSON de las sondas.
+type Response struct {
+	Status string                     `json:"status"`
+	Checks map[string]ComponentStatus `json:"checks,omitempty"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker agrupa los checks de readiness y el estado de apagado del servicio.
+type Checker struct {
+	timeout      time.Duration
+	mu           sync.RWMutex
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+}
+
+// NewChecker crea un Checker cuyos checks se ejecutan con el timeout indicado.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Register añade un check de readiness con el nombre con el que aparecerá en la respuesta.
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// SetShuttingDown marca el servicio como en apagado: desde ese momento /readyz falla para que el
+// balanceador deje de enviar tráfico mientras se drenan las conexiones abiertas.
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Run ejecuta todos los checks en paralelo y devuelve el resultado agregado.
+func (c *Checker) Run(ctx context.Context) Response {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	response := Response{Status: StatusOK, Checks: make(map[string]ComponentStatus, len(checks)+1)}
+	if c.shuttingDown.Load() {
+		response.Status = StatusFail
+		response.Checks["shutdown"] = ComponentStatus{Status: StatusFail, Error: "el servicio se está apagando"}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, nc := range checks {
+		wg.Add(1)
+		go func(nc namedCheck) {
+			defer wg.Done()
+			start := time.Now()
+			err := nc.check(ctx)
+			status := ComponentStatus{Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				status.Status = StatusFail
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			response.Checks[nc.name] = status
+			if err != nil {
+				response.Status = StatusFail
+			}
+		}(nc)
+	}
+	wg.Wait()
+	return response
+}
+
+// LivenessHandler responde 200 mientras el proceso pueda atender peticiones. No consulta
+// dependencias externas: una caída de la base de datos no debe provocar reinicios del contenedor.
+func (c *Checker) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, http.StatusOK, Response{Status: StatusOK})
+}
+
+// ReadinessHandler responde 200 si todos los checks pasan y 503 en caso contrario.
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	response := c.Run(r.Context())
+	status := http.StatusOK
+	if response.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeResponse(w, status, response)
+}
+
+func writeResponse(w http.ResponseWriter, status int, response Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"component-4/internal/audit"
+)
+
+// RunAuditChainer encadena periódicamente los eventos de auditoría que las peticiones guardaron sin
+// encadenar. Se ejecuta hasta que ctx se cancela.
+func RunAuditChainer(ctx context.Context, events *audit.Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := events.Chain(ctx); err != nil {
+			slog.Error("error encadenando eventos de auditoría", "error", err)
+		}
+	}
+}
+
+// RunAuditCheckpointer firma periódicamente el último eslabón de la cadena de auditoría, tras
+// encadenar los eventos pendientes para que el punto de control los cubra.
+// Se ejecuta hasta que ctx se cancela.
+func RunAuditCheckpointer(ctx context.Context, events *audit.Store, key []byte, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := events.Chain(ctx); err != nil {
+			slog.Error("error encadenando eventos de auditoría", "error", err)
+			continue
+		}
+		if _, err := events.Checkpoint(ctx, key); err != nil {
+			slog.Error("error firmando punto de control de auditoría", "error", err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"component-4/internal/store"
+)
+
+// RunDeletionPurger elimina periódicamente las cuentas cuyo periodo de gracia terminó.
+// Se ejecuta hasta que ctx se cancela.
+func RunDeletionPurger(ctx context.Context, users store.UserRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeDeletedUsers(ctx, users)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func purgeDeletedUsers(ctx context.Context, users store.UserRepository) {
+	n, err := users.PurgeScheduledDeletions(ctx, time.Now())
+	if err != nil {
+		slog.Error("error eliminando cuentas programadas", "error", err)
+		return
+	}
+	if n > 0 {
+		slog.Info("cuentas eliminadas definitivamente tras el periodo de gracia", "count", n)
+	}
+}
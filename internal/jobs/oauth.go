@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"component-4/internal/store"
+)
+
+// RunAuthorizationPurger elimina periódicamente las solicitudes de autorización OAuth vencidas,
+// que se acumulan porque /authorize no requiere autenticación. Se ejecuta hasta que ctx se cancela.
+func RunAuthorizationPurger(ctx context.Context, oauth store.OAuthRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeAuthorizations(ctx, oauth)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func purgeAuthorizations(ctx context.Context, oauth store.OAuthRepository) {
+	n, err := oauth.PurgeExpiredAuthorizations(ctx, time.Now())
+	if err != nil {
+		slog.Error("error eliminando solicitudes de autorización vencidas", "error", err)
+		return
+	}
+	if n > 0 {
+		slog.Info("solicitudes de autorización vencidas eliminadas", "count", n)
+	}
+}
@@ -0,0 +1,52 @@
+// Package logging configura el logger estructurado (log/slog) del servicio: salida JSON,
+// nivel configurable, loggers por petición con un request ID y enmascaramiento de secretos.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Setup instala como logger por defecto un logger JSON sobre la salida de error con el nivel indicado
+// ("debug", "info", "warn" o "error"). El paquete log estándar también queda redirigido a él, con el
+// enmascaramiento aplicado. Se usa stderr para no mezclar los logs con la salida de los subcomandos.
+func Setup(level string) *slog.Logger {
+	logger := New(os.Stderr, ParseLevel(level))
+	slog.SetDefault(logger)
+	return logger
+}
+
+// New crea un logger JSON que escribe en w y enmascara los valores sensibles.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	}))
+}
+
+// ParseLevel convierte el nombre de un nivel en slog.Level; los valores desconocidos equivalen a info.
+func ParseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+type loggerKey struct{}
+
+// WithLogger devuelve un contexto que transporta el logger dado.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext devuelve el logger de la petición, o el logger por defecto si el contexto no tiene uno.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
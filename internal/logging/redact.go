@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted sustituye a cualquier valor enmascarado.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys son los nombres de atributo cuyo valor nunca se registra.
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+	"code":          true,
+	"authorization": true,
+	"cookie":        true,
+	"jwt":           true,
+}
+
+// sensitiveSuffixes cubren variantes como access_token, client_secret o new_password.
+var sensitiveSuffixes = []string{"_password", "_secret", "_token", "_code"}
+
+var (
+	// JWT y Bearer: tokens incrustados en mensajes o errores.
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern = regexp.MustCompile(`(?i)bearer\s+\S+`)
+	// Parámetros de URL sensibles, p. ej. ?code=...&token=...
+	queryPattern = regexp.MustCompile(`(?i)([?&](?:code|token|access_token|id_token|refresh_token|password|secret|client_secret)=)[^&\s"]+`)
+)
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if sensitiveKeys[key] {
+		return true
+	}
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactString enmascara los tokens, códigos y credenciales que aparezcan dentro de s.
+func RedactString(s string) string {
+	s = jwtPattern.ReplaceAllString(s, Redacted)
+	s = bearerPattern.ReplaceAllString(s, "Bearer "+Redacted)
+	return queryPattern.ReplaceAllString(s, "${1}"+Redacted)
+}
+
+// redactAttr es el ReplaceAttr del handler: enmascara por nombre de atributo y, para el resto,
+// busca secretos dentro de los valores de texto (incluido el mensaje y los errores).
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, RedactString(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, RedactString(v.Error()))
+		case []byte:
+			return slog.String(a.Key, RedactString(string(v)))
+		}
+	}
+	return a
+}
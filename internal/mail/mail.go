@@ -0,0 +1,67 @@
+package mail
+
+import (
+	"fmt"
+	"log/slog"
+	"net/smtp"
+	"strings"
+
+	"component-4/config"
+)
+
+// Mailer envía correos electrónicos de texto plano.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// NewMailer devuelve un Mailer SMTP si hay un servidor configurado, o uno que solo registra los correos en el log.
+func NewMailer(cfg *config.Config) Mailer {
+	if cfg.SMTPHost == "" {
+		return logMailer{}
+	}
+	return &smtpMailer{
+		addr:     cfg.SMTPHost + ":" + cfg.SMTPPort,
+		host:     cfg.SMTPHost,
+		user:     cfg.SMTPUser,
+		password: cfg.SMTPPassword,
+		from:     cfg.SMTPFrom,
+	}
+}
+
+type smtpMailer struct {
+	addr     string
+	host     string
+	user     string
+	password string
+	from     string
+}
+
+func (m *smtpMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.user != "" {
+		auth = smtp.PlainAuth("", m.user, m.password, m.host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + m.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	if err := smtp.SendMail(m.addr, auth, m.from, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+	return nil
+}
+
+// logMailer se usa en desarrollo cuando no hay servidor SMTP configurado.
+type logMailer struct{}
+
+func (logMailer) Send(to, subject, body string) error {
+	slog.Info("correo no enviado: SMTP no configurado", "to", to, "subject", subject)
+	return nil
+}
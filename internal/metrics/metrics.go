@@ -0,0 +1,150 @@
+// Package metrics define las métricas Prometheus del servicio y el endpoint /metrics.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "auth"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Peticiones HTTP atendidas, por ruta, método y código de estado.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latencia de las peticiones HTTP, por ruta, método y código de estado.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	loginAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_attempts_total",
+		Help:      "Intentos de inicio de sesión, por método (native, google), resultado y motivo de fallo.",
+	}, []string{"method", "outcome", "reason"})
+
+	tokensIssued = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_issued_total",
+		Help:      "Tokens de sesión emitidos, por método de autenticación.",
+	}, []string{"method"})
+
+	tokenValidations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_validations_total",
+		Help:      "Validaciones de tokens de sesión, por resultado (valid, invalid, revoked, error).",
+	}, []string{"result"})
+
+	googleAPIDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "google_api_duration_seconds",
+		Help:      "Latencia de las llamadas a las APIs de Google, por operación y resultado.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "outcome"})
+)
+
+// Resultados de validación de tokens.
+const (
+	TokenValid   = "valid"
+	TokenInvalid = "invalid"
+	TokenRevoked = "revoked"
+	TokenError   = "error"
+)
+
+// Handler expone las métricas en formato Prometheus.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterDBStats publica las estadísticas del pool de conexiones (sql.DB.Stats) con la etiqueta db_name.
+func RegisterDBStats(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterReplicaUp publica auth_db_replica_up, 1 mientras la réplica de lectura está en rotación y 0 si no.
+func RegisterReplicaUp(name string, up func() bool) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        "db_replica_up",
+		Help:        "Si la réplica de lectura está en rotación (1) o sus lecturas van al primario (0).",
+		ConstLabels: prometheus.Labels{"replica": name},
+	}, func() float64 {
+		if up() {
+			return 1
+		}
+		return 0
+	}))
+}
+
+// ObserveLogin cuenta un intento de inicio de sesión. reason va vacío en los intentos exitosos.
+func ObserveLogin(method, outcome, reason string) {
+	loginAttempts.WithLabelValues(method, outcome, reason).Inc()
+}
+
+// ObserveTokenIssued cuenta un token de sesión emitido.
+func ObserveTokenIssued(method string) {
+	tokensIssued.WithLabelValues(method).Inc()
+}
+
+// ObserveTokenValidation cuenta una validación de token con su resultado.
+func ObserveTokenValidation(result string) {
+	tokenValidations.WithLabelValues(result).Inc()
+}
+
+// ObserveGoogleCall registra la duración de una llamada a Google iniciada en start.
+func ObserveGoogleCall(operation string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	googleAPIDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap permite a http.ResponseController llegar al ResponseWriter original.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Middleware mide las peticiones etiquetándolas con la plantilla de la ruta de mux
+// (p. ej. /api/v1/admin/sessions/{id}) para no crear una serie por cada ID.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+
+		status := strconv.Itoa(rec.status)
+		httpRequests.WithLabelValues(route, r.Method, status).Inc()
+		httpDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
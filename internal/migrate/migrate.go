@@ -1,33 +1,93 @@
 package migrate
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
-	"io/ioutil"
-	"log"
-	"path/filepath"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
 )
 
-// RunMigrations ejecuta todos los archivos .sql en el directorio de migraciones sobre la base de datos dada.
-func RunMigrations(db *sql.DB, migrationsDir string) error {
-	files, err := ioutil.ReadDir(migrationsDir)
+// fileName reconoce los archivos de migración: <versión>_<nombre>.up.sql y, opcionalmente, su
+// <versión>_<nombre>.down.sql, p. ej. 005_sessions.up.sql y 005_sessions.down.sql.
+var fileName = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration es un par de archivos de migración versionados. Down queda vacío si la migración no
+// tiene archivo .down.sql, en cuyo caso no puede revertirse.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Load lee las migraciones de la raíz de fsys ordenadas por versión. Los archivos .sql que no siguen el
+// formato esperado, las versiones repetidas y los .down.sql sin su .up.sql son un error.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q: expected <version>_<name>.up.sql or .down.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			sum := sha256.Sum256(content)
+			m.Up = string(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
 	}
 
-	for _, file := range files {
-		if filepath.Ext(file.Name()) == ".sql" {
-			content, err := ioutil.ReadFile(filepath.Join(migrationsDir, file.Name()))
-			if err != nil {
-				return err
-			}
-			log.Printf("Ejecutando migración: %s", file.Name())
-			_, err = db.Exec(string(content))
-			if err != nil {
-				return err
-			}
-			log.Printf("Migración %s completada", file.Name())
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Checksum == "" {
+			return nil, fmt.Errorf("migration %d (%s) has a down file but no up file", m.Version, m.Name)
 		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// RunMigrations aplica en orden las migraciones de fsys que aún no figuran en schema_migrations,
+// cada una en su propia transacción. Se niega a continuar si una migración aplicada cambió o ya no
+// existe, o si hay una migración pendiente con una versión anterior a la última aplicada.
+func RunMigrations(db *sql.DB, fsys fs.FS) error {
+	m, err := New(db, fsys)
+	if err != nil {
+		return err
 	}
-	log.Println("Todas las migraciones completadas exitosamente")
-	return nil
+	_, err = m.Up(context.Background(), 0)
+	return err
 }
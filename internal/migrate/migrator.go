@@ -0,0 +1,299 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// lockID identifica el advisory lock que serializa las migraciones entre réplicas que arrancan a la vez.
+const lockID = 7_042_001
+
+const createTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT PRIMARY KEY,
+    name TEXT NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// Estados de una migración en Status.
+const (
+	StateApplied  = "applied"
+	StatePending  = "pending"
+	StateModified = "modified" // aplicada, pero el archivo cambió después
+	StateMissing  = "missing"  // aplicada, pero su archivo ya no existe
+)
+
+// Status describe una migración conocida por los archivos o por schema_migrations.
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	State     string     `json:"state"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// applied es una migración registrada en schema_migrations.
+type applied struct {
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Migrator aplica y revierte un conjunto de migraciones sobre una base de datos.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New carga las migraciones de fsys, normalmente migrations.FS o os.DirFS de un directorio.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up aplica hasta n migraciones pendientes (todas si n <= 0) y devuelve cuántas aplicó.
+func (m *Migrator) Up(ctx context.Context, n int) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn, done map[int64]applied) error {
+		pending, err := plan(m.migrations, done)
+		if err != nil {
+			return err
+		}
+		if n > 0 && n < len(pending) {
+			pending = pending[:n]
+		}
+		for _, mig := range pending {
+			start := time.Now()
+			slog.Info("ejecutando migración", "version", mig.Version, "name", mig.Name)
+			if err := runInTx(ctx, conn, mig.Up,
+				`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+				mig.Version, mig.Name, mig.Checksum); err != nil {
+				return fmt.Errorf("error applying migration %d (%s): %w", mig.Version, mig.Name, err)
+			}
+			slog.Info("migración completada", "version", mig.Version, "name", mig.Name, "duration", time.Since(start))
+			count++
+		}
+		return nil
+	})
+	if err == nil {
+		slog.Info("todas las migraciones completadas exitosamente", "applied", count, "total", len(m.migrations))
+	}
+	return count, err
+}
+
+// Down revierte las n últimas migraciones aplicadas (al menos una) y devuelve cuántas revirtió.
+// Falla sin revertir nada más si una de ellas no tiene archivo .down.sql.
+func (m *Migrator) Down(ctx context.Context, n int) (int, error) {
+	if n <= 0 {
+		n = 1
+	}
+	files := m.byVersion()
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn, done map[int64]applied) error {
+		versions := make([]int64, 0, len(done))
+		for version := range done {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+		if n < len(versions) {
+			versions = versions[:n]
+		}
+
+		for _, version := range versions {
+			mig, ok := files[version]
+			if !ok {
+				return fmt.Errorf("migration %d (%s) is applied but its file is missing", version, done[version].Name)
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d (%s) has no down file and cannot be reverted", version, mig.Name)
+			}
+			start := time.Now()
+			slog.Info("revirtiendo migración", "version", version, "name", mig.Name)
+			if err := runInTx(ctx, conn, mig.Down, `DELETE FROM schema_migrations WHERE version = $1`, version); err != nil {
+				return fmt.Errorf("error reverting migration %d (%s): %w", version, mig.Name, err)
+			}
+			slog.Info("migración revertida", "version", version, "name", mig.Name, "duration", time.Since(start))
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status devuelve el estado de cada migración, ordenado por versión.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *sql.Conn, done map[int64]applied) error {
+		for _, mig := range m.migrations {
+			status := Status{Version: mig.Version, Name: mig.Name, State: StatePending}
+			if a, ok := done[mig.Version]; ok {
+				status.State = StateApplied
+				if a.Checksum != mig.Checksum {
+					status.State = StateModified
+				}
+				appliedAt := a.AppliedAt
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		files := m.byVersion()
+		for version, a := range done {
+			if _, ok := files[version]; !ok {
+				appliedAt := a.AppliedAt
+				statuses = append(statuses, Status{Version: version, Name: a.Name, State: StateMissing, AppliedAt: &appliedAt})
+			}
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, err
+}
+
+// Force reescribe schema_migrations para que figuren como aplicadas exactamente las migraciones con
+// versión <= version, con el checksum de su archivo actual, sin ejecutar ningún SQL. Sirve para
+// recuperarse tras una migración fallida corregida a mano o para aceptar un cambio de checksum.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if version != 0 {
+		if _, ok := m.byVersion()[version]; !ok {
+			return fmt.Errorf("unknown migration version %d", version)
+		}
+	}
+	return m.withLock(ctx, func(conn *sql.Conn, done map[int64]applied) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("error starting transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version > $1`, version); err != nil {
+			return fmt.Errorf("error updating schema_migrations: %w", err)
+		}
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)
+				 ON CONFLICT (version) DO UPDATE SET name = EXCLUDED.name, checksum = EXCLUDED.checksum`,
+				mig.Version, mig.Name, mig.Checksum)
+			if err != nil {
+				return fmt.Errorf("error updating schema_migrations: %w", err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("error committing transaction: %w", err)
+		}
+		slog.Warn("versión de migraciones forzada", "version", version)
+		return nil
+	})
+}
+
+func (m *Migrator) byVersion() map[int64]Migration {
+	files := make(map[int64]Migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		files[mig.Version] = mig
+	}
+	return files
+}
+
+// withLock toma el advisory lock de migraciones, se asegura de que exista schema_migrations y ejecuta
+// fn con el historial aplicado. El lock es de sesión, así que todo pasa por la misma conexión.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn, done map[int64]applied) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("error acquiring migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	if _, err := conn.ExecContext(ctx, createTable); err != nil {
+		return fmt.Errorf("error creating schema_migrations: %w", err)
+	}
+	done, err := loadApplied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, done)
+}
+
+// plan comprueba que el historial aplicado coincide con los archivos y devuelve las migraciones pendientes.
+func plan(migrations []Migration, done map[int64]applied) ([]Migration, error) {
+	files := make(map[int64]Migration, len(migrations))
+	for _, m := range migrations {
+		files[m.Version] = m
+	}
+
+	var latest int64
+	for version, a := range done {
+		m, ok := files[version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d (%s) is applied but its file is missing", version, a.Name)
+		}
+		if m.Checksum != a.Checksum {
+			return nil, fmt.Errorf("migration %d (%s) was modified after being applied: checksum %s, expected %s",
+				version, m.Name, m.Checksum, a.Checksum)
+		}
+		if version > latest {
+			latest = version
+		}
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if _, ok := done[m.Version]; ok {
+			continue
+		}
+		if m.Version < latest {
+			return nil, fmt.Errorf("migration %d (%s) is older than the latest applied migration %d", m.Version, m.Name, latest)
+		}
+		pending = append(pending, m)
+	}
+	return pending, nil
+}
+
+func loadApplied(ctx context.Context, conn *sql.Conn) (map[int64]applied, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := map[int64]applied{}
+	for rows.Next() {
+		var version int64
+		var a applied
+		if err := rows.Scan(&version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, fmt.Errorf("error reading schema_migrations: %w", err)
+		}
+		done[version] = a
+	}
+	return done, rows.Err()
+}
+
+// runInTx ejecuta el SQL de la migración y la sentencia que actualiza schema_migrations en la misma transacción.
+func runInTx(ctx context.Context, conn *sql.Conn, migrationSQL, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migrationSQL); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("error updating schema_migrations: %w", err)
+	}
+	return tx.Commit()
+}
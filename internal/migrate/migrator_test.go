@@ -0,0 +1,228 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+func testMigrations(t *testing.T, files map[string]string) []Migration {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	for name, content := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return migrations
+}
+
+func TestPlan(t *testing.T) {
+	migrations := testMigrations(t, map[string]string{
+		"001_users.up.sql":    "CREATE TABLE a (id INT)",
+		"002_sessions.up.sql": "CREATE TABLE b (id INT)",
+		"003_audit.up.sql":    "CREATE TABLE c (id INT)",
+	})
+	record := func(versions ...int64) map[int64]applied {
+		done := map[int64]applied{}
+		for _, v := range versions {
+			m := migrations[v-1]
+			done[v] = applied{Name: m.Name, Checksum: m.Checksum, AppliedAt: time.Now()}
+		}
+		return done
+	}
+
+	drifted := record(1, 2)
+	drifted[2] = applied{Name: "sessions", Checksum: "otro", AppliedAt: time.Now()}
+	missing := record(1)
+	missing[9] = applied{Name: "borrada", Checksum: "x", AppliedAt: time.Now()}
+
+	tests := []struct {
+		name    string
+		done    map[int64]applied
+		pending []int64
+		err     string
+	}{
+		{"vacío", record(), []int64{1, 2, 3}, ""},
+		{"parcial", record(1), []int64{2, 3}, ""},
+		{"al día", record(1, 2, 3), nil, ""},
+		{"fuera de orden", record(1, 3), nil, "older than the latest applied"},
+		{"checksum distinto", drifted, nil, "modified after being applied"},
+		{"archivo ausente", missing, nil, "file is missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pending, err := plan(migrations, tt.done)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, se esperaba %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("plan: %v", err)
+			}
+			if len(pending) != len(tt.pending) {
+				t.Fatalf("pendientes = %d, se esperaban %d", len(pending), len(tt.pending))
+			}
+			for i, m := range pending {
+				if m.Version != tt.pending[i] {
+					t.Fatalf("pendiente %d = versión %d, se esperaba %d", i, m.Version, tt.pending[i])
+				}
+			}
+		})
+	}
+}
+
+// testDB abre TEST_DATABASE_URL en un esquema propio y desechable, para que schema_migrations no se
+// mezcle con el de la aplicación. Devuelve nil si la variable no está definida.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		return nil
+	}
+	schema := "migrate_test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("CREATE SCHEMA: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+	switch {
+	case !strings.Contains(dsn, "://"):
+		dsn += " search_path=" + schema
+	case strings.Contains(dsn, "?"):
+		dsn += "&search_path=" + schema
+	default:
+		dsn += "?search_path=" + schema
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func appliedVersions(t *testing.T, m *Migrator) []int64 {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	var versions []int64
+	for _, s := range statuses {
+		if s.State != StatePending {
+			versions = append(versions, s.Version)
+		}
+	}
+	return versions
+}
+
+func newTestMigrator(t *testing.T, db *sql.DB, files map[string]string) *Migrator {
+	t.Helper()
+	return &Migrator{db: db, migrations: testMigrations(t, files)}
+}
+
+func TestMigratorBookkeeping(t *testing.T) {
+	db := testDB(t)
+	if db == nil {
+		t.Skip("TEST_DATABASE_URL no definida")
+	}
+	ctx := context.Background()
+	files := map[string]string{
+		"001_a.up.sql":   "CREATE TABLE a (id INT)",
+		"001_a.down.sql": "DROP TABLE a",
+		"002_b.up.sql":   "CREATE TABLE IF NOT EXISTS b (id INT)",
+	}
+	m := newTestMigrator(t, db, files)
+
+	if n, err := m.Up(ctx, 0); err != nil || n != 2 {
+		t.Fatalf("Up = %d, %v, se esperaba 2", n, err)
+	}
+	if n, err := m.Up(ctx, 0); err != nil || n != 0 {
+		t.Fatalf("segundo Up = %d, %v, se esperaba 0", n, err)
+	}
+
+	// 002 no tiene .down.sql: Down falla sin tocar el historial
+	if n, err := m.Down(ctx, 1); err == nil || n != 0 {
+		t.Fatalf("Down = %d, %v, se esperaba un error", n, err)
+	}
+	if got := appliedVersions(t, m); len(got) != 2 {
+		t.Fatalf("aplicadas = %v, se esperaban [1 2]", got)
+	}
+
+	// Force deja aplicada solo la 001 sin ejecutar SQL; después Down puede revertirla
+	if err := m.Force(ctx, 1); err != nil {
+		t.Fatalf("Force: %v", err)
+	}
+	if got := appliedVersions(t, m); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("aplicadas = %v, se esperaba [1]", got)
+	}
+	if n, err := m.Down(ctx, 1); err != nil || n != 1 {
+		t.Fatalf("Down = %d, %v, se esperaba 1", n, err)
+	}
+	if got := appliedVersions(t, m); len(got) != 0 {
+		t.Fatalf("aplicadas = %v, no se esperaba ninguna", got)
+	}
+	if _, err := db.Exec(`SELECT 1 FROM a`); err == nil {
+		t.Fatal("la tabla a sigue existiendo después de Down")
+	}
+	if err := m.Force(ctx, 7); err == nil {
+		t.Fatal("Force aceptó una versión desconocida")
+	}
+}
+
+func TestMigratorRefusesDrift(t *testing.T) {
+	db := testDB(t)
+	if db == nil {
+		t.Skip("TEST_DATABASE_URL no definida")
+	}
+	ctx := context.Background()
+	if _, err := newTestMigrator(t, db, map[string]string{
+		"001_a.up.sql": "CREATE TABLE a (id INT)",
+		"003_c.up.sql": "CREATE TABLE c (id INT)",
+	}).Up(ctx, 0); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	// Una migración nueva con versión anterior a la última aplicada
+	outOfOrder := newTestMigrator(t, db, map[string]string{
+		"001_a.up.sql": "CREATE TABLE a (id INT)",
+		"002_b.up.sql": "CREATE TABLE b (id INT)",
+		"003_c.up.sql": "CREATE TABLE c (id INT)",
+	})
+	if n, err := outOfOrder.Up(ctx, 0); err == nil || n != 0 {
+		t.Fatalf("Up fuera de orden = %d, %v, se esperaba un error", n, err)
+	}
+
+	// Un archivo aplicado que cambió después; Force acepta el nuevo checksum
+	drifted := newTestMigrator(t, db, map[string]string{
+		"001_a.up.sql": "CREATE TABLE a (id BIGINT)",
+		"003_c.up.sql": "CREATE TABLE c (id INT)",
+	})
+	if _, err := drifted.Up(ctx, 0); err == nil || !strings.Contains(err.Error(), "modified after being applied") {
+		t.Fatalf("Up con checksum distinto = %v, se esperaba un error", err)
+	}
+	if err := drifted.Force(ctx, 3); err != nil {
+		t.Fatalf("Force: %v", err)
+	}
+	if n, err := drifted.Up(ctx, 0); err != nil || n != 0 {
+		t.Fatalf("Up tras Force = %d, %v, se esperaba 0", n, err)
+	}
+}
@@ -0,0 +1,25 @@
+// internal/models/invitation.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Invitation representa una invitación firmada para que un miembro del personal se registre con un rol preasignado.
+type Invitation struct {
+	ID         uuid.UUID  `json:"id"`
+	Email      string     `json:"email"`
+	Role       Role       `json:"role"`
+	InvitedBy  *uuid.UUID `json:"invited_by,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// IsPending indica si la invitación todavía puede ser aceptada.
+func (i *Invitation) IsPending(now time.Time) bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && now.Before(i.ExpiresAt)
+}
@@ -0,0 +1,79 @@
+// internal/models/oauth.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes OIDC que pueden solicitar las aplicaciones cliente.
+const (
+	SCOPE_OPENID  = "openid"
+	SCOPE_PROFILE = "profile"
+	SCOPE_EMAIL   = "email"
+)
+
+// ValidOIDCScope indica si el scope es uno de los que admite el servidor de autorización.
+func ValidOIDCScope(scope string) bool {
+	switch scope {
+	case SCOPE_OPENID, SCOPE_PROFILE, SCOPE_EMAIL:
+		return true
+	}
+	return false
+}
+
+// AUTH_METHOD_OIDC identifica las sesiones abiertas por una aplicación cliente al canjear un código de autorización.
+const AUTH_METHOD_OIDC AuthMethod = "oidc"
+
+// OAuthClient es una aplicación registrada que usa el servicio como proveedor de identidad.
+// Las aplicaciones públicas (SPA, móviles) no tienen secreto y dependen solo de PKCE.
+type OAuthClient struct {
+	ID           uuid.UUID  `json:"id"`
+	ClientID     string     `json:"client_id"`
+	Name         string     `json:"name"`
+	Public       bool       `json:"public"`
+	RedirectURIs []string   `json:"redirect_uris"`
+	CreatedBy    *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IsActive indica si la aplicación puede seguir solicitando autorizaciones.
+func (c *OAuthClient) IsActive() bool {
+	return c.RevokedAt == nil
+}
+
+// AllowsRedirect indica si la URI de redirección está registrada exactamente para la aplicación.
+func (c *OAuthClient) AllowsRedirect(uri string) bool {
+	for _, allowed := range c.RedirectURIs {
+		if allowed == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorization es una solicitud de autorización con PKCE. Queda pendiente hasta que el usuario la
+// aprueba (UserID y el código quedan fijados) y se consume al canjear el código.
+type Authorization struct {
+	ID                  uuid.UUID  `json:"id"`
+	ClientID            uuid.UUID  `json:"client_id"`
+	RedirectURI         string     `json:"redirect_uri"`
+	Scope               string     `json:"scope"`
+	State               string     `json:"-"`
+	Nonce               string     `json:"-"`
+	CodeChallenge       string     `json:"-"`
+	CodeChallengeMethod string     `json:"-"`
+	UserID              *uuid.UUID `json:"user_id,omitempty"`
+	AuthTime            *time.Time `json:"auth_time,omitempty"`
+	SessionID           *uuid.UUID `json:"-"`
+	ExpiresAt           time.Time  `json:"expires_at"`
+	ConsumedAt          *time.Time `json:"consumed_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// IsPending indica si la solicitud todavía espera la decisión del usuario.
+func (a *Authorization) IsPending(now time.Time) bool {
+	return a.UserID == nil && a.ConsumedAt == nil && now.Before(a.ExpiresAt)
+}
@@ -0,0 +1,53 @@
+// internal/models/service_client.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes que pueden concederse a las cuentas de servicio.
+const (
+	SCOPE_USERS_READ        = "users:read"
+	SCOPE_USERS_WRITE       = "users:write"
+	SCOPE_AUDIT_READ        = "audit:read"
+	SCOPE_TOKENS_INTROSPECT = "tokens:introspect"
+)
+
+// ValidScope indica si el scope es uno de los conocidos por el sistema.
+func ValidScope(scope string) bool {
+	switch scope {
+	case SCOPE_USERS_READ, SCOPE_USERS_WRITE, SCOPE_AUDIT_READ, SCOPE_TOKENS_INTROSPECT:
+		return true
+	}
+	return false
+}
+
+// ServiceClient es una cuenta de servicio (p. ej. la sincronización de notas) que se autentica con
+// client_id y secreto para obtener tokens sin un usuario humano.
+type ServiceClient struct {
+	ID        uuid.UUID  `json:"id"`
+	ClientID  string     `json:"client_id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	CreatedBy *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IsActive indica si la cuenta de servicio puede obtener y usar tokens.
+func (c *ServiceClient) IsActive() bool {
+	return c.RevokedAt == nil
+}
+
+// HasScope indica si la cuenta de servicio tiene concedido el scope.
+func (c *ServiceClient) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+// internal/models/session.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuthMethod identifica cómo se autenticó el usuario al abrir una sesión.
+type AuthMethod string
+
+const (
+	AUTH_METHOD_NATIVE     AuthMethod = "native"
+	AUTH_METHOD_GOOGLE     AuthMethod = "google"
+	AUTH_METHOD_INVITATION AuthMethod = "invitation"
+)
+
+// Session representa un token emitido a un dispositivo concreto.
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	AuthMethod AuthMethod `json:"auth_method"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IsActive indica si la sesión todavía autoriza peticiones.
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
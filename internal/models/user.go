@@ -2,6 +2,7 @@
 package models
 
 import (
+    "encoding/json"
     "time"
     "github.com/google/uuid"
 )
@@ -14,13 +15,25 @@ const (
     ROLE_ESTUDIANTE   Role = "estudiante"
 )
 
+// ValidRole indica si el rol es uno de los roles conocidos por el sistema.
+func ValidRole(role Role) bool {
+    switch role {
+    case ROLE_ADMINISTRADOR, ROLE_PROFESOR, ROLE_ESTUDIANTE:
+        return true
+    }
+    return false
+}
+
 type User struct {
-    ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
-    Email     string    `json:"email" gorm:"uniqueIndex"`
-    Name      string    `json:"name"`
-    Password  *string   `json:"-"`
-    Role      Role      `json:"role"`
-    GoogleID  *string   `json:"google_id,omitempty"`
-    CreatedAt time.Time `json:"created_at"`
-    UpdatedAt time.Time `json:"updated_at"`
+    ID                  uuid.UUID       `json:"id" gorm:"type:uuid;primary_key"`
+    Email               string          `json:"email" gorm:"uniqueIndex"`
+    Name                string          `json:"name"`
+    Password            *string         `json:"-"`
+    Role                Role            `json:"role"`
+    GoogleID            *string         `json:"google_id,omitempty"`
+    Preferences         json.RawMessage `json:"preferences,omitempty" swaggertype:"object"`
+    DeletionScheduledAt *time.Time      `json:"deletion_scheduled_at,omitempty"`
+    AnonymizedAt        *time.Time      `json:"anonymized_at,omitempty"`
+    CreatedAt           time.Time       `json:"created_at"`
+    UpdatedAt           time.Time       `json:"updated_at"`
 }
\ No newline at end of file
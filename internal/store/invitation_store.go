@@ -0,0 +1,267 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"component-4/internal/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvitationNotFound indica que la invitación no existe.
+	ErrInvitationNotFound = errors.New("invitation not found")
+	// ErrInvitationNotPending indica que la invitación ya fue aceptada, revocada o expiró.
+	ErrInvitationNotPending = errors.New("invitation is no longer pending")
+)
+
+const invitationColumns = `id, email, role, invited_by, expires_at, accepted_at, revoked_at, created_at`
+
+// InvitationRepository es el almacén de invitaciones del que dependen los handlers y la importación
+// masiva. InvitationStore lo implementa sobre Postgres.
+type InvitationRepository interface {
+	Create(ctx context.Context, email string, role models.Role, invitedBy *uuid.UUID, ttl time.Duration) (*models.Invitation, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*models.Invitation, error)
+	HasPending(ctx context.Context, email string) (bool, error)
+	ListPending(ctx context.Context) ([]*models.Invitation, error)
+	Revoke(ctx context.Context, id uuid.UUID) error
+	AcceptNative(ctx context.Context, id uuid.UUID, name, password string) (*models.User, error)
+	AcceptGoogle(ctx context.Context, id uuid.UUID, name, googleID string) (*models.User, error)
+}
+
+var _ InvitationRepository = (*InvitationStore)(nil)
+
+// InvitationStore gestiona las invitaciones de incorporación del personal.
+type InvitationStore struct {
+	db       *sql.DB
+	replicas *ReplicaSet
+	timeout  time.Duration
+}
+
+// NewInvitationStore crea un InvitationStore sobre la conexión dada. timeout limita la duración de cada operación
+// (0 = sin límite propio, solo el del contexto). replicas (que puede ser nil) es el de UserStore: al
+// aceptar una invitación, las lecturas del usuario creado van al primario como tras un registro.
+func NewInvitationStore(db *sql.DB, replicas *ReplicaSet, timeout time.Duration) *InvitationStore {
+	return &InvitationStore{db: db, replicas: replicas, timeout: timeout}
+}
+
+func scanInvitation(row rowScanner) (*models.Invitation, error) {
+	inv := &models.Invitation{}
+	var role string
+	var invitedBy uuid.NullUUID
+	var acceptedAt, revokedAt sql.NullTime
+	err := row.Scan(&inv.ID, &inv.Email, &role, &invitedBy, &inv.ExpiresAt, &acceptedAt, &revokedAt, &inv.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	inv.Role = models.Role(role)
+	if invitedBy.Valid {
+		inv.InvitedBy = &invitedBy.UUID
+	}
+	if acceptedAt.Valid {
+		inv.AcceptedAt = &acceptedAt.Time
+	}
+	if revokedAt.Valid {
+		inv.RevokedAt = &revokedAt.Time
+	}
+	return inv, nil
+}
+
+// Create registra una invitación nueva. Cualquier invitación pendiente previa para el mismo
+// email queda revocada, de modo que reenviar una invitación invalida el token anterior.
+// invitedBy es nil cuando la invitación no la emite un usuario (p. ej. desde la CLI).
+// El email se guarda en minúsculas y se compara sin distinguir mayúsculas con los usuarios y las
+// invitaciones existentes.
+func (s *InvitationStore) Create(ctx context.Context, email string, role models.Role, invitedBy *uuid.UUID, ttl time.Duration) (*models.Invitation, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	email = normalizeEmail(email)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE lower(email) = $1)", email).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("error checking email existence: %w", err)
+	}
+	if exists {
+		return nil, ErrEmailTaken
+	}
+
+	now := time.Now()
+	_, err = tx.ExecContext(ctx,
+		`UPDATE invitations SET revoked_at = $1
+		 WHERE lower(email) = $2 AND accepted_at IS NULL AND revoked_at IS NULL`,
+		now, email,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error revoking previous invitations: %w", err)
+	}
+
+	inv := &models.Invitation{
+		ID:        uuid.New(),
+		Email:     email,
+		Role:      role,
+		InvitedBy: invitedBy,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO invitations (id, email, role, invited_by, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		inv.ID, inv.Email, string(inv.Role), invitedBy, inv.ExpiresAt, inv.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating invitation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return inv, nil
+}
+
+// FindByID busca una invitación por su identificador.
+func (s *InvitationStore) FindByID(ctx context.Context, id uuid.UUID) (*models.Invitation, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	inv, err := scanInvitation(s.db.QueryRowContext(ctx,
+		`SELECT `+invitationColumns+` FROM invitations WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("error finding invitation: %w", err)
+	}
+	return inv, nil
+}
+
+// HasPending indica si existe una invitación vigente para el email.
+func (s *InvitationStore) HasPending(ctx context.Context, email string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM invitations
+		 WHERE email = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > $2)`,
+		normalizeEmail(email), time.Now(),
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("error checking pending invitations: %w", err)
+	}
+	return exists, nil
+}
+
+// ListPending devuelve las invitaciones que aún pueden ser aceptadas, de la más reciente a la más antigua.
+func (s *InvitationStore) ListPending(ctx context.Context) ([]*models.Invitation, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+invitationColumns+` FROM invitations
+		 WHERE accepted_at IS NULL AND revoked_at IS NULL AND expires_at > $1
+		 ORDER BY created_at DESC`, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error listing invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := []*models.Invitation{}
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error reading invitation: %w", err)
+		}
+		invitations = append(invitations, inv)
+	}
+	return invitations, rows.Err()
+}
+
+// Revoke invalida una invitación pendiente.
+func (s *InvitationStore) Revoke(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE invitations SET revoked_at = $1
+		 WHERE id = $2 AND accepted_at IS NULL AND revoked_at IS NULL`,
+		time.Now(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("error revoking invitation: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := s.FindByID(ctx, id); err != nil {
+			return err
+		}
+		return ErrInvitationNotPending
+	}
+	return nil
+}
+
+// AcceptNative crea un usuario con contraseña a partir de la invitación y la marca como aceptada.
+func (s *InvitationStore) AcceptNative(ctx context.Context, id uuid.UUID, name, password string) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.accept(ctx, id, func(tx *sql.Tx, inv *models.Invitation) (*models.User, error) {
+		return createNativeUser(ctx, tx, inv.Email, name, password, inv.Role)
+	})
+}
+
+// AcceptGoogle crea un usuario vinculado a Google a partir de la invitación y la marca como aceptada.
+func (s *InvitationStore) AcceptGoogle(ctx context.Context, id uuid.UUID, name, googleID string) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.accept(ctx, id, func(tx *sql.Tx, inv *models.Invitation) (*models.User, error) {
+		return createGoogleUser(ctx, tx, inv.Email, name, googleID, inv.Role)
+	})
+}
+
+// accept bloquea la invitación, crea el usuario con el rol preasignado y la marca como aceptada en una sola transacción.
+func (s *InvitationStore) accept(ctx context.Context, id uuid.UUID, create func(*sql.Tx, *models.Invitation) (*models.User, error)) (*models.User, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	inv, err := scanInvitation(tx.QueryRowContext(ctx,
+		`SELECT `+invitationColumns+` FROM invitations WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("error finding invitation: %w", err)
+	}
+	now := time.Now()
+	if !inv.IsPending(now) {
+		return nil, ErrInvitationNotPending
+	}
+
+	user, err := create(tx, inv)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE invitations SET accepted_at = $1 WHERE id = $2`, now, id); err != nil {
+		return nil, fmt.Errorf("error accepting invitation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	s.replicas.markWritten(userKey(user.ID), emailKey(user.Email))
+	return user, nil
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"component-4/internal/models"
+)
+
+func TestInvitationCreateIgnoresEmailCase(t *testing.T) {
+	db := testPostgres(t)
+	if db == nil {
+		t.Skip("TEST_DATABASE_URL no definida")
+	}
+	invitations := NewInvitationStore(db, nil, 5*time.Second)
+	users := NewUserStore(db, nil, 5*time.Second)
+	ctx := context.Background()
+
+	email := uniqueEmail("Docente")
+	inv, err := invitations.Create(ctx, "  "+strings.ToUpper(email)+" ", models.ROLE_PROFESOR, nil, time.Hour)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if inv.Email != strings.ToLower(email) {
+		t.Fatalf("email de la invitación = %q, se esperaba %q", inv.Email, strings.ToLower(email))
+	}
+
+	// Reinvitar con otras mayúsculas revoca la invitación anterior
+	again, err := invitations.Create(ctx, email, models.ROLE_PROFESOR, nil, time.Hour)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	previous, err := invitations.FindByID(ctx, inv.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if previous.RevokedAt == nil {
+		t.Fatal("la invitación anterior sigue pendiente")
+	}
+
+	if _, err := invitations.AcceptNative(ctx, again.ID, "Ana", "secreta123"); err != nil {
+		t.Fatalf("AcceptNative: %v", err)
+	}
+	if _, err := users.FindByEmail(ctx, strings.ToLower(email)); err != nil {
+		t.Fatalf("FindByEmail: %v", err)
+	}
+
+	// Un usuario existente bloquea la invitación aunque cambien las mayúsculas
+	if _, err := invitations.Create(ctx, strings.ToUpper(email), models.ROLE_PROFESOR, nil, time.Hour); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("Create con un usuario existente: err = %v, se esperaba ErrEmailTaken", err)
+	}
+}
+
+// TestInvitationAcceptThenGoogleLogin reproduce el alta de un profesor invitado con un email en
+// mayúsculas que después entra con Google: debe reutilizar su cuenta en lugar de crear otra de
+// estudiante.
+func TestInvitationAcceptThenGoogleLogin(t *testing.T) {
+	db := testPostgres(t)
+	if db == nil {
+		t.Skip("TEST_DATABASE_URL no definida")
+	}
+	invitations := NewInvitationStore(db, nil, 5*time.Second)
+	users := NewUserStore(db, nil, 5*time.Second)
+	ctx := context.Background()
+
+	email := uniqueEmail("Profesor.Nuevo")
+	inv, err := invitations.Create(ctx, email, models.ROLE_PROFESOR, nil, time.Hour)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	accepted, err := invitations.AcceptNative(ctx, inv.ID, "Ana", "secreta123")
+	if err != nil {
+		t.Fatalf("AcceptNative: %v", err)
+	}
+
+	user, err := users.UpsertGoogleUser(ctx, email, "Ana", "google-"+email, models.ROLE_ESTUDIANTE)
+	if err != nil {
+		t.Fatalf("UpsertGoogleUser: %v", err)
+	}
+	if user.ID != accepted.ID || user.Role != models.ROLE_PROFESOR {
+		t.Fatalf("UpsertGoogleUser devolvió %s (%s), se esperaba %s (%s)", user.ID, user.Role, accepted.ID, models.ROLE_PROFESOR)
+	}
+	if n := countByEmail(t, users, strings.ToLower(email)); n != 1 {
+		t.Fatalf("hay %d usuarios con el email %s, se esperaba 1", n, email)
+	}
+
+	pending, err := invitations.HasPending(ctx, strings.ToUpper(email))
+	if err != nil {
+		t.Fatalf("HasPending: %v", err)
+	}
+	if pending {
+		t.Fatal("la invitación aceptada sigue pendiente")
+	}
+}
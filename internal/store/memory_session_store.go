@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"component-4/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemorySessionStore implementa SessionRepository en memoria. Como MemoryUserStore, sirve para
+// probar los handlers y AuthMiddleware sin base de datos y devuelve copias de las sesiones.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[uuid.UUID]*models.Session
+}
+
+// NewMemorySessionStore crea un MemorySessionStore vacío.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[uuid.UUID]*models.Session{}}
+}
+
+func (s *MemorySessionStore) Create(ctx context.Context, userID uuid.UUID, userAgent, ip string, method models.AuthMethod, expiresAt time.Time) (*models.Session, error) {
+	now := time.Now()
+	session := &models.Session{
+		ID:         uuid.New(),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		AuthMethod: method,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.ID] = session
+	return cloneSession(session), nil
+}
+
+func (s *MemorySessionStore) FindByID(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return cloneSession(session), nil
+}
+
+// Validate indica si la sesión sigue activa y, de ser así, actualiza su última actividad.
+func (s *MemorySessionStore) Validate(ctx context.Context, id uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	now := time.Now()
+	if !ok || !session.IsActive(now) {
+		return false, nil
+	}
+	if now.Sub(session.LastSeenAt) >= lastSeenResolution {
+		session.LastSeenAt = now
+	}
+	return true, nil
+}
+
+// ListActiveByUser devuelve las sesiones vigentes del usuario, de la más reciente a la más antigua.
+func (s *MemorySessionStore) ListActiveByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	now := time.Now()
+	sessions := s.list(func(session *models.Session) bool {
+		return session.UserID == userID && session.IsActive(now)
+	})
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+// ListByUser devuelve todas las sesiones del usuario, incluidas las revocadas y expiradas.
+func (s *MemorySessionStore) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	sessions := s.list(func(session *models.Session) bool {
+		return session.UserID == userID
+	})
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+func (s *MemorySessionStore) list(match func(*models.Session) bool) []*models.Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions := []*models.Session{}
+	for _, session := range s.sessions {
+		if match(session) {
+			sessions = append(sessions, cloneSession(session))
+		}
+	}
+	return sessions
+}
+
+// Revoke invalida una sesión. Si userID no es nil, la sesión debe pertenecer a ese usuario.
+func (s *MemorySessionStore) Revoke(ctx context.Context, id uuid.UUID, userID *uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok || (userID != nil && session.UserID != *userID) {
+		return ErrSessionNotFound
+	}
+	if session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+	}
+	return nil
+}
+
+// RevokeAllByUser invalida todas las sesiones vigentes del usuario.
+func (s *MemorySessionStore) RevokeAllByUser(ctx context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			revokedAt := now
+			session.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+func cloneSession(session *models.Session) *models.Session {
+	c := *session
+	if session.RevokedAt != nil {
+		at := *session.RevokedAt
+		c.RevokedAt = &at
+	}
+	return &c
+}
@@ -0,0 +1,293 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"component-4/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemoryUserStore implementa UserRepository en memoria. Está pensado para probar los handlers sin
+// base de datos: no persiste nada y devuelve copias, de modo que modificar un usuario devuelto no
+// altera el store.
+type MemoryUserStore struct {
+	mu    sync.Mutex
+	users map[uuid.UUID]*models.User
+}
+
+// NewMemoryUserStore crea un MemoryUserStore vacío.
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: map[uuid.UUID]*models.User{}}
+}
+
+// Close no hace nada; existe para cumplir UserRepository.
+func (s *MemoryUserStore) Close() error {
+	return nil
+}
+
+func (s *MemoryUserStore) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user := s.byEmail(email)
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return cloneUser(user), nil
+}
+
+func (s *MemoryUserStore) FindByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return cloneUser(user), nil
+}
+
+// ListUsers devuelve todos los usuarios ordenados por fecha de creación.
+func (s *MemoryUserStore) ListUsers(ctx context.Context) ([]*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := make([]*models.User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, cloneUser(user))
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].CreatedAt.Before(users[j].CreatedAt)
+		}
+		return users[i].Email < users[j].Email
+	})
+	return users, nil
+}
+
+func (s *MemoryUserStore) CreateNativeUser(ctx context.Context, email, name, password string, role models.Role) (*models.User, error) {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.insert(&models.User{Email: email, Name: name, Password: &hash, Role: role})
+}
+
+func (s *MemoryUserStore) CreateGoogleUser(ctx context.Context, email, name, googleID string, role models.Role) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.insert(&models.User{Email: email, Name: name, GoogleID: &googleID, Role: role})
+}
+
+// CreateNativeUsers crea un lote de usuarios; los emails que ya existen se omiten. Con dryRun no se guarda nada.
+func (s *MemoryUserStore) CreateNativeUsers(ctx context.Context, users []NewNativeUser, dryRun bool) ([]BatchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := make(map[uuid.UUID]*models.User, len(s.users))
+	for id, user := range s.users {
+		snapshot[id] = user
+	}
+
+	results := make([]BatchResult, len(users))
+	for i, u := range users {
+		results[i].Email = u.Email
+		if s.byEmail(u.Email) != nil {
+			continue
+		}
+		hash, err := hashPassword(u.Password)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		if _, err := s.insert(&models.User{Email: u.Email, Name: u.Name, Password: &hash, Role: u.Role}); err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Created = true
+	}
+
+	if dryRun {
+		s.users = snapshot
+	}
+	return results, nil
+}
+
+// UpsertGoogleUser crea el usuario de Google con el rol dado o, si el email ya existe, lo vincula y
+// sincroniza su nombre sin cambiar su rol.
+func (s *MemoryUserStore) UpsertGoogleUser(ctx context.Context, email, name, googleID string, role models.Role) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user := s.byEmail(email)
+	if user == nil {
+		return s.insert(&models.User{Email: email, Name: name, GoogleID: &googleID, Role: role})
+	}
+	if user.GoogleID != nil && user.Name == name {
+		return cloneUser(user), nil
+	}
+	if user.GoogleID == nil {
+		user.GoogleID = &googleID
+	}
+	user.Name = name
+	user.UpdatedAt = time.Now()
+	return cloneUser(user), nil
+}
+
+func (s *MemoryUserStore) SetPassword(ctx context.Context, email, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user := s.byEmail(email)
+	if user == nil {
+		return ErrUserNotFound
+	}
+	user.Password = &hash
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// UpdateProfile actualiza el nombre (si no es nil) y fusiona las preferencias dadas con las existentes.
+func (s *MemoryUserStore) UpdateProfile(ctx context.Context, id uuid.UUID, name *string, preferences json.RawMessage) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	merged, err := mergePreferences(user.Preferences, preferences)
+	if err != nil {
+		return nil, err
+	}
+	if name != nil {
+		user.Name = *name
+	}
+	user.Preferences = merged
+	user.UpdatedAt = time.Now()
+	return cloneUser(user), nil
+}
+
+// UpdateEmail cambia el email del usuario solo si su email actual sigue siendo oldEmail.
+func (s *MemoryUserStore) UpdateEmail(ctx context.Context, id uuid.UUID, oldEmail, newEmail string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byEmail(newEmail) != nil {
+		return nil, ErrEmailTaken
+	}
+	user, ok := s.users[id]
+	if !ok || user.Email != normalizeEmail(oldEmail) {
+		return nil, ErrUserNotFound
+	}
+	user.Email = normalizeEmail(newEmail)
+	user.UpdatedAt = time.Now()
+	return cloneUser(user), nil
+}
+
+// ScheduleDeletion marca la cuenta para ser eliminada definitivamente en la fecha indicada.
+func (s *MemoryUserStore) ScheduleDeletion(ctx context.Context, id uuid.UUID, at time.Time) (*models.User, error) {
+	return s.update(id, func(user *models.User) {
+		user.DeletionScheduledAt = &at
+	})
+}
+
+// CancelDeletion anula una eliminación programada que aún no se ha ejecutado.
+func (s *MemoryUserStore) CancelDeletion(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return s.update(id, func(user *models.User) {
+		user.DeletionScheduledAt = nil
+	})
+}
+
+// PurgeScheduledDeletions elimina las cuentas cuyo periodo de gracia terminó antes de now.
+func (s *MemoryUserStore) PurgeScheduledDeletions(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for id, user := range s.users {
+		if user.DeletionScheduledAt != nil && !user.DeletionScheduledAt.After(now) {
+			delete(s.users, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Anonymize reemplaza los datos personales del usuario por valores neutros y elimina sus credenciales.
+func (s *MemoryUserStore) Anonymize(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return s.update(id, func(user *models.User) {
+		now := time.Now()
+		user.Email = anonymizedEmail(id)
+		user.Name = anonymizedName
+		user.Password = nil
+		user.GoogleID = nil
+		user.Preferences = json.RawMessage(`{}`)
+		user.DeletionScheduledAt = nil
+		user.AnonymizedAt = &now
+	})
+}
+
+// update aplica fn al usuario y actualiza su updated_at.
+func (s *MemoryUserStore) update(id uuid.UUID, fn func(user *models.User)) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	fn(user)
+	user.UpdatedAt = time.Now()
+	return cloneUser(user), nil
+}
+
+// insert guarda un usuario nuevo. Debe llamarse con s.mu tomado.
+func (s *MemoryUserStore) insert(user *models.User) (*models.User, error) {
+	user.Email = normalizeEmail(user.Email)
+	if s.byEmail(user.Email) != nil {
+		return nil, ErrEmailTaken
+	}
+	now := time.Now()
+	user.ID = uuid.New()
+	user.Preferences = json.RawMessage(`{}`)
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	s.users[user.ID] = user
+	return cloneUser(user), nil
+}
+
+// byEmail busca un usuario por email, sin distinguir mayúsculas. Debe llamarse con s.mu tomado.
+func (s *MemoryUserStore) byEmail(email string) *models.User {
+	email = normalizeEmail(email)
+	for _, user := range s.users {
+		if user.Email == email {
+			return user
+		}
+	}
+	return nil
+}
+
+// cloneUser copia el usuario para que quien lo recibe no comparta punteros con el store.
+func cloneUser(user *models.User) *models.User {
+	c := *user
+	if user.Password != nil {
+		password := *user.Password
+		c.Password = &password
+	}
+	if user.GoogleID != nil {
+		googleID := *user.GoogleID
+		c.GoogleID = &googleID
+	}
+	if user.DeletionScheduledAt != nil {
+		at := *user.DeletionScheduledAt
+		c.DeletionScheduledAt = &at
+	}
+	if user.AnonymizedAt != nil {
+		at := *user.AnonymizedAt
+		c.AnonymizedAt = &at
+	}
+	c.Preferences = append(json.RawMessage(nil), user.Preferences...)
+	return &c
+}
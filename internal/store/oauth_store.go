@@ -0,0 +1,453 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"component-4/internal/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrOAuthClientNotFound indica que la aplicación cliente no existe.
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+	// ErrAuthorizationNotFound indica que la solicitud de autorización no existe.
+	ErrAuthorizationNotFound = errors.New("authorization not found")
+	// ErrAuthorizationNotPending indica que la solicitud ya fue aprobada, rechazada o expiró.
+	ErrAuthorizationNotPending = errors.New("authorization is no longer pending")
+	// ErrInvalidAuthorizationCode indica que el código no existe o expiró.
+	ErrInvalidAuthorizationCode = errors.New("invalid authorization code")
+	// ErrAuthorizationCodeReused indica que el código ya se había canjeado.
+	ErrAuthorizationCodeReused = errors.New("authorization code already used")
+)
+
+const oauthClientColumns = `id, client_id, name, secret_hash IS NULL, redirect_uris, created_by, created_at, revoked_at`
+
+const authorizationColumns = `id, client_id, redirect_uri, scope, state, nonce, code_challenge, code_challenge_method,
+	user_id, auth_time, session_id, expires_at, consumed_at, created_at`
+
+// OAuthRepository es el almacén del servidor de autorización del que dependen los handlers de OAuth
+// y OIDC y el job de limpieza. OAuthStore lo implementa sobre Postgres.
+type OAuthRepository interface {
+	CreateClient(ctx context.Context, name string, redirectURIs []string, public bool, createdBy *uuid.UUID) (*models.OAuthClient, string, error)
+	FindClient(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	FindClientByID(ctx context.Context, id uuid.UUID) (*models.OAuthClient, error)
+	ListClients(ctx context.Context) ([]*models.OAuthClient, error)
+	RevokeClient(ctx context.Context, id uuid.UUID) error
+	AuthenticateClient(ctx context.Context, clientID, secret string) (*models.OAuthClient, error)
+
+	CreateAuthorization(ctx context.Context, a *models.Authorization) error
+	FindAuthorization(ctx context.Context, id uuid.UUID) (*models.Authorization, error)
+	ApproveAuthorization(ctx context.Context, id, userID uuid.UUID, authTime time.Time, codeHash string, codeExpiresAt time.Time) error
+	DenyAuthorization(ctx context.Context, id uuid.UUID) error
+	FindAuthorizationByCode(ctx context.Context, codeHash string) (*models.Authorization, error)
+	ConsumeCode(ctx context.Context, codeHash string, sessionID uuid.UUID) (*models.Authorization, error)
+	PurgeExpiredAuthorizations(ctx context.Context, before time.Time) (int64, error)
+
+	FindConsent(ctx context.Context, userID, clientID uuid.UUID) ([]string, error)
+	SaveConsent(ctx context.Context, userID, clientID uuid.UUID, scopes []string) error
+}
+
+var _ OAuthRepository = (*OAuthStore)(nil)
+
+// OAuthStore gestiona las aplicaciones cliente, las solicitudes de autorización y los consentimientos
+// del servidor de autorización OAuth 2.0 / OIDC.
+type OAuthStore struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewOAuthStore crea un OAuthStore sobre la conexión dada. timeout limita la duración de cada operación
+// (0 = sin límite propio, solo el del contexto).
+func NewOAuthStore(db *sql.DB, timeout time.Duration) *OAuthStore {
+	return &OAuthStore{db: db, timeout: timeout}
+}
+
+// scanOAuthClient lee las columnas de oauthClientColumns seguidas de los destinos extra, si los hay.
+func scanOAuthClient(row rowScanner, extra ...interface{}) (*models.OAuthClient, error) {
+	client := &models.OAuthClient{}
+	var createdBy uuid.NullUUID
+	var revokedAt sql.NullTime
+	dest := append([]interface{}{&client.ID, &client.ClientID, &client.Name, &client.Public,
+		pq.Array(&client.RedirectURIs), &createdBy, &client.CreatedAt, &revokedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if createdBy.Valid {
+		client.CreatedBy = &createdBy.UUID
+	}
+	if revokedAt.Valid {
+		client.RevokedAt = &revokedAt.Time
+	}
+	return client, nil
+}
+
+func scanAuthorization(row rowScanner) (*models.Authorization, error) {
+	a := &models.Authorization{}
+	var userID, sessionID uuid.NullUUID
+	var authTime, consumedAt sql.NullTime
+	err := row.Scan(&a.ID, &a.ClientID, &a.RedirectURI, &a.Scope, &a.State, &a.Nonce, &a.CodeChallenge,
+		&a.CodeChallengeMethod, &userID, &authTime, &sessionID, &a.ExpiresAt, &consumedAt, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if userID.Valid {
+		a.UserID = &userID.UUID
+	}
+	if authTime.Valid {
+		a.AuthTime = &authTime.Time
+	}
+	if sessionID.Valid {
+		a.SessionID = &sessionID.UUID
+	}
+	if consumedAt.Valid {
+		a.ConsumedAt = &consumedAt.Time
+	}
+	return a, nil
+}
+
+// CreateClient registra una aplicación cliente. Las aplicaciones confidenciales reciben un secreto
+// que se devuelve en claro solo aquí; las públicas no tienen secreto.
+func (s *OAuthStore) CreateClient(ctx context.Context, name string, redirectURIs []string, public bool, createdBy *uuid.UUID) (*models.OAuthClient, string, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, "", fmt.Errorf("error generating client id: %w", err)
+	}
+
+	var secret string
+	var hash sql.NullString
+	if !public {
+		var err error
+		secret, hash.String, err = generateClientSecret()
+		if err != nil {
+			return nil, "", err
+		}
+		hash.Valid = true
+	}
+
+	client := &models.OAuthClient{
+		ID:           uuid.New(),
+		ClientID:     "app_" + hex.EncodeToString(suffix),
+		Name:         name,
+		Public:       public,
+		RedirectURIs: redirectURIs,
+		CreatedBy:    createdBy,
+		CreatedAt:    time.Now(),
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO oauth_clients (id, client_id, name, secret_hash, redirect_uris, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		client.ID, client.ClientID, client.Name, hash, pq.Array(client.RedirectURIs), client.CreatedBy, client.CreatedAt,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating oauth client: %w", err)
+	}
+	return client, secret, nil
+}
+
+// FindClient busca una aplicación por su client_id público.
+func (s *OAuthStore) FindClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	client, err := scanOAuthClient(s.db.QueryRowContext(ctx,
+		`SELECT `+oauthClientColumns+` FROM oauth_clients WHERE client_id = $1`, clientID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOAuthClientNotFound
+		}
+		return nil, fmt.Errorf("error finding oauth client: %w", err)
+	}
+	return client, nil
+}
+
+// FindClientByID busca una aplicación por su identificador interno.
+func (s *OAuthStore) FindClientByID(ctx context.Context, id uuid.UUID) (*models.OAuthClient, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	client, err := scanOAuthClient(s.db.QueryRowContext(ctx,
+		`SELECT `+oauthClientColumns+` FROM oauth_clients WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOAuthClientNotFound
+		}
+		return nil, fmt.Errorf("error finding oauth client: %w", err)
+	}
+	return client, nil
+}
+
+// ListClients devuelve todas las aplicaciones registradas, incluidas las revocadas.
+func (s *OAuthStore) ListClients(ctx context.Context) ([]*models.OAuthClient, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+oauthClientColumns+` FROM oauth_clients ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing oauth clients: %w", err)
+	}
+	defer rows.Close()
+
+	clients := []*models.OAuthClient{}
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error reading oauth client: %w", err)
+		}
+		clients = append(clients, client)
+	}
+	return clients, rows.Err()
+}
+
+// RevokeClient desactiva una aplicación; deja de poder solicitar autorizaciones y canjear códigos.
+func (s *OAuthStore) RevokeClient(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE oauth_clients SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("error revoking oauth client: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error revoking oauth client: %w", err)
+	}
+	if n == 0 {
+		return ErrOAuthClientNotFound
+	}
+	return nil
+}
+
+// AuthenticateClient verifica las credenciales de una aplicación en el endpoint de tokens. Las
+// aplicaciones públicas se identifican solo con el client_id y no deben enviar secreto.
+func (s *OAuthStore) AuthenticateClient(ctx context.Context, clientID, secret string) (*models.OAuthClient, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var hash sql.NullString
+	client, err := scanOAuthClient(s.db.QueryRowContext(ctx,
+		`SELECT `+oauthClientColumns+`, secret_hash FROM oauth_clients WHERE client_id = $1`, clientID), &hash)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidClientCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding oauth client: %w", err)
+	}
+	if !client.IsActive() {
+		return nil, ErrInvalidClientCredentials
+	}
+	if client.Public {
+		if secret != "" {
+			return nil, ErrInvalidClientCredentials
+		}
+		return client, nil
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash.String), []byte(secret)) != nil {
+		return nil, ErrInvalidClientCredentials
+	}
+	return client, nil
+}
+
+// CreateAuthorization guarda una solicitud de autorización pendiente de consentimiento.
+func (s *OAuthStore) CreateAuthorization(ctx context.Context, a *models.Authorization) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO oauth_authorizations
+		 (id, client_id, redirect_uri, scope, state, nonce, code_challenge, code_challenge_method, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		a.ID, a.ClientID, a.RedirectURI, a.Scope, a.State, a.Nonce, a.CodeChallenge, a.CodeChallengeMethod, a.ExpiresAt, a.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating authorization: %w", err)
+	}
+	return nil
+}
+
+// FindAuthorization busca una solicitud de autorización por su identificador.
+func (s *OAuthStore) FindAuthorization(ctx context.Context, id uuid.UUID) (*models.Authorization, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	a, err := scanAuthorization(s.db.QueryRowContext(ctx,
+		`SELECT `+authorizationColumns+` FROM oauth_authorizations WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAuthorizationNotFound
+		}
+		return nil, fmt.Errorf("error finding authorization: %w", err)
+	}
+	return a, nil
+}
+
+// ApproveAuthorization asocia la solicitud pendiente al usuario que la aprobó y le fija el código
+// (por su hash) y su vencimiento.
+func (s *OAuthStore) ApproveAuthorization(ctx context.Context, id, userID uuid.UUID, authTime time.Time, codeHash string, codeExpiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE oauth_authorizations SET user_id = $1, auth_time = $2, code_hash = $3, expires_at = $4
+		 WHERE id = $5 AND user_id IS NULL AND consumed_at IS NULL AND expires_at > $6`,
+		userID, authTime, codeHash, codeExpiresAt, id, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("error approving authorization: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAuthorizationNotPending
+	}
+	return nil
+}
+
+// DenyAuthorization cierra una solicitud pendiente que el usuario rechazó.
+func (s *OAuthStore) DenyAuthorization(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	now := time.Now()
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE oauth_authorizations SET consumed_at = $1
+		 WHERE id = $2 AND user_id IS NULL AND consumed_at IS NULL AND expires_at > $1`,
+		now, id,
+	)
+	if err != nil {
+		return fmt.Errorf("error denying authorization: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAuthorizationNotPending
+	}
+	return nil
+}
+
+// FindAuthorizationByCode devuelve la autorización del código con el hash dado sin canjearlo, para
+// validar la solicitud antes de abrir la sesión. Si el código ya se había canjeado devuelve
+// ErrAuthorizationCodeReused junto con la autorización, para que el llamador revoque la sesión
+// emitida con él.
+func (s *OAuthStore) FindAuthorizationByCode(ctx context.Context, codeHash string) (*models.Authorization, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	a, err := scanAuthorization(s.db.QueryRowContext(ctx,
+		`SELECT `+authorizationColumns+` FROM oauth_authorizations WHERE code_hash = $1`, codeHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidAuthorizationCode
+		}
+		return nil, fmt.Errorf("error finding authorization code: %w", err)
+	}
+	if err := checkCode(a, time.Now()); err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+// ConsumeCode marca como canjeado el código con el hash dado y le asocia la sesión abierta con él,
+// en la misma actualización: un canje repetido siempre encuentra la sesión que debe revocar. Si el
+// código ya se había canjeado devuelve ErrAuthorizationCodeReused junto con la autorización.
+func (s *OAuthStore) ConsumeCode(ctx context.Context, codeHash string, sessionID uuid.UUID) (*models.Authorization, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	a, err := scanAuthorization(tx.QueryRowContext(ctx,
+		`SELECT `+authorizationColumns+` FROM oauth_authorizations WHERE code_hash = $1 FOR UPDATE`, codeHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidAuthorizationCode
+		}
+		return nil, fmt.Errorf("error finding authorization code: %w", err)
+	}
+	now := time.Now()
+	if err := checkCode(a, now); err != nil {
+		return a, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE oauth_authorizations SET consumed_at = $1, session_id = $2 WHERE id = $3`, now, sessionID, a.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error consuming authorization code: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	a.ConsumedAt = &now
+	a.SessionID = &sessionID
+	return a, nil
+}
+
+// checkCode comprueba que el código de la autorización pueda canjearse en now.
+func checkCode(a *models.Authorization, now time.Time) error {
+	if a.ConsumedAt != nil {
+		return ErrAuthorizationCodeReused
+	}
+	if !now.Before(a.ExpiresAt) {
+		return ErrInvalidAuthorizationCode
+	}
+	return nil
+}
+
+// PurgeExpiredAuthorizations elimina las solicitudes vencidas antes de la fecha dada.
+func (s *OAuthStore) PurgeExpiredAuthorizations(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM oauth_authorizations WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("error purging authorizations: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// FindConsent devuelve los scopes que el usuario ya concedió a la aplicación, o nil si no hay consentimiento.
+func (s *OAuthStore) FindConsent(ctx context.Context, userID, clientID uuid.UUID) ([]string, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var scopes []string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT scopes FROM oauth_consents WHERE user_id = $1 AND client_id = $2`, userID, clientID,
+	).Scan(pq.Array(&scopes))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding consent: %w", err)
+	}
+	return scopes, nil
+}
+
+// SaveConsent añade los scopes al consentimiento del usuario para la aplicación.
+func (s *OAuthStore) SaveConsent(ctx context.Context, userID, clientID uuid.UUID, scopes []string) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO oauth_consents (user_id, client_id, scopes, granted_at) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (user_id, client_id) DO UPDATE
+		 SET scopes = ARRAY(SELECT DISTINCT unnest(oauth_consents.scopes || EXCLUDED.scopes)),
+		     granted_at = EXCLUDED.granted_at`,
+		userID, clientID, pq.Array(scopes), time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("error saving consent: %w", err)
+	}
+	return nil
+}
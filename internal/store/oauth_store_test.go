@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"component-4/internal/models"
+	"github.com/google/uuid"
+)
+
+// approvedCode crea un cliente, un usuario y una autorización aprobada cuyo código vence en expiresAt,
+// y devuelve el hash del código.
+func approvedCode(t *testing.T, oauth *OAuthStore, users *UserStore, expiresAt time.Time) string {
+	t.Helper()
+	ctx := context.Background()
+
+	client, _, err := oauth.CreateClient(ctx, "LMS", []string{"https://lms.test/callback"}, true, nil)
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	user, err := users.CreateNativeUser(ctx, uniqueEmail("oauth"), "Ana", "secreta123", models.ROLE_ESTUDIANTE)
+	if err != nil {
+		t.Fatalf("CreateNativeUser: %v", err)
+	}
+	a := &models.Authorization{
+		ID:                  uuid.New(),
+		ClientID:            client.ID,
+		RedirectURI:         "https://lms.test/callback",
+		Scope:               "openid",
+		CodeChallenge:       "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		CodeChallengeMethod: "S256",
+		ExpiresAt:           time.Now().Add(10 * time.Minute),
+		CreatedAt:           time.Now(),
+	}
+	if err := oauth.CreateAuthorization(ctx, a); err != nil {
+		t.Fatalf("CreateAuthorization: %v", err)
+	}
+	codeHash := uuid.NewString()
+	if err := oauth.ApproveAuthorization(ctx, a.ID, user.ID, time.Now(), codeHash, expiresAt); err != nil {
+		t.Fatalf("ApproveAuthorization: %v", err)
+	}
+	return codeHash
+}
+
+func TestConsumeCode(t *testing.T) {
+	db := testPostgres(t)
+	if db == nil {
+		t.Skip("TEST_DATABASE_URL no definida")
+	}
+	oauth := NewOAuthStore(db, 5*time.Second)
+	users := NewUserStore(db, nil, 5*time.Second)
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		unknown   bool
+		err       error
+	}{
+		{"vigente", time.Now().Add(time.Minute), false, nil},
+		{"vencido", time.Now().Add(-time.Second), false, ErrInvalidAuthorizationCode},
+		{"desconocido", time.Now().Add(time.Minute), true, ErrInvalidAuthorizationCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codeHash := approvedCode(t, oauth, users, tt.expiresAt)
+			if tt.unknown {
+				codeHash = uuid.NewString()
+			}
+
+			// Consultar el código no lo canjea
+			if _, err := oauth.FindAuthorizationByCode(ctx, codeHash); !errors.Is(err, tt.err) {
+				t.Fatalf("FindAuthorizationByCode: err = %v, se esperaba %v", err, tt.err)
+			}
+			sessionID := uuid.New()
+			a, err := oauth.ConsumeCode(ctx, codeHash, sessionID)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ConsumeCode: err = %v, se esperaba %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if a.ConsumedAt == nil || a.SessionID == nil || *a.SessionID != sessionID {
+				t.Fatalf("autorización canjeada = %+v, se esperaba la sesión %s", a, sessionID)
+			}
+		})
+	}
+}
+
+// TestConsumeCodeReplay comprueba que un código solo se canjea una vez, también con canjes simultáneos,
+// y que el canje repetido recibe la sesión del primero para poder revocarla.
+func TestConsumeCodeReplay(t *testing.T) {
+	db := testPostgres(t)
+	if db == nil {
+		t.Skip("TEST_DATABASE_URL no definida")
+	}
+	oauth := NewOAuthStore(db, 5*time.Second)
+	users := NewUserStore(db, nil, 5*time.Second)
+	ctx := context.Background()
+	codeHash := approvedCode(t, oauth, users, time.Now().Add(time.Minute))
+
+	const attempts = 5
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var winners []uuid.UUID
+	var replays []*models.Authorization
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sessionID := uuid.New()
+			a, err := oauth.ConsumeCode(ctx, codeHash, sessionID)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				winners = append(winners, sessionID)
+			case errors.Is(err, ErrAuthorizationCodeReused):
+				replays = append(replays, a)
+			default:
+				t.Errorf("ConsumeCode: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(winners) != 1 || len(replays) != attempts-1 {
+		t.Fatalf("canjes = %d, repetidos = %d, se esperaba 1 y %d", len(winners), len(replays), attempts-1)
+	}
+	for _, a := range replays {
+		if a == nil || a.SessionID == nil || *a.SessionID != winners[0] {
+			t.Fatalf("el canje repetido no recibió la sesión %s: %+v", winners[0], a)
+		}
+	}
+
+	a, err := oauth.FindAuthorizationByCode(ctx, codeHash)
+	if !errors.Is(err, ErrAuthorizationCodeReused) || a == nil || *a.SessionID != winners[0] {
+		t.Fatalf("FindAuthorizationByCode = %+v, %v, se esperaba ErrAuthorizationCodeReused con la sesión %s", a, err, winners[0])
+	}
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"component-4/config"
+)
+
+// Esperas entre reintentos de WaitForPostgres: empiezan en connectRetryMin y se duplican hasta connectRetryMax.
+const (
+	connectRetryMin = 500 * time.Millisecond
+	connectRetryMax = 5 * time.Second
+)
+
+// OpenPostgres crea el pool de conexiones a Postgres con los límites de la configuración. Es el
+// único pool del servicio: lo comparten las migraciones y todos los stores. No abre ninguna
+// conexión; para esperar a que la base de datos responda se usa WaitForPostgres.
+func OpenPostgres(cfg *config.Config) (*sql.DB, error) {
+	return openPool(cfg.DatabaseURL, cfg)
+}
+
+// openPool abre un pool sobre dsn con los límites de la configuración.
+func openPool(dsn string, cfg *config.Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+	return db, nil
+}
+
+// WaitForPostgres comprueba la conexión y, mientras falle, reintenta con espera exponencial hasta
+// agotar timeout. Cubre el arranque junto a Postgres (docker compose, Kubernetes), cuando la base
+// de datos tarda unos segundos más que el servicio en aceptar conexiones. Con timeout <= 0 se
+// intenta una sola vez.
+func WaitForPostgres(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	wait := connectRetryMin
+	for attempt := 1; ; attempt++ {
+		err := ping(ctx, db)
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return fmt.Errorf("database not reachable after %d attempts: %w", attempt, err)
+		}
+		slog.Warn("la base de datos no responde, reintentando", "attempt", attempt, "retry_in", wait, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, connectRetryMax)
+	}
+}
+
+// ping comprueba la conexión sin esperar más de connectRetryMax, para que un host que no responde
+// no bloquee un intento indefinidamente.
+func ping(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, connectRetryMax)
+	defer cancel()
+	return db.PingContext(ctx)
+}
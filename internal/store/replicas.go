@@ -0,0 +1,246 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"component-4/config"
+	"github.com/google/uuid"
+)
+
+// recentWritesSweep es el tamaño a partir del cual ReplicaSet descarta las escrituras ya vencidas.
+const recentWritesSweep = 1024
+
+// ReplicaSet reparte las lecturas de usuarios entre réplicas de solo lectura de Postgres.
+//
+// Las réplicas se eligen por turnos entre las que están en rotación. Watch comprueba cada réplica
+// periódicamente: la saca de rotación si no responde y la devuelve cuando vuelve a hacerlo. Si una
+// lectura falla, la réplica sale de rotación hasta la siguiente comprobación correcta y la lectura se
+// repite en el primario, así que una réplica caída no produce errores.
+//
+// Como las réplicas van con retraso, tras modificar un usuario sus lecturas van al primario durante
+// stickiness: quien acaba de registrarse, cambiar su contraseña o su email lee sus propios cambios.
+// El registro de escrituras es local a cada instancia del servicio.
+type ReplicaSet struct {
+	replicas   []*replica
+	next       atomic.Uint64
+	stickiness time.Duration
+
+	mu     sync.Mutex
+	recent map[string]time.Time // clave de usuario -> fin del periodo de lectura en el primario
+}
+
+// replica es una réplica y si está en rotación.
+type replica struct {
+	name string
+	db   *sql.DB
+	up   atomic.Bool
+}
+
+// NewReplicaSet crea un ReplicaSet sobre los pools dados. Con dbs vacío todas las lecturas van al
+// primario. Las réplicas empiezan fuera de rotación hasta que Watch compruebe que responden.
+func NewReplicaSet(dbs []*sql.DB, stickiness time.Duration) *ReplicaSet {
+	r := &ReplicaSet{
+		stickiness: stickiness,
+		recent:     map[string]time.Time{},
+	}
+	for i, db := range dbs {
+		r.replicas = append(r.replicas, &replica{name: replicaName(i), db: db})
+	}
+	return r
+}
+
+// OpenReplicas abre un pool por cada DSN de DB_REPLICA_URLS con los límites de la configuración.
+// No espera a que las réplicas respondan: hasta que Watch las compruebe, las lecturas van al primario.
+func OpenReplicas(cfg *config.Config) (*ReplicaSet, error) {
+	dbs := make([]*sql.DB, 0, len(cfg.DBReplicaURLs))
+	for _, dsn := range cfg.DBReplicaURLs {
+		db, err := openPool(dsn, cfg)
+		if err != nil {
+			for _, opened := range dbs {
+				opened.Close()
+			}
+			return nil, err
+		}
+		dbs = append(dbs, db)
+	}
+	return NewReplicaSet(dbs, cfg.DBReplicaStickiness), nil
+}
+
+// Names devuelve los nombres de las réplicas en el mismo orden que DBs.
+func (r *ReplicaSet) Names() []string {
+	if r == nil {
+		return nil
+	}
+	names := make([]string, len(r.replicas))
+	for i, rep := range r.replicas {
+		names[i] = rep.name
+	}
+	return names
+}
+
+// IsUp indica si la réplica con ese nombre está en rotación, p. ej. para exportarlo como métrica.
+func (r *ReplicaSet) IsUp(name string) bool {
+	if r == nil {
+		return false
+	}
+	for _, rep := range r.replicas {
+		if rep.name == name {
+			return rep.up.Load()
+		}
+	}
+	return false
+}
+
+// Watch comprueba las réplicas al empezar y después cada interval, sacando de rotación las que no
+// responden y devolviendo las que se recuperan. Se ejecuta hasta que ctx se cancela.
+func (r *ReplicaSet) Watch(ctx context.Context, interval time.Duration) {
+	if r == nil || len(r.replicas) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check hace ping a todas las réplicas en paralelo y actualiza su estado.
+func (r *ReplicaSet) check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, rep := range r.replicas {
+		wg.Add(1)
+		go func(rep *replica) {
+			defer wg.Done()
+			err := ping(ctx, rep.db)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				r.markDown(rep, err)
+				return
+			}
+			if !rep.up.Swap(true) {
+				slog.Info("réplica de lectura en rotación", "replica", rep.name)
+			}
+		}(rep)
+	}
+	wg.Wait()
+}
+
+// DBs devuelve los pools de las réplicas, p. ej. para exportar sus métricas.
+func (r *ReplicaSet) DBs() []*sql.DB {
+	if r == nil {
+		return nil
+	}
+	dbs := make([]*sql.DB, len(r.replicas))
+	for i, rep := range r.replicas {
+		dbs[i] = rep.db
+	}
+	return dbs
+}
+
+// Close cierra los pools de las réplicas.
+func (r *ReplicaSet) Close() error {
+	if r == nil {
+		return nil
+	}
+	var errs []error
+	for _, rep := range r.replicas {
+		errs = append(errs, rep.db.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// pick elige la réplica para una lectura sobre las claves dadas. Devuelve nil, y la lectura va al
+// primario, si no hay réplicas disponibles o alguna clave se modificó hace poco.
+func (r *ReplicaSet) pick(keys ...string) *replica {
+	if r == nil || len(r.replicas) == 0 || r.written(keys...) {
+		return nil
+	}
+	start := r.next.Add(1)
+	for i := range r.replicas {
+		rep := r.replicas[(start+uint64(i))%uint64(len(r.replicas))]
+		if rep.up.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+// markDown saca la réplica de rotación hasta que Watch compruebe que vuelve a responder.
+func (r *ReplicaSet) markDown(rep *replica, err error) {
+	if rep.up.Swap(false) {
+		slog.Warn("réplica de lectura fuera de rotación, se usa el primario", "replica", rep.name, "error", err)
+	}
+}
+
+// markWritten envía al primario las lecturas de las claves dadas durante stickiness.
+func (r *ReplicaSet) markWritten(keys ...string) {
+	if r == nil || len(r.replicas) == 0 || r.stickiness <= 0 {
+		return
+	}
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.recent) >= recentWritesSweep {
+		for key, until := range r.recent {
+			if !until.After(now) {
+				delete(r.recent, key)
+			}
+		}
+	}
+	for _, key := range keys {
+		r.recent[key] = now.Add(r.stickiness)
+	}
+}
+
+// written indica si alguna de las claves se modificó durante el último periodo de stickiness.
+func (r *ReplicaSet) written(keys ...string) bool {
+	if r == nil || len(keys) == 0 {
+		return false
+	}
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range keys {
+		if until, ok := r.recent[key]; ok && until.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// failover indica si un error de una réplica justifica repetir la lectura en el primario. Una fila
+// inexistente es una respuesta válida, y con el contexto cancelado o vencido el primario tampoco
+// llegaría a tiempo.
+func failover(ctx context.Context, err error) bool {
+	return err != nil && !errors.Is(err, sql.ErrNoRows) && ctx.Err() == nil
+}
+
+// userKey identifica a un usuario por su id en el registro de escrituras recientes.
+func userKey(id uuid.UUID) string {
+	return "id:" + id.String()
+}
+
+// emailKey identifica a un usuario por su email en el registro de escrituras recientes.
+func emailKey(email string) string {
+	return "email:" + normalizeEmail(email)
+}
+
+// replicaName es el nombre de la réplica i en los logs y las métricas.
+func replicaName(i int) string {
+	return "replica-" + strconv.Itoa(i+1)
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// openTestReplica abre un pool SQLite que hace de réplica: solo importa si responde al ping.
+func openTestReplica(t *testing.T, name string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), name+".db"))
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestReplicaSetWatchRotation(t *testing.T) {
+	healthy := openTestReplica(t, "healthy")
+	broken := openTestReplica(t, "broken")
+	broken.Close()
+	replicas := NewReplicaSet([]*sql.DB{healthy, broken}, time.Minute)
+	ctx := context.Background()
+
+	if rep := replicas.pick(); rep != nil {
+		t.Fatalf("pick antes de la primera comprobación = %s, se esperaba el primario", rep.name)
+	}
+
+	replicas.check(ctx)
+	if !replicas.IsUp("replica-1") || replicas.IsUp("replica-2") {
+		t.Fatalf("tras comprobar: replica-1 up = %v, replica-2 up = %v", replicas.IsUp("replica-1"), replicas.IsUp("replica-2"))
+	}
+	for i := 0; i < 4; i++ {
+		if rep := replicas.pick(); rep == nil || rep.db != healthy {
+			t.Fatalf("pick %d no devolvió la réplica sana", i)
+		}
+	}
+
+	// Un fallo de consulta la saca de rotación; la siguiente comprobación la devuelve
+	replicas.markDown(replicas.replicas[0], errors.New("connection reset"))
+	if rep := replicas.pick(); rep != nil {
+		t.Fatalf("pick tras el fallo = %s, se esperaba el primario", rep.name)
+	}
+	replicas.check(ctx)
+	if rep := replicas.pick(); rep == nil || rep.db != healthy {
+		t.Fatal("la réplica no volvió a rotación tras comprobar que responde")
+	}
+}
+
+func TestReplicaSetWatchStopsWithContext(t *testing.T) {
+	replicas := NewReplicaSet([]*sql.DB{openTestReplica(t, "replica")}, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		replicas.Watch(ctx, time.Hour)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !replicas.IsUp("replica-1") {
+		if time.Now().After(deadline) {
+			t.Fatal("Watch no comprobó la réplica al empezar")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch no terminó al cancelar el contexto")
+	}
+}
+
+func TestReplicaSetReadYourWrites(t *testing.T) {
+	replicas := NewReplicaSet([]*sql.DB{openTestReplica(t, "replica")}, time.Minute)
+	replicas.check(context.Background())
+
+	id := uuid.New()
+	if rep := replicas.pick(userKey(id)); rep == nil {
+		t.Fatal("pick de un usuario sin escrituras recientes no usó la réplica")
+	}
+	replicas.markWritten(userKey(id), emailKey("ana@test.invalid"))
+	if rep := replicas.pick(userKey(id)); rep != nil {
+		t.Fatal("pick de un usuario recién modificado no fue al primario")
+	}
+	if rep := replicas.pick(emailKey("ana@test.invalid")); rep != nil {
+		t.Fatal("pick por el email de un usuario recién modificado no fue al primario")
+	}
+	if rep := replicas.pick(userKey(uuid.New())); rep == nil {
+		t.Fatal("pick de otro usuario no usó la réplica")
+	}
+}
@@ -0,0 +1,224 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"component-4/internal/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrServiceClientNotFound indica que la cuenta de servicio no existe.
+	ErrServiceClientNotFound = errors.New("service client not found")
+	// ErrInvalidClientCredentials indica que el client_id o el secreto no son válidos, o que la cuenta fue revocada.
+	ErrInvalidClientCredentials = errors.New("invalid client credentials")
+)
+
+const serviceClientColumns = `id, client_id, name, scopes, created_by, created_at, rotated_at, revoked_at`
+
+// ServiceClientRepository es el almacén de cuentas de servicio del que dependen los handlers y
+// ServiceAuthMiddleware. ServiceClientStore lo implementa sobre Postgres.
+type ServiceClientRepository interface {
+	Create(ctx context.Context, name string, scopes []string, createdBy *uuid.UUID) (*models.ServiceClient, string, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*models.ServiceClient, error)
+	List(ctx context.Context) ([]*models.ServiceClient, error)
+	Authenticate(ctx context.Context, clientID, secret string) (*models.ServiceClient, error)
+	Rotate(ctx context.Context, id uuid.UUID) (*models.ServiceClient, string, error)
+	Revoke(ctx context.Context, id uuid.UUID) error
+}
+
+var _ ServiceClientRepository = (*ServiceClientStore)(nil)
+
+// ServiceClientStore gestiona las cuentas de servicio y sus secretos.
+type ServiceClientStore struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewServiceClientStore crea un ServiceClientStore sobre la conexión dada. timeout limita la duración de cada operación
+// (0 = sin límite propio, solo el del contexto).
+func NewServiceClientStore(db *sql.DB, timeout time.Duration) *ServiceClientStore {
+	return &ServiceClientStore{db: db, timeout: timeout}
+}
+
+// scanServiceClient lee las columnas de serviceClientColumns seguidas de los destinos extra, si los hay.
+func scanServiceClient(row rowScanner, extra ...interface{}) (*models.ServiceClient, error) {
+	client := &models.ServiceClient{}
+	var createdBy uuid.NullUUID
+	var rotatedAt, revokedAt sql.NullTime
+	dest := append([]interface{}{&client.ID, &client.ClientID, &client.Name, pq.Array(&client.Scopes),
+		&createdBy, &client.CreatedAt, &rotatedAt, &revokedAt}, extra...)
+	err := row.Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+	if createdBy.Valid {
+		client.CreatedBy = &createdBy.UUID
+	}
+	if rotatedAt.Valid {
+		client.RotatedAt = &rotatedAt.Time
+	}
+	if revokedAt.Valid {
+		client.RevokedAt = &revokedAt.Time
+	}
+	return client, nil
+}
+
+// generateClientSecret devuelve un secreto aleatorio de 256 bits y su hash bcrypt.
+func generateClientSecret() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", fmt.Errorf("error generating secret: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(raw)
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", fmt.Errorf("error hashing secret: %w", err)
+	}
+	return secret, string(hash), nil
+}
+
+// Create registra una cuenta de servicio y devuelve su secreto en claro, que no vuelve a estar disponible.
+func (s *ServiceClientStore) Create(ctx context.Context, name string, scopes []string, createdBy *uuid.UUID) (*models.ServiceClient, string, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, "", fmt.Errorf("error generating client id: %w", err)
+	}
+	secret, hash, err := generateClientSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	client := &models.ServiceClient{
+		ID:        uuid.New(),
+		ClientID:  "svc_" + hex.EncodeToString(suffix),
+		Name:      name,
+		Scopes:    scopes,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO service_clients (id, client_id, name, secret_hash, scopes, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		client.ID, client.ClientID, client.Name, hash, pq.Array(client.Scopes), client.CreatedBy, client.CreatedAt,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating service client: %w", err)
+	}
+	return client, secret, nil
+}
+
+// FindByID busca una cuenta de servicio por su identificador interno.
+func (s *ServiceClientStore) FindByID(ctx context.Context, id uuid.UUID) (*models.ServiceClient, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	client, err := scanServiceClient(s.db.QueryRowContext(ctx,
+		`SELECT `+serviceClientColumns+` FROM service_clients WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrServiceClientNotFound
+		}
+		return nil, fmt.Errorf("error finding service client: %w", err)
+	}
+	return client, nil
+}
+
+// List devuelve todas las cuentas de servicio, incluidas las revocadas.
+func (s *ServiceClientStore) List(ctx context.Context) ([]*models.ServiceClient, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+serviceClientColumns+` FROM service_clients ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing service clients: %w", err)
+	}
+	defer rows.Close()
+
+	clients := []*models.ServiceClient{}
+	for rows.Next() {
+		client, err := scanServiceClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error reading service client: %w", err)
+		}
+		clients = append(clients, client)
+	}
+	return clients, rows.Err()
+}
+
+// Authenticate verifica el client_id y el secreto. Devuelve ErrInvalidClientCredentials tanto si
+// la cuenta no existe como si el secreto no coincide o la cuenta está revocada.
+func (s *ServiceClientStore) Authenticate(ctx context.Context, clientID, secret string) (*models.ServiceClient, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var hash string
+	client, err := scanServiceClient(s.db.QueryRowContext(ctx,
+		`SELECT `+serviceClientColumns+`, secret_hash FROM service_clients WHERE client_id = $1`, clientID), &hash)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidClientCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding service client: %w", err)
+	}
+	if !client.IsActive() || bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) != nil {
+		return nil, ErrInvalidClientCredentials
+	}
+	return client, nil
+}
+
+// Rotate reemplaza el secreto de una cuenta activa y devuelve el nuevo en claro. El secreto
+// anterior deja de servir para obtener tokens; los tokens ya emitidos siguen vigentes hasta vencer.
+func (s *ServiceClientStore) Rotate(ctx context.Context, id uuid.UUID) (*models.ServiceClient, string, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	secret, hash, err := generateClientSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	client, err := scanServiceClient(s.db.QueryRowContext(ctx,
+		`UPDATE service_clients SET secret_hash = $1, rotated_at = $2
+		 WHERE id = $3 AND revoked_at IS NULL
+		 RETURNING `+serviceClientColumns,
+		hash, time.Now(), id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", ErrServiceClientNotFound
+		}
+		return nil, "", fmt.Errorf("error rotating service client: %w", err)
+	}
+	return client, secret, nil
+}
+
+// Revoke desactiva la cuenta de servicio; sus tokens dejan de aceptarse de inmediato.
+func (s *ServiceClientStore) Revoke(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE service_clients SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("error revoking service client: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error revoking service client: %w", err)
+	}
+	if n == 0 {
+		return ErrServiceClientNotFound
+	}
+	return nil
+}
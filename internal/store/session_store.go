@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"component-4/internal/models"
+	"github.com/google/uuid"
+)
+
+// ErrSessionNotFound indica que la sesión no existe o no pertenece al usuario indicado.
+var ErrSessionNotFound = errors.New("session not found")
+
+// lastSeenResolution evita escribir en la base de datos en cada petición autenticada.
+const lastSeenResolution = time.Minute
+
+const sessionColumns = `id, user_id, user_agent, ip, auth_method, created_at, last_seen_at, expires_at, revoked_at`
+
+// SessionRepository es el almacén de sesiones del que dependen los handlers y AuthMiddleware.
+// SessionStore lo implementa sobre Postgres y MemorySessionStore en memoria para las pruebas.
+type SessionRepository interface {
+	Create(ctx context.Context, userID uuid.UUID, userAgent, ip string, method models.AuthMethod, expiresAt time.Time) (*models.Session, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*models.Session, error)
+	Validate(ctx context.Context, id uuid.UUID) (bool, error)
+	ListActiveByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error)
+	Revoke(ctx context.Context, id uuid.UUID, userID *uuid.UUID) error
+	RevokeAllByUser(ctx context.Context, userID uuid.UUID) error
+}
+
+var (
+	_ SessionRepository = (*SessionStore)(nil)
+	_ SessionRepository = (*MemorySessionStore)(nil)
+)
+
+// SessionStore gestiona las sesiones asociadas a los tokens emitidos.
+type SessionStore struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewSessionStore crea un SessionStore sobre la conexión dada. timeout limita la duración de cada operación
+// (0 = sin límite propio, solo el del contexto).
+func NewSessionStore(db *sql.DB, timeout time.Duration) *SessionStore {
+	return &SessionStore{db: db, timeout: timeout}
+}
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	session := &models.Session{}
+	var method string
+	var revokedAt sql.NullTime
+	err := row.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &method,
+		&session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	session.AuthMethod = models.AuthMethod(method)
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return session, nil
+}
+
+// Create abre una sesión nueva para el usuario.
+func (s *SessionStore) Create(ctx context.Context, userID uuid.UUID, userAgent, ip string, method models.AuthMethod, expiresAt time.Time) (*models.Session, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	now := time.Now()
+	session := &models.Session{
+		ID:         uuid.New(),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		AuthMethod: method,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO sessions (id, user_id, user_agent, ip, auth_method, created_at, last_seen_at, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		session.ID, session.UserID, session.UserAgent, session.IP, string(session.AuthMethod),
+		session.CreatedAt, session.LastSeenAt, session.ExpiresAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating session: %w", err)
+	}
+	return session, nil
+}
+
+// FindByID busca una sesión por su identificador.
+func (s *SessionStore) FindByID(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	session, err := scanSession(s.db.QueryRowContext(ctx,
+		`SELECT `+sessionColumns+` FROM sessions WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("error finding session: %w", err)
+	}
+	return session, nil
+}
+
+// Validate indica si la sesión sigue activa y, de ser así, actualiza su última actividad.
+func (s *SessionStore) Validate(ctx context.Context, id uuid.UUID) (bool, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	session, err := s.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	now := time.Now()
+	if !session.IsActive(now) {
+		return false, nil
+	}
+	if now.Sub(session.LastSeenAt) >= lastSeenResolution {
+		if _, err := s.db.ExecContext(ctx, `UPDATE sessions SET last_seen_at = $1 WHERE id = $2`, now, id); err != nil {
+			return false, fmt.Errorf("error updating session: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// ListActiveByUser devuelve las sesiones vigentes del usuario, de la más reciente a la más antigua.
+func (s *SessionStore) ListActiveByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.list(ctx,
+		`SELECT `+sessionColumns+` FROM sessions
+		 WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		 ORDER BY last_seen_at DESC`, userID, time.Now())
+}
+
+// ListByUser devuelve todas las sesiones del usuario, incluidas las revocadas y expiradas.
+func (s *SessionStore) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.list(ctx,
+		`SELECT `+sessionColumns+` FROM sessions WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+}
+
+func (s *SessionStore) list(ctx context.Context, query string, args ...interface{}) ([]*models.Session, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error reading session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Revoke invalida una sesión. Si userID no es nil, la sesión debe pertenecer a ese usuario.
+func (s *SessionStore) Revoke(ctx context.Context, id uuid.UUID, userID *uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE sessions SET revoked_at = $1
+		 WHERE id = $2 AND ($3::uuid IS NULL OR user_id = $3) AND revoked_at IS NULL`,
+		time.Now(), id, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("error revoking session: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		session, err := s.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if userID != nil && session.UserID != *userID {
+			return ErrSessionNotFound
+		}
+		// Ya estaba revocada
+	}
+	return nil
+}
+
+// RevokeAllByUser invalida todas las sesiones vigentes del usuario.
+func (s *SessionStore) RevokeAllByUser(ctx context.Context, userID uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`,
+		time.Now(), userID,
+	)
+	if err != nil {
+		return fmt.Errorf("error revoking sessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,383 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"component-4/internal/models"
+	"component-4/internal/tracing"
+	"github.com/google/uuid"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema es el equivalente en SQLite de la tabla users de las migraciones de Postgres.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    password TEXT,
+    role TEXT NOT NULL,
+    google_id TEXT UNIQUE,
+    preferences TEXT NOT NULL DEFAULT '{}',
+    deletion_scheduled_at DATETIME,
+    anonymized_at DATETIME,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users(deletion_scheduled_at);
+UPDATE users SET email = lower(email) WHERE email <> lower(email);
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_key ON users(lower(email));
+`
+
+// SQLiteUserStore implementa UserRepository sobre un archivo SQLite, para instalaciones locales o sin
+// acceso a Postgres. Solo guarda la tabla users, así que lo usan los subcomandos import-users y
+// export-users (con -sqlite); el servidor, cuyas sesiones, invitaciones y demás tablas dependen de
+// users, requiere Postgres.
+type SQLiteUserStore struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewSQLiteUserStore abre (o crea) la base de datos SQLite en path y se asegura de que exista la tabla users.
+// timeout limita la duración de cada operación (0 = sin límite propio).
+func NewSQLiteUserStore(path string, timeout time.Duration) (*SQLiteUserStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("error opening sqlite database: %w", err)
+	}
+	// SQLite admite un único escritor; una sola conexión evita errores SQLITE_BUSY entre transacciones.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating sqlite schema: %w", err)
+	}
+	return &SQLiteUserStore{db: db, timeout: timeout}, nil
+}
+
+func startSQLiteSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "SQLiteUserStore."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemSqlite, semconv.DBOperationName(operation)),
+	)
+}
+
+// Close cierra la base de datos.
+func (s *SQLiteUserStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteUserStore) FindByEmail(ctx context.Context, email string) (user *models.User, err error) {
+	ctx, span := startSQLiteSpan(ctx, "FindByEmail")
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.findOne(ctx, s.db, `SELECT `+userColumns+` FROM users WHERE email = $1`, normalizeEmail(email))
+}
+
+func (s *SQLiteUserStore) FindByID(ctx context.Context, id uuid.UUID) (user *models.User, err error) {
+	ctx, span := startSQLiteSpan(ctx, "FindByID")
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.findOne(ctx, s.db, `SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+}
+
+// findOne ejecuta una consulta o un UPDATE ... RETURNING que devuelve a lo sumo un usuario.
+func (s *SQLiteUserStore) findOne(ctx context.Context, q querier, query string, args ...interface{}) (*models.User, error) {
+	user, err := scanUser(q.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		if isEmailUniqueViolation(err) {
+			return nil, ErrEmailTaken
+		}
+		return nil, fmt.Errorf("error querying user: %w", err)
+	}
+	return user, nil
+}
+
+func (s *SQLiteUserStore) ListUsers(ctx context.Context) (users []*models.User, err error) {
+	ctx, span := startSQLiteSpan(ctx, "ListUsers")
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users ORDER BY created_at, email`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing users: %w", err)
+	}
+	defer rows.Close()
+
+	users = []*models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error reading user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (s *SQLiteUserStore) CreateNativeUser(ctx context.Context, email, name, password string, role models.Role) (user *models.User, err error) {
+	ctx, span := startSQLiteSpan(ctx, "CreateNativeUser")
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		user, err = createNativeUser(ctx, tx, email, name, password, role)
+		return err
+	})
+	return user, err
+}
+
+func (s *SQLiteUserStore) CreateGoogleUser(ctx context.Context, email, name, googleID string, role models.Role) (user *models.User, err error) {
+	ctx, span := startSQLiteSpan(ctx, "CreateGoogleUser")
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		user, err = createGoogleUser(ctx, tx, email, name, googleID, role)
+		return err
+	})
+	return user, err
+}
+
+// CreateNativeUsers crea un lote de usuarios en una única transacción con un savepoint por fila,
+// igual que UserStore.CreateNativeUsers. Tampoco aplica el límite por operación.
+func (s *SQLiteUserStore) CreateNativeUsers(ctx context.Context, users []NewNativeUser, dryRun bool) (results []BatchResult, err error) {
+	ctx, span := startSQLiteSpan(ctx, "CreateNativeUsers")
+	defer func() { tracing.End(span, err) }()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	results = make([]BatchResult, len(users))
+	for i, u := range users {
+		results[i].Email = u.Email
+
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_row"); err != nil {
+			return nil, fmt.Errorf("error creating savepoint: %w", err)
+		}
+		_, err := createNativeUser(ctx, tx, u.Email, u.Name, u.Password, u.Role)
+		switch {
+		case err == nil:
+			results[i].Created = true
+		case errors.Is(err, ErrEmailTaken):
+			// Fila ya importada: se omite
+		default:
+			results[i].Err = err
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_row"); err != nil {
+				return nil, fmt.Errorf("error rolling back savepoint: %w", err)
+			}
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_row"); err != nil {
+			return nil, fmt.Errorf("error releasing savepoint: %w", err)
+		}
+	}
+
+	if dryRun {
+		return results, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return results, nil
+}
+
+// UpsertGoogleUser crea el usuario de Google con el rol dado o, si el email ya existe, lo vincula y
+// sincroniza su nombre sin cambiar su rol.
+func (s *SQLiteUserStore) UpsertGoogleUser(ctx context.Context, email, name, googleID string, role models.Role) (user *models.User, err error) {
+	ctx, span := startSQLiteSpan(ctx, "UpsertGoogleUser")
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+	email = normalizeEmail(email)
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		// El INSERT toma el bloqueo de escritura de la base de datos: a partir de aquí ninguna otra
+		// transacción puede crear o modificar el usuario, así que SQLite no necesita FOR UPDATE
+		now := time.Now()
+		user, err = s.findOne(ctx, tx,
+			`INSERT INTO users (id, email, name, role, google_id, created_at, updated_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)
+			 ON CONFLICT (email) DO NOTHING
+			 RETURNING `+userColumns,
+			uuid.New(), email, name, string(role), googleID, now, now)
+		if !errors.Is(err, ErrUserNotFound) {
+			return err
+		}
+		user, err = s.findOne(ctx, tx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email)
+		if err != nil {
+			return err
+		}
+		if user.GoogleID != nil && user.Name == name {
+			return nil
+		}
+		user, err = s.findOne(ctx, tx,
+			`UPDATE users SET google_id = COALESCE(google_id, $1), name = $2, updated_at = $3
+			 WHERE id = $4
+			 RETURNING `+userColumns,
+			googleID, name, now, user.ID)
+		return err
+	})
+	return user, err
+}
+
+func (s *SQLiteUserStore) SetPassword(ctx context.Context, email, password string) (err error) {
+	ctx, span := startSQLiteSpan(ctx, "SetPassword")
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+	email = normalizeEmail(email)
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users SET password = $1, updated_at = $2 WHERE email = $3",
+		hash, time.Now(), email,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating password: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// UpdateProfile actualiza el nombre (si no es nil) y fusiona las preferencias dadas con las existentes.
+func (s *SQLiteUserStore) UpdateProfile(ctx context.Context, id uuid.UUID, name *string, preferences json.RawMessage) (user *models.User, err error) {
+	ctx, span := startSQLiteSpan(ctx, "UpdateProfile")
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := s.findOne(ctx, tx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+		merged, err := mergePreferences(current.Preferences, preferences)
+		if err != nil {
+			return err
+		}
+		user, err = s.findOne(ctx, tx,
+			`UPDATE users SET name = COALESCE($1, name), preferences = $2, updated_at = $3
+			 WHERE id = $4
+			 RETURNING `+userColumns,
+			name, string(merged), time.Now(), id)
+		return err
+	})
+	return user, err
+}
+
+// UpdateEmail cambia el email del usuario solo si su email actual sigue siendo oldEmail.
+func (s *SQLiteUserStore) UpdateEmail(ctx context.Context, id uuid.UUID, oldEmail, newEmail string) (user *models.User, err error) {
+	ctx, span := startSQLiteSpan(ctx, "UpdateEmail")
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+	oldEmail, newEmail = normalizeEmail(oldEmail), normalizeEmail(newEmail)
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", newEmail).Scan(&exists); err != nil {
+			return fmt.Errorf("error checking email existence: %w", err)
+		}
+		if exists {
+			return ErrEmailTaken
+		}
+		user, err = s.findOne(ctx, tx,
+			`UPDATE users SET email = $1, updated_at = $2
+			 WHERE id = $3 AND email = $4
+			 RETURNING `+userColumns,
+			newEmail, time.Now(), id, oldEmail)
+		return err
+	})
+	return user, err
+}
+
+// ScheduleDeletion marca la cuenta para ser eliminada definitivamente en la fecha indicada.
+func (s *SQLiteUserStore) ScheduleDeletion(ctx context.Context, id uuid.UUID, at time.Time) (user *models.User, err error) {
+	ctx, span := startSQLiteSpan(ctx, "ScheduleDeletion")
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+	// Las fechas se guardan como texto: en UTC se comparan correctamente en PurgeScheduledDeletions.
+	return s.findOne(ctx, s.db,
+		`UPDATE users SET deletion_scheduled_at = $1, updated_at = $2
+		 WHERE id = $3
+		 RETURNING `+userColumns,
+		at.UTC(), time.Now(), id)
+}
+
+// CancelDeletion anula una eliminación programada que aún no se ha ejecutado.
+func (s *SQLiteUserStore) CancelDeletion(ctx context.Context, id uuid.UUID) (user *models.User, err error) {
+	ctx, span := startSQLiteSpan(ctx, "CancelDeletion")
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.findOne(ctx, s.db,
+		`UPDATE users SET deletion_scheduled_at = NULL, updated_at = $1
+		 WHERE id = $2
+		 RETURNING `+userColumns,
+		time.Now(), id)
+}
+
+// PurgeScheduledDeletions elimina definitivamente las cuentas cuyo periodo de gracia terminó antes de now.
+func (s *SQLiteUserStore) PurgeScheduledDeletions(ctx context.Context, now time.Time) (n int64, err error) {
+	ctx, span := startSQLiteSpan(ctx, "PurgeScheduledDeletions")
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM users WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= $1`, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("error purging users: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Anonymize reemplaza los datos personales del usuario por valores neutros y elimina sus credenciales.
+// SQLite no guarda sesiones, así que no hay nada más que revocar.
+func (s *SQLiteUserStore) Anonymize(ctx context.Context, id uuid.UUID) (user *models.User, err error) {
+	ctx, span := startSQLiteSpan(ctx, "Anonymize")
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+	now := time.Now()
+	return s.findOne(ctx, s.db,
+		`UPDATE users
+		 SET email = $1, name = $2, password = NULL, google_id = NULL, preferences = '{}',
+		     deletion_scheduled_at = NULL, anonymized_at = $3, updated_at = $3
+		 WHERE id = $4
+		 RETURNING `+userColumns,
+		anonymizedEmail(id), anonymizedName, now, id)
+}
+
+// inTx ejecuta fn en una transacción y la confirma si fn no devuelve error.
+func (s *SQLiteUserStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
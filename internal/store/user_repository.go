@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"component-4/internal/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrUserNotFound indica que el usuario no existe.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrEmailTaken indica que ya hay una cuenta con ese email.
+	ErrEmailTaken = errors.New("email already exists")
+)
+
+// UserRepository es el directorio de usuarios del que dependen los handlers, los jobs y la CLI.
+// UserStore lo implementa sobre Postgres, SQLiteUserStore sobre un archivo SQLite y MemoryUserStore
+// en memoria para las pruebas.
+type UserRepository interface {
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	ListUsers(ctx context.Context) ([]*models.User, error)
+
+	CreateNativeUser(ctx context.Context, email, name, password string, role models.Role) (*models.User, error)
+	CreateGoogleUser(ctx context.Context, email, name, googleID string, role models.Role) (*models.User, error)
+	CreateNativeUsers(ctx context.Context, users []NewNativeUser, dryRun bool) ([]BatchResult, error)
+	UpsertGoogleUser(ctx context.Context, email, name, googleID string, role models.Role) (*models.User, error)
+
+	SetPassword(ctx context.Context, email, password string) error
+	UpdateProfile(ctx context.Context, id uuid.UUID, name *string, preferences json.RawMessage) (*models.User, error)
+	UpdateEmail(ctx context.Context, id uuid.UUID, oldEmail, newEmail string) (*models.User, error)
+
+	ScheduleDeletion(ctx context.Context, id uuid.UUID, at time.Time) (*models.User, error)
+	CancelDeletion(ctx context.Context, id uuid.UUID) (*models.User, error)
+	PurgeScheduledDeletions(ctx context.Context, now time.Time) (int64, error)
+	Anonymize(ctx context.Context, id uuid.UUID) (*models.User, error)
+
+	Close() error
+}
+
+var (
+	_ UserRepository = (*UserStore)(nil)
+	_ UserRepository = (*SQLiteUserStore)(nil)
+	_ UserRepository = (*MemoryUserStore)(nil)
+)
+
+// normalizeEmail es la forma en la que se guardan y buscan los emails: sin espacios alrededor y en
+// minúsculas. Todos los stores la aplican en sus entradas para que Ana@x.com y ana@x.com sean la
+// misma cuenta; el índice único users_email_lower_key lo garantiza en la base de datos.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// isEmailUniqueViolation indica si err es la violación de la restricción UNIQUE de users.email o
+// del índice sobre lower(email), tanto en Postgres como en SQLite. Cubre la carrera entre la
+// comprobación previa y el INSERT.
+func isEmailUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505" &&
+			(pqErr.Constraint == "users_email_key" || pqErr.Constraint == "users_email_lower_key")
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "UNIQUE constraint failed: users.email") ||
+		strings.Contains(msg, "UNIQUE constraint failed: index 'users_email_lower_key'")
+}
+
+// mergePreferences fusiona las claves de patch sobre las de current, como el operador || de jsonb.
+func mergePreferences(current, patch json.RawMessage) (json.RawMessage, error) {
+	merged := map[string]json.RawMessage{}
+	if len(current) > 0 {
+		if err := json.Unmarshal(current, &merged); err != nil {
+			return nil, fmt.Errorf("error reading preferences: %w", err)
+		}
+	}
+	if len(patch) > 0 {
+		var changes map[string]json.RawMessage
+		if err := json.Unmarshal(patch, &changes); err != nil {
+			return nil, fmt.Errorf("error reading preferences: %w", err)
+		}
+		for k, v := range changes {
+			merged[k] = v
+		}
+	}
+	return json.Marshal(merged)
+}
+
+// anonymizedEmail es el email que recibe una cuenta anonimizada.
+func anonymizedEmail(id uuid.UUID) string {
+	return fmt.Sprintf("deleted-%s@anonymized.invalid", id)
+}
+
+// anonymizedName es el nombre que recibe una cuenta anonimizada.
+const anonymizedName = "Usuario eliminado"
+
+// hashPassword genera el hash bcrypt con el que se guardan las contraseñas.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
+	return string(hash), nil
+}
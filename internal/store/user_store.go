@@ -2,59 +2,94 @@ package store
 
 import (
     "component-4/internal/models"
+    "component-4/internal/tracing"
+    "context"
     "database/sql"
+    "encoding/json"
+    "errors"
     "fmt"
     "time"
 
     "github.com/google/uuid"
+    "go.opentelemetry.io/otel/trace"
+    semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
     "golang.org/x/crypto/bcrypt"
-    "component-4/config"
 )
 
+// UserStore implementa UserRepository sobre Postgres.
 type UserStore struct {
-    db *sql.DB
+    db       *sql.DB
+    replicas *ReplicaSet
+    timeout  time.Duration
 }
 
-func NewUserStore(config *config.Config) (*UserStore, error) {
-    dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-        config.DBHost,
-        config.DBPort,
-        config.DBUser,
-        config.DBPassword,
-        config.DBName,
-        config.DBSSLMode,
-    )
+// NewUserStore crea un UserStore sobre el pool compartido del servicio. Los índices de users los
+// crean las migraciones. Con replicas (que puede ser nil) las búsquedas de usuarios se reparten
+// entre las réplicas de lectura; las escrituras siempre van a db.
+func NewUserStore(db *sql.DB, replicas *ReplicaSet, timeout time.Duration) *UserStore {
+    return &UserStore{db: db, replicas: replicas, timeout: timeout}
+}
 
-    db, err := sql.Open("postgres", dsn)
-    if err != nil {
-        return nil, fmt.Errorf("error connecting to database: %v", err)
+// findUser lee un usuario de una réplica si hay alguna disponible para key. La lectura se repite en
+// el primario si la réplica falla o devuelve un usuario modificado hace poco, que podría estar
+// desactualizado en ella.
+func (s *UserStore) findUser(ctx context.Context, key string, query string, args ...interface{}) (*models.User, error) {
+    if rep := s.replicas.pick(key); rep != nil {
+        user, err := scanUser(rep.db.QueryRowContext(ctx, query, args...))
+        switch {
+        case failover(ctx, err):
+            s.replicas.markDown(rep, err)
+        case err == nil && s.replicas.written(userKey(user.ID)):
+            // El usuario cambió hace poco: la réplica puede no tener aún el cambio
+        default:
+            return user, err
+        }
     }
+    return scanUser(s.db.QueryRowContext(ctx, query, args...))
+}
 
-    // Crear índices si no existen
-    _, err = db.Exec(`
-        CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
-        CREATE INDEX IF NOT EXISTS idx_users_google_id ON users(google_id);
-    `)
-    if err != nil {
-        panic(fmt.Sprintf("Error creating indexes: %v", err))
-    }
-    return &UserStore{db: db}, nil
+// markWritten envía al primario las lecturas del usuario recién modificado mientras las réplicas se ponen al día.
+func (s *UserStore) markWritten(user *models.User) {
+    s.replicas.markWritten(userKey(user.ID), emailKey(user.Email))
+}
+
+// startSpan abre el span de una operación del store como hijo del span de la petición.
+func startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+    return tracing.Start(ctx, "UserStore."+operation,
+        trace.WithSpanKind(trace.SpanKindClient),
+        trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationName(operation)),
+    )
+}
+
+// Close no hace nada: el pool es compartido y lo cierra quien lo abrió.
+func (s *UserStore) Close() error {
+    return nil
 }
 
-func (s *UserStore) FindByEmail(email string) (*models.User, error) {
+// querier agrupa los métodos comunes de *sql.DB y *sql.Tx para reutilizar consultas dentro y fuera de transacciones.
+type querier interface {
+    QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+    ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// rowScanner abstrae *sql.Row y *sql.Rows para compartir el código de lectura.
+type rowScanner interface {
+    Scan(dest ...interface{}) error
+}
+
+const userColumns = `id, email, name, password, role, google_id, preferences, deletion_scheduled_at, anonymized_at, created_at, updated_at`
+
+// scanUser lee una fila con las columnas de userColumns.
+func scanUser(row rowScanner) (*models.User, error) {
     user := &models.User{}
-    row := s.db.QueryRow(
-        `SELECT id, email, name, password, role, google_id, created_at, updated_at
-         FROM users WHERE email = $1`, email)
     var id uuid.UUID
     var password, googleID sql.NullString
     var role string
-    err := row.Scan(&id, &user.Email, &user.Name, &password, &role, &googleID, &user.CreatedAt, &user.UpdatedAt)
+    var preferences []byte
+    var deletionScheduledAt, anonymizedAt sql.NullTime
+    err := row.Scan(&id, &user.Email, &user.Name, &password, &role, &googleID, &preferences, &deletionScheduledAt, &anonymizedAt, &user.CreatedAt, &user.UpdatedAt)
     if err != nil {
-        if err == sql.ErrNoRows {
-            return nil, fmt.Errorf("user not found")
-        }
-        return nil, fmt.Errorf("error finding user: %w", err)
+        return nil, err
     }
     user.ID = id
     if password.Valid {
@@ -64,25 +99,89 @@ func (s *UserStore) FindByEmail(email string) (*models.User, error) {
     if googleID.Valid {
         user.GoogleID = &googleID.String
     }
+    user.Preferences = json.RawMessage(preferences)
+    if deletionScheduledAt.Valid {
+        user.DeletionScheduledAt = &deletionScheduledAt.Time
+    }
+    if anonymizedAt.Valid {
+        user.AnonymizedAt = &anonymizedAt.Time
+    }
+    return user, nil
+}
+
+func (s *UserStore) FindByEmail(ctx context.Context, email string) (user *models.User, err error) {
+    ctx, span := startSpan(ctx, "FindByEmail")
+    defer func() { tracing.End(span, err) }()
+    ctx, cancel := withTimeout(ctx, s.timeout)
+    defer cancel()
+    email = normalizeEmail(email)
+    user, err = s.findUser(ctx, emailKey(email), 
+        `SELECT `+userColumns+`
+         FROM users WHERE email = $1`, email)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, ErrUserNotFound
+        }
+        return nil, fmt.Errorf("error finding user: %w", err)
+    }
     return user, nil
 }
 
-func (s *UserStore) CreateNativeUser(email, name, password string, role models.Role) (*models.User, error) {
+// FindByID busca un usuario por su identificador.
+func (s *UserStore) FindByID(ctx context.Context, id uuid.UUID) (user *models.User, err error) {
+    ctx, span := startSpan(ctx, "FindByID")
+    defer func() { tracing.End(span, err) }()
+    ctx, cancel := withTimeout(ctx, s.timeout)
+    defer cancel()
+    user, err = s.findUser(ctx, userKey(id), 
+        `SELECT `+userColumns+`
+         FROM users WHERE id = $1`, id)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, ErrUserNotFound
+        }
+        return nil, fmt.Errorf("error finding user: %w", err)
+    }
+    return user, nil
+}
+
+func (s *UserStore) CreateNativeUser(ctx context.Context, email, name, password string, role models.Role) (user *models.User, err error) {
+    ctx, span := startSpan(ctx, "CreateNativeUser")
+    defer func() { tracing.End(span, err) }()
+    ctx, cancel := withTimeout(ctx, s.timeout)
+    defer cancel()
     // Iniciar transacción
-    tx, err := s.db.Begin()
+    tx, err := s.db.BeginTx(ctx, nil)
     if err != nil {
         return nil, fmt.Errorf("error starting transaction: %w", err)
     }
     defer tx.Rollback()
 
+    user, err = createNativeUser(ctx, tx, email, name, password, role)
+    if err != nil {
+        return nil, err
+    }
+
+    // Commit de la transacción
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %w", err)
+    }
+
+    s.markWritten(user)
+    return user, nil
+}
+
+// createNativeUser inserta un usuario con contraseña usando la transacción dada.
+func createNativeUser(ctx context.Context, q querier, email, name, password string, role models.Role) (*models.User, error) {
+    email = normalizeEmail(email)
     // Verificar si el email ya existe
     var exists bool
-    err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", email).Scan(&exists)
+    err := q.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", email).Scan(&exists)
     if err != nil {
         return nil, fmt.Errorf("error checking email existence: %w", err)
     }
     if exists {
-        return nil, fmt.Errorf("email already exists")
+        return nil, ErrEmailTaken
     }
 
     // Generar hash de contraseña
@@ -96,20 +195,18 @@ func (s *UserStore) CreateNativeUser(email, name, password string, role models.R
     hashStr := string(hash)
     
     // Insertar usuario
-    _, err = tx.Exec(
+    _, err = q.ExecContext(ctx, 
         `INSERT INTO users (id, email, name, password, role, created_at, updated_at)
          VALUES ($1, $2, $3, $4, $5, $6, $7)`,
         id, email, name, hashStr, string(role), now, now,
     )
     if err != nil {
+        if isEmailUniqueViolation(err) {
+            return nil, ErrEmailTaken
+        }
         return nil, fmt.Errorf("error creating user: %w", err)
     }
 
-    // Commit de la transacción
-    if err := tx.Commit(); err != nil {
-        return nil, fmt.Errorf("error committing transaction: %w", err)
-    }
-
     return &models.User{
         ID:        id,
         Email:     email,
@@ -121,42 +218,61 @@ func (s *UserStore) CreateNativeUser(email, name, password string, role models.R
     }, nil
 }
 
-func (s *UserStore) CreateGoogleUser(email, name, googleID string, role models.Role) (*models.User, error) {
+func (s *UserStore) CreateGoogleUser(ctx context.Context, email, name, googleID string, role models.Role) (user *models.User, err error) {
+    ctx, span := startSpan(ctx, "CreateGoogleUser")
+    defer func() { tracing.End(span, err) }()
+    ctx, cancel := withTimeout(ctx, s.timeout)
+    defer cancel()
     // Iniciar transacción
-    tx, err := s.db.Begin()
+    tx, err := s.db.BeginTx(ctx, nil)
     if err != nil {
         return nil, fmt.Errorf("error starting transaction: %w", err)
     }
     defer tx.Rollback()
 
+    user, err = createGoogleUser(ctx, tx, email, name, googleID, role)
+    if err != nil {
+        return nil, err
+    }
+
+    // Commit de la transacción
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %w", err)
+    }
+
+    s.markWritten(user)
+    return user, nil
+}
+
+// createGoogleUser inserta un usuario vinculado a Google usando la transacción dada.
+func createGoogleUser(ctx context.Context, q querier, email, name, googleID string, role models.Role) (*models.User, error) {
+    email = normalizeEmail(email)
     // Verificar si el email ya existe
     var exists bool
-    err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", email).Scan(&exists)
+    err := q.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", email).Scan(&exists)
     if err != nil {
         return nil, fmt.Errorf("error checking email existence: %w", err)
     }
     if exists {
-        return nil, fmt.Errorf("email already exists")
+        return nil, ErrEmailTaken
     }
 
     id := uuid.New()
     now := time.Now()
     
     // Insertar usuario
-    _, err = tx.Exec(
+    _, err = q.ExecContext(ctx, 
         `INSERT INTO users (id, email, name, role, google_id, created_at, updated_at)
          VALUES ($1, $2, $3, $4, $5, $6, $7)`,
         id, email, name, string(role), googleID, now, now,
     )
     if err != nil {
+        if isEmailUniqueViolation(err) {
+            return nil, ErrEmailTaken
+        }
         return nil, fmt.Errorf("error creating user: %w", err)
     }
 
-    // Commit de la transacción
-    if err := tx.Commit(); err != nil {
-        return nil, fmt.Errorf("error committing transaction: %w", err)
-    }
-
     return &models.User{
         ID:        id,
         Email:     email,
@@ -168,23 +284,28 @@ func (s *UserStore) CreateGoogleUser(email, name, googleID string, role models.R
     }, nil
 }
 
-func (s *UserStore) SetPassword(email, password string) error {
+func (s *UserStore) SetPassword(ctx context.Context, email, password string) (err error) {
+    ctx, span := startSpan(ctx, "SetPassword")
+    defer func() { tracing.End(span, err) }()
+    ctx, cancel := withTimeout(ctx, s.timeout)
+    defer cancel()
+    email = normalizeEmail(email)
     // Iniciar transacción
-    tx, err := s.db.Begin()
+    tx, err := s.db.BeginTx(ctx, nil)
     if err != nil {
         return fmt.Errorf("error starting transaction: %w", err)
     }
     defer tx.Rollback()
 
     // Verificar si el usuario existe
-    var exists bool
-    err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", email).Scan(&exists)
+    var id uuid.UUID
+    err = tx.QueryRowContext(ctx, "SELECT id FROM users WHERE email = $1", email).Scan(&id)
+    if err == sql.ErrNoRows {
+        return ErrUserNotFound
+    }
     if err != nil {
         return fmt.Errorf("error checking user existence: %w", err)
     }
-    if !exists {
-        return fmt.Errorf("user not found")
-    }
 
     // Generar hash de contraseña
     hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -193,7 +314,7 @@ func (s *UserStore) SetPassword(email, password string) error {
     }
 
     // Actualizar contraseña
-    _, err = tx.Exec(
+    _, err = tx.ExecContext(ctx, 
         "UPDATE users SET password = $1, updated_at = $2 WHERE email = $3",
         string(hash), time.Now(), email,
     )
@@ -206,60 +327,369 @@ func (s *UserStore) SetPassword(email, password string) error {
         return fmt.Errorf("error committing transaction: %w", err)
     }
 
+    s.replicas.markWritten(userKey(id), emailKey(email))
     return nil
 }
 
-func (s *UserStore) UpsertGoogleUser(email, name, googleID string, role models.Role) (*models.User, error) {
+// UpsertGoogleUser crea el usuario de Google con el rol dado o, si el email ya existe, lo vincula y
+// sincroniza su nombre. El rol de un usuario existente no se toca: un profesor o administrador
+// invitado que vuelve a entrar con Google conserva el suyo.
+func (s *UserStore) UpsertGoogleUser(ctx context.Context, email, name, googleID string, role models.Role) (user *models.User, err error) {
+    ctx, span := startSpan(ctx, "UpsertGoogleUser")
+    defer func() { tracing.End(span, err) }()
+    ctx, cancel := withTimeout(ctx, s.timeout)
+    defer cancel()
+    email = normalizeEmail(email)
     // Iniciar transacción
-    tx, err := s.db.Begin()
+    tx, err := s.db.BeginTx(ctx, nil)
     if err != nil {
         return nil, fmt.Errorf("error starting transaction: %w", err)
     }
     defer tx.Rollback()
 
-    user, err := s.FindByEmail(email)
+    // Con ON CONFLICT dos primeros logins simultáneos no chocan: uno inserta y el otro
+    // sigue por la rama del usuario existente
     now := time.Now()
+    user, err = scanUser(tx.QueryRowContext(ctx, 
+        `INSERT INTO users (id, email, name, role, google_id, created_at, updated_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7)
+         ON CONFLICT (email) DO NOTHING
+         RETURNING `+userColumns,
+        uuid.New(), email, name, string(role), googleID, now, now))
+    if err == nil {
+        if err := tx.Commit(); err != nil {
+            return nil, fmt.Errorf("error committing transaction: %w", err)
+        }
+        s.markWritten(user)
+        return user, nil
+    }
+    if err != sql.ErrNoRows {
+        return nil, fmt.Errorf("error creating user: %w", err)
+    }
+
+    // Ya existe: se bloquea la fila para que la lectura y la actualización sean atómicas
+    user, err = scanUser(tx.QueryRowContext(ctx, 
+        `SELECT `+userColumns+` FROM users WHERE email = $1 FOR UPDATE`, email))
     if err != nil {
-        if err.Error() == "user not found" {
-            // No existe, crear nuevo usuario con Google
-            return s.CreateGoogleUser(email, name, googleID, role)
+        if err == sql.ErrNoRows {
+            return nil, ErrUserNotFound
         }
         return nil, fmt.Errorf("error finding user: %w", err)
     }
+    if user.GoogleID != nil && user.Name == name {
+        return user, nil
+    }
 
-    // Ya existe: actualizamos GoogleID si no está seteado
-    if user.GoogleID == nil {
-        _, err := tx.Exec(
-            `UPDATE users SET google_id = $1, name = $2, role = $3, updated_at = $4 WHERE email = $5`,
-            googleID, name, string(role), now, email,
-        )
+    // Vincular el GoogleID si no está seteado y sincronizar el nombre
+    user, err = scanUser(tx.QueryRowContext(ctx, 
+        `UPDATE users SET google_id = COALESCE(google_id, $1), name = $2, updated_at = $3
+         WHERE id = $4
+         RETURNING `+userColumns,
+        googleID, name, now, user.ID))
+    if err != nil {
+        return nil, fmt.Errorf("error updating user: %w", err)
+    }
+
+    // Commit de la transacción
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %w", err)
+    }
+
+    s.markWritten(user)
+    return user, nil
+}
+// ListUsers devuelve todos los usuarios ordenados por fecha de creación.
+func (s *UserStore) ListUsers(ctx context.Context) (users []*models.User, err error) {
+    ctx, span := startSpan(ctx, "ListUsers")
+    defer func() { tracing.End(span, err) }()
+    ctx, cancel := withTimeout(ctx, s.timeout)
+    defer cancel()
+    if rep := s.replicas.pick(); rep != nil {
+        users, err = listUsers(ctx, rep.db)
+        if !failover(ctx, err) {
+            return users, err
+        }
+        s.replicas.markDown(rep, err)
+    }
+    return listUsers(ctx, s.db)
+}
+
+// listUsers lee todos los usuarios de db ordenados por fecha de creación.
+func listUsers(ctx context.Context, db *sql.DB) ([]*models.User, error) {
+    rows, err := db.QueryContext(ctx, `SELECT ` + userColumns + ` FROM users ORDER BY created_at, email`)
+    if err != nil {
+        return nil, fmt.Errorf("error listing users: %w", err)
+    }
+    defer rows.Close()
+
+    users := []*models.User{}
+    for rows.Next() {
+        user, err := scanUser(rows)
         if err != nil {
-            return nil, fmt.Errorf("error updating user: %w", err)
+            return nil, fmt.Errorf("error reading user: %w", err)
         }
-        user.GoogleID = &googleID
-        user.Name = name
-        user.Role = role
-        user.UpdatedAt = now
-    } else {
-        // Opcional: sincronizar nombre y rol si vienen distintos de Google
-        if user.Name != name || user.Role != role {
-            _, err := tx.Exec(
-                `UPDATE users SET name = $1, role = $2, updated_at = $3 WHERE email = $4`,
-                name, string(role), now, email,
-            )
-            if err != nil {
-                return nil, fmt.Errorf("error updating user: %w", err)
+        users = append(users, user)
+    }
+    return users, rows.Err()
+}
+
+// NewNativeUser describe un usuario con contraseña a crear en lote.
+type NewNativeUser struct {
+    Email    string
+    Name     string
+    Password string
+    Role     models.Role
+}
+
+// BatchResult es el resultado de crear un usuario dentro de un lote.
+// Si Created es false y Err es nil, el email ya existía y la fila se omitió.
+type BatchResult struct {
+    Email   string
+    Created bool
+    Err     error
+}
+
+// CreateNativeUsers crea un lote de usuarios con CreateNativeUser dentro de una única transacción.
+// Cada fila usa su propio savepoint, de modo que un error en una fila no descarta las demás.
+// Los emails que ya existen se omiten, lo que hace la operación idempotente. Con dryRun la
+// transacción se revierte al final y no se persiste nada. El hash de cada contraseña hace que un lote
+// grande tarde más que una operación normal, así que no aplica el límite por operación: solo el de ctx.
+func (s *UserStore) CreateNativeUsers(ctx context.Context, users []NewNativeUser, dryRun bool) (results []BatchResult, err error) {
+    ctx, span := startSpan(ctx, "CreateNativeUsers")
+    defer func() { tracing.End(span, err) }()
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    results = make([]BatchResult, len(users))
+    for i, u := range users {
+        results[i].Email = u.Email
+
+        if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_row"); err != nil {
+            return nil, fmt.Errorf("error creating savepoint: %w", err)
+        }
+        _, err := createNativeUser(ctx, tx, u.Email, u.Name, u.Password, u.Role)
+        switch {
+        case err == nil:
+            results[i].Created = true
+        case errors.Is(err, ErrEmailTaken):
+            // Fila ya importada: se omite
+        default:
+            results[i].Err = err
+            if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_row"); err != nil {
+                return nil, fmt.Errorf("error rolling back savepoint: %w", err)
             }
-            user.Name = name
-            user.Role = role
-            user.UpdatedAt = now
+            continue
+        }
+        if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_row"); err != nil {
+            return nil, fmt.Errorf("error releasing savepoint: %w", err)
         }
     }
 
+    if dryRun {
+        return results, nil
+    }
+
     // Commit de la transacción
     if err := tx.Commit(); err != nil {
         return nil, fmt.Errorf("error committing transaction: %w", err)
     }
+    for _, r := range results {
+        if r.Created {
+            s.replicas.markWritten(emailKey(r.Email))
+        }
+    }
+    return results, nil
+}
 
+// UpdateProfile actualiza el nombre (si no es nil) y fusiona las preferencias dadas con las existentes.
+func (s *UserStore) UpdateProfile(ctx context.Context, id uuid.UUID, name *string, preferences json.RawMessage) (user *models.User, err error) {
+    ctx, span := startSpan(ctx, "UpdateProfile")
+    defer func() { tracing.End(span, err) }()
+    ctx, cancel := withTimeout(ctx, s.timeout)
+    defer cancel()
+    if len(preferences) == 0 {
+        preferences = json.RawMessage(`{}`)
+    }
+    user, err = scanUser(s.db.QueryRowContext(ctx, 
+        `UPDATE users
+         SET name = COALESCE($1, name), preferences = preferences || $2::jsonb, updated_at = $3
+         WHERE id = $4
+         RETURNING `+userColumns,
+        name, string(preferences), time.Now(), id))
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, ErrUserNotFound
+        }
+        return nil, fmt.Errorf("error updating user: %w", err)
+    }
+    s.markWritten(user)
     return user, nil
-}
\ No newline at end of file
+}
+
+// UpdateEmail cambia el email del usuario solo si su email actual sigue siendo oldEmail,
+// de modo que un token de verificación no pueda reutilizarse tras un cambio posterior.
+func (s *UserStore) UpdateEmail(ctx context.Context, id uuid.UUID, oldEmail, newEmail string) (user *models.User, err error) {
+    ctx, span := startSpan(ctx, "UpdateEmail")
+    defer func() { tracing.End(span, err) }()
+    ctx, cancel := withTimeout(ctx, s.timeout)
+    defer cancel()
+    oldEmail, newEmail = normalizeEmail(oldEmail), normalizeEmail(newEmail)
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    var exists bool
+    err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", newEmail).Scan(&exists)
+    if err != nil {
+        return nil, fmt.Errorf("error checking email existence: %w", err)
+    }
+    if exists {
+        return nil, ErrEmailTaken
+    }
+
+    user, err = scanUser(tx.QueryRowContext(ctx, 
+        `UPDATE users SET email = $1, updated_at = $2
+         WHERE id = $3 AND email = $4
+         RETURNING `+userColumns,
+        newEmail, time.Now(), id, oldEmail))
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, ErrUserNotFound
+        }
+        if isEmailUniqueViolation(err) {
+            return nil, ErrEmailTaken
+        }
+        return nil, fmt.Errorf("error updating email: %w", err)
+    }
+
+    // Commit de la transacción
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %w", err)
+    }
+    s.markWritten(user)
+    s.replicas.markWritten(emailKey(oldEmail))
+    return user, nil
+}
+
+// ScheduleDeletion marca la cuenta para ser eliminada definitivamente en la fecha indicada.
+func (s *UserStore) ScheduleDeletion(ctx context.Context, id uuid.UUID, at time.Time) (user *models.User, err error) {
+    ctx, span := startSpan(ctx, "ScheduleDeletion")
+    defer func() { tracing.End(span, err) }()
+    ctx, cancel := withTimeout(ctx, s.timeout)
+    defer cancel()
+    user, err = scanUser(s.db.QueryRowContext(ctx, 
+        `UPDATE users SET deletion_scheduled_at = $1, updated_at = $2
+         WHERE id = $3
+         RETURNING `+userColumns,
+        at, time.Now(), id))
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, ErrUserNotFound
+        }
+        return nil, fmt.Errorf("error scheduling deletion: %w", err)
+    }
+    s.markWritten(user)
+    return user, nil
+}
+
+// CancelDeletion anula una eliminación programada que aún no se ha ejecutado.
+func (s *UserStore) CancelDeletion(ctx context.Context, id uuid.UUID) (user *models.User, err error) {
+    ctx, span := startSpan(ctx, "CancelDeletion")
+    defer func() { tracing.End(span, err) }()
+    ctx, cancel := withTimeout(ctx, s.timeout)
+    defer cancel()
+    user, err = scanUser(s.db.QueryRowContext(ctx, 
+        `UPDATE users SET deletion_scheduled_at = NULL, updated_at = $1
+         WHERE id = $2
+         RETURNING `+userColumns,
+        time.Now(), id))
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, ErrUserNotFound
+        }
+        return nil, fmt.Errorf("error cancelling deletion: %w", err)
+    }
+    s.markWritten(user)
+    return user, nil
+}
+
+// PurgeScheduledDeletions elimina definitivamente las cuentas cuyo periodo de gracia terminó antes de now.
+// Devuelve el número de cuentas eliminadas.
+func (s *UserStore) PurgeScheduledDeletions(ctx context.Context, now time.Time) (n int64, err error) {
+    ctx, span := startSpan(ctx, "PurgeScheduledDeletions")
+    defer func() { tracing.End(span, err) }()
+    ctx, cancel := withTimeout(ctx, s.timeout)
+    defer cancel()
+    rows, err := s.db.QueryContext(ctx, 
+        `DELETE FROM users WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= $1
+         RETURNING id, email`, now)
+    if err != nil {
+        return 0, fmt.Errorf("error purging users: %w", err)
+    }
+    defer rows.Close()
+
+    // Las réplicas pueden seguir devolviendo las cuentas borradas: sus lecturas van al primario
+    for rows.Next() {
+        var id uuid.UUID
+        var email string
+        if err := rows.Scan(&id, &email); err != nil {
+            return n, fmt.Errorf("error reading purged user: %w", err)
+        }
+        s.replicas.markWritten(userKey(id), emailKey(email))
+        n++
+    }
+    if err := rows.Err(); err != nil {
+        return n, fmt.Errorf("error purging users: %w", err)
+    }
+    return n, nil
+}
+
+// Anonymize reemplaza los datos personales del usuario por valores neutros, elimina sus credenciales
+// y revoca sus sesiones. El registro se conserva para mantener la integridad referencial, pero ya no
+// permite iniciar sesión.
+func (s *UserStore) Anonymize(ctx context.Context, id uuid.UUID) (user *models.User, err error) {
+    ctx, span := startSpan(ctx, "Anonymize")
+    defer func() { tracing.End(span, err) }()
+    ctx, cancel := withTimeout(ctx, s.timeout)
+    defer cancel()
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    now := time.Now()
+    user, err = scanUser(tx.QueryRowContext(ctx, 
+        `UPDATE users
+         SET email = $1, name = $2, password = NULL, google_id = NULL, preferences = '{}'::jsonb,
+             deletion_scheduled_at = NULL, anonymized_at = $3, updated_at = $3
+         WHERE id = $4
+         RETURNING `+userColumns,
+        anonymizedEmail(id), anonymizedName, now, id))
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, ErrUserNotFound
+        }
+        return nil, fmt.Errorf("error anonymizing user: %w", err)
+    }
+
+    _, err = tx.ExecContext(ctx, 
+        `UPDATE sessions SET user_agent = '', ip = '', revoked_at = COALESCE(revoked_at, $1) WHERE user_id = $2`,
+        now, id,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("error revoking sessions: %w", err)
+    }
+
+    // Commit de la transacción
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %w", err)
+    }
+    s.markWritten(user)
+    return user, nil
+}
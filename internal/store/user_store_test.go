@@ -0,0 +1,215 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"component-4/internal/migrate"
+	"component-4/internal/models"
+	"component-4/migrations"
+	"github.com/google/uuid"
+)
+
+// testRepositories devuelve las implementaciones de UserRepository contra las que se prueba. Postgres
+// solo se incluye si TEST_DATABASE_URL apunta a una base de datos de pruebas.
+func testRepositories(t *testing.T) map[string]UserRepository {
+	t.Helper()
+
+	sqlite, err := NewSQLiteUserStore(filepath.Join(t.TempDir(), "users.db"), 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewSQLiteUserStore: %v", err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+
+	repos := map[string]UserRepository{
+		"memory": NewMemoryUserStore(),
+		"sqlite": sqlite,
+	}
+
+	if db := testPostgres(t); db != nil {
+		repos["postgres"] = NewUserStore(db, nil, 5*time.Second)
+	}
+	return repos
+}
+
+// testPostgres abre la base de datos de TEST_DATABASE_URL con las migraciones aplicadas, o devuelve
+// nil si no está definida.
+func testPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		return nil
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := migrate.RunMigrations(db, migrations.FS); err != nil {
+		t.Fatalf("RunMigrations: %v", err)
+	}
+	return db
+}
+
+// uniqueEmail evita choques entre ejecuciones cuando la base de datos de pruebas es compartida.
+func uniqueEmail(prefix string) string {
+	return prefix + "-" + uuid.NewString() + "@test.invalid"
+}
+
+// countByEmail cuenta los usuarios con el email dado.
+func countByEmail(t *testing.T, repo UserRepository, email string) int {
+	t.Helper()
+	users, err := repo.ListUsers(context.Background())
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	n := 0
+	for _, u := range users {
+		if u.Email == email {
+			n++
+		}
+	}
+	return n
+}
+
+// TestUpsertGoogleUserConcurrent simula muchos callbacks de Google simultáneos para el mismo email,
+// como los que produce un usuario que pulsa varias veces el botón de inicio de sesión.
+func TestUpsertGoogleUserConcurrent(t *testing.T) {
+	const callers = 32
+
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			email := uniqueEmail("race")
+			ids := make([]uuid.UUID, callers)
+			errs := make([]error, callers)
+
+			var wg sync.WaitGroup
+			start := make(chan struct{})
+			for i := 0; i < callers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					<-start
+					user, err := repo.UpsertGoogleUser(context.Background(), email, "Ana", "google-"+email, models.ROLE_ESTUDIANTE)
+					errs[i] = err
+					if err == nil {
+						ids[i] = user.ID
+					}
+				}(i)
+			}
+			close(start)
+			wg.Wait()
+
+			for i, err := range errs {
+				if err != nil {
+					t.Fatalf("llamada %d: UpsertGoogleUser: %v", i, err)
+				}
+			}
+			for i, id := range ids {
+				if id != ids[0] {
+					t.Fatalf("llamada %d devolvió el usuario %s, la primera %s", i, id, ids[0])
+				}
+			}
+			if n := countByEmail(t, repo, email); n != 1 {
+				t.Fatalf("hay %d usuarios con el email %s, se esperaba 1", n, email)
+			}
+		})
+	}
+}
+
+// TestUpsertGoogleUserKeepsRole comprueba que volver a entrar con Google no cambia el rol de un
+// usuario existente, aunque el callback pida el rol por defecto.
+func TestUpsertGoogleUserKeepsRole(t *testing.T) {
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			email := uniqueEmail("invited")
+			created, err := repo.CreateNativeUser(ctx, email, "Ana", "secreta123", models.ROLE_PROFESOR)
+			if err != nil {
+				t.Fatalf("CreateNativeUser: %v", err)
+			}
+
+			user, err := repo.UpsertGoogleUser(ctx, email, "Ana María", "google-"+email, models.ROLE_ESTUDIANTE)
+			if err != nil {
+				t.Fatalf("UpsertGoogleUser: %v", err)
+			}
+			if user.ID != created.ID {
+				t.Fatalf("UpsertGoogleUser devolvió el usuario %s, se esperaba %s", user.ID, created.ID)
+			}
+			if user.Role != models.ROLE_PROFESOR {
+				t.Fatalf("rol = %s, se esperaba %s", user.Role, models.ROLE_PROFESOR)
+			}
+			if user.Name != "Ana María" || user.GoogleID == nil {
+				t.Fatalf("no se sincronizó el nombre o no se vinculó Google: %+v", user)
+			}
+
+			stored, err := repo.FindByEmail(ctx, email)
+			if err != nil {
+				t.Fatalf("FindByEmail: %v", err)
+			}
+			if stored.Role != models.ROLE_PROFESOR {
+				t.Fatalf("rol guardado = %s, se esperaba %s", stored.Role, models.ROLE_PROFESOR)
+			}
+		})
+	}
+}
+
+// TestEmailsIgnoreCase comprueba que todas las entradas del store tratan como la misma cuenta un
+// email escrito con distintas mayúsculas.
+func TestEmailsIgnoreCase(t *testing.T) {
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			email := uniqueEmail("mixed")
+			created, err := repo.CreateNativeUser(ctx, " "+strings.ToUpper(email), "Ana", "secreta123", models.ROLE_PROFESOR)
+			if err != nil {
+				t.Fatalf("CreateNativeUser: %v", err)
+			}
+			if created.Email != email {
+				t.Fatalf("email guardado = %q, se esperaba %q", created.Email, email)
+			}
+
+			found, err := repo.FindByEmail(ctx, strings.ToUpper(email[:1])+email[1:])
+			if err != nil {
+				t.Fatalf("FindByEmail: %v", err)
+			}
+			if found.ID != created.ID {
+				t.Fatalf("FindByEmail devolvió el usuario %s, se esperaba %s", found.ID, created.ID)
+			}
+
+			if _, err := repo.CreateGoogleUser(ctx, strings.ToUpper(email), "Ana", "google-"+email, models.ROLE_ESTUDIANTE); !errors.Is(err, ErrEmailTaken) {
+				t.Fatalf("CreateGoogleUser: err = %v, se esperaba ErrEmailTaken", err)
+			}
+			if err := repo.SetPassword(ctx, strings.ToUpper(email), "otra-secreta"); err != nil {
+				t.Fatalf("SetPassword: %v", err)
+			}
+
+			user, err := repo.UpsertGoogleUser(ctx, strings.ToUpper(email), "Ana", "google-"+email, models.ROLE_ESTUDIANTE)
+			if err != nil {
+				t.Fatalf("UpsertGoogleUser: %v", err)
+			}
+			if user.ID != created.ID || user.Role != models.ROLE_PROFESOR {
+				t.Fatalf("UpsertGoogleUser devolvió %s (%s), se esperaba %s (%s)", user.ID, user.Role, created.ID, models.ROLE_PROFESOR)
+			}
+			if n := countByEmail(t, repo, email); n != 1 {
+				t.Fatalf("hay %d usuarios con el email %s, se esperaba 1", n, email)
+			}
+
+			newEmail := uniqueEmail("renamed")
+			updated, err := repo.UpdateEmail(ctx, created.ID, strings.ToUpper(email), strings.ToUpper(newEmail))
+			if err != nil {
+				t.Fatalf("UpdateEmail: %v", err)
+			}
+			if updated.Email != newEmail {
+				t.Fatalf("email actualizado = %q, se esperaba %q", updated.Email, newEmail)
+			}
+		})
+	}
+}
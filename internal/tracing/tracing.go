@@ -0,0 +1,73 @@
+// Package tracing configura el trazado distribuido con OpenTelemetry: proveedor de trazas,
+// exportador OTLP opcional y propagación W3C trace-context.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"component-4/config"
+)
+
+const instrumentationName = "component-4"
+
+// Setup instala el proveedor de trazas global. Si no hay endpoint OTLP configurado las trazas
+// se generan (y se propagan) pero no se exportan, de modo que el servicio funciona sin colector.
+// La función devuelta vacía los spans pendientes y debe llamarse al apagar el servicio.
+func Setup(ctx context.Context, cfg *config.Config) (func(context.Context) error, error) {
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error building trace resource: %w", err)
+	}
+
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	if cfg.OTLPEndpoint != "" {
+		// El exportador lee OTEL_EXPORTER_OTLP_ENDPOINT y el resto de variables OTEL_EXPORTER_OTLP_* estándar
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error creating OTLP exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return provider.Shutdown, nil
+}
+
+// Middleware crea un span por petición con la plantilla de la ruta de mux como nombre y
+// continúa la traza recibida en las cabeceras traceparent/tracestate.
+func Middleware(serviceName string) func(http.Handler) http.Handler {
+	return otelmux.Middleware(serviceName)
+}
+
+// Start abre un span hijo del span presente en ctx.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End registra err en el span (si no es nil) y lo cierra.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,2 @@
+-- Preferencias del usuario editables desde /api/v1/me
+ALTER TABLE users ADD COLUMN IF NOT EXISTS preferences JSONB NOT NULL DEFAULT '{}'::jsonb;
@@ -0,0 +1,7 @@
+-- Solicitudes de eliminación de cuenta con periodo de gracia y anonimización
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at
+    ON users(deletion_scheduled_at)
+    WHERE deletion_scheduled_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Los emails siguen en minúsculas: la capitalización original no se conserva
+DROP INDEX IF EXISTS users_email_lower_key;
@@ -0,0 +1,27 @@
+-- Los emails se guardan en minúsculas y se comparan sin distinguir mayúsculas. Si ya existen
+-- cuentas que solo se diferencian en las mayúsculas hay que fusionarlas a mano antes de migrar
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users GROUP BY lower(email) HAVING COUNT(*) > 1) THEN
+        RAISE EXCEPTION 'users contains emails that differ only in case; merge those accounts before migrating';
+    END IF;
+END $$;
+
+UPDATE users SET email = lower(email) WHERE email <> lower(email);
+
+-- Garantiza que no vuelvan a coexistir variantes del mismo email aunque algún cliente no normalice
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_key ON users(lower(email));
+
+-- De las invitaciones pendientes que pasan a compartir email solo se conserva la más reciente,
+-- igual que al reenviar una invitación
+UPDATE invitations i
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE i.accepted_at IS NULL AND i.revoked_at IS NULL
+  AND EXISTS (
+      SELECT 1 FROM invitations j
+      WHERE lower(j.email) = lower(i.email) AND j.id <> i.id
+        AND j.accepted_at IS NULL AND j.revoked_at IS NULL
+        AND (j.created_at, j.id) > (i.created_at, i.id)
+  );
+
+UPDATE invitations SET email = lower(email) WHERE email <> lower(email);
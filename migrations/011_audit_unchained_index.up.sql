@@ -0,0 +1,4 @@
+-- Los eventos se guardan sin encadenar y un proceso en segundo plano los encadena en orden
+CREATE INDEX IF NOT EXISTS idx_audit_events_unchained
+    ON audit_events(occurred_at, id)
+    WHERE seq IS NULL;
@@ -0,0 +1,9 @@
+// Package migrations incluye en el binario los archivos SQL de este directorio.
+package migrations
+
+import "embed"
+
+// FS contiene las migraciones *.up.sql y *.down.sql.
+//
+//go:embed *.sql
+var FS embed.FS
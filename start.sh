@@ -12,7 +12,7 @@ set +a
 if [ "$1" = "-d" ]; then
     export DB_HOST=localhost
     export PORT=8082
-    go run cmd/main.go
+    go run ./cmd
 else
     docker-compose up --build
 fi
\ No newline at end of file